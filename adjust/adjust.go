@@ -0,0 +1,104 @@
+// Package adjust は画像の明るさと階調を調整する。
+// レベル補正(黒点と白点)、ガンマ補正、明るさとコントラストの順に、
+// 非乗算済みのR、G、Bへ同じトーンカーブを適用する。アルファは変えない。
+package adjust
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/kouheiszk/png-reader/internal/imageutil"
+)
+
+// Options は調整の設定。ゼロ値は何も変えない。値はすべて0から1の範囲の明るさを基準にする。
+type Options struct {
+	// BlackPoint 以下の値を0に、WhitePoint 以上の値を1にし、その間を引き伸ばす。
+	// WhitePointが0の場合は1として扱う。
+	BlackPoint, WhitePoint float64
+	// Gamma は出力を入力の1/Gamma乗にする。1より大きいと中間調が明るくなる。0の場合は1
+	Gamma float64
+	// Brightness は-1から1で、値に加える。
+	Brightness float64
+	// Contrast は-1から1で、0.5を中心に値を(1+Contrast)倍する。
+	Contrast float64
+}
+
+// IsZero はoが何も変えない設定かどうかを返す。
+func (o *Options) IsZero() bool {
+	return o == nil || *o == Options{} || *o == Options{WhitePoint: 1, Gamma: 1}
+}
+
+func (o *Options) validate() error {
+	white := o.whitePoint()
+	switch {
+	case o.BlackPoint < 0 || white > 1 || o.BlackPoint >= white:
+		return fmt.Errorf("adjust: need 0 <= black point (%g) < white point (%g) <= 1", o.BlackPoint, white)
+	case o.Gamma < 0:
+		return fmt.Errorf("adjust: gamma must be positive, got %g", o.Gamma)
+	case o.Brightness < -1 || o.Brightness > 1:
+		return fmt.Errorf("adjust: brightness must be between -1 and 1, got %g", o.Brightness)
+	case o.Contrast < -1 || o.Contrast > 1:
+		return fmt.Errorf("adjust: contrast must be between -1 and 1, got %g", o.Contrast)
+	}
+	return nil
+}
+
+func (o *Options) whitePoint() float64 {
+	if o.WhitePoint == 0 {
+		return 1
+	}
+	return o.WhitePoint
+}
+
+// curve は0から1の値vを調整した値を返す。
+func (o *Options) curve(v float64) float64 {
+	black, white := o.BlackPoint, o.whitePoint()
+	v = (v - black) / (white - black)
+	v = math.Max(0, math.Min(1, v))
+	if o.Gamma != 0 && o.Gamma != 1 {
+		v = math.Pow(v, 1/o.Gamma)
+	}
+	v = (v-0.5)*(1+o.Contrast) + 0.5 + o.Brightness
+	return math.Max(0, math.Min(1, v))
+}
+
+// Apply はimgを調整した画像を返す。imgは変更しない。
+// imgが16ビットの場合は*image.NRGBA64を、それ以外の場合は*image.NRGBAを返す。
+func Apply(img image.Image, o *Options) (image.Image, error) {
+	if o == nil {
+		o = new(Options)
+	}
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+	b := img.Bounds()
+	if imageutil.Is16Bit(img) {
+		lut := make([]uint16, 1<<16)
+		for i := range lut {
+			lut[i] = uint16(math.Round(o.curve(float64(i)/0xffff) * 0xffff))
+		}
+		dst := image.NewNRGBA64(b)
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				c := imageutil.NRGBA64(img.At(x, y))
+				dst.SetNRGBA64(x, y, color.NRGBA64{lut[c.R], lut[c.G], lut[c.B], c.A})
+			}
+		}
+		return dst, nil
+	}
+
+	var lut [256]uint8
+	for i := range lut {
+		lut[i] = uint8(math.Round(o.curve(float64(i)/0xff) * 0xff))
+	}
+	dst := image.NewNRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := imageutil.NRGBA64(img.At(x, y))
+			dst.SetNRGBA(x, y, color.NRGBA{lut[c.R>>8], lut[c.G>>8], lut[c.B>>8], uint8(c.A >> 8)})
+		}
+	}
+	return dst, nil
+}
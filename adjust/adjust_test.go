@@ -0,0 +1,93 @@
+package adjust
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// TestCurve はトーンカーブが各調整を決めた順に適用することを確認する。
+func TestCurve(t *testing.T) {
+	tests := []struct {
+		o       Options
+		in, out float64
+	}{
+		{Options{}, 0.3, 0.3},
+		{Options{BlackPoint: 0.25, WhitePoint: 0.75}, 0.5, 0.5},
+		{Options{BlackPoint: 0.25, WhitePoint: 0.75}, 0.2, 0},
+		{Options{BlackPoint: 0.25, WhitePoint: 0.75}, 0.625, 0.75},
+		{Options{Gamma: 2}, 0.25, 0.5},
+		{Options{Brightness: 0.2}, 0.5, 0.7},
+		{Options{Brightness: -0.5}, 0.25, 0},
+		{Options{Contrast: 1}, 0.625, 0.75},
+		{Options{Contrast: -1}, 0.9, 0.5},
+		// 黒点と白点で0.5になった値をガンマで明るくしてから、明るさを加える
+		{Options{WhitePoint: 0.5, Gamma: 0.5, Brightness: 0.1}, 0.25, 0.35},
+	}
+	for _, tt := range tests {
+		if got := tt.o.curve(tt.in); got < tt.out-1e-9 || got > tt.out+1e-9 {
+			t.Errorf("%+v: curve(%v) = %v, want %v", tt.o, tt.in, got, tt.out)
+		}
+	}
+}
+
+// TestApply は8ビットと16ビットの画像で色だけが変わり、アルファと範囲が保たれることを確認する。
+func TestApply(t *testing.T) {
+	shallow := image.NewNRGBA(image.Rect(2, 3, 4, 4))
+	shallow.SetNRGBA(2, 3, color.NRGBA{0x40, 0x80, 0xff, 0x20})
+	deep := image.NewNRGBA64(image.Rect(2, 3, 4, 4))
+	deep.SetNRGBA64(2, 3, color.NRGBA64{0x4000, 0x8000, 0xffff, 0x2000})
+
+	o := &Options{Brightness: 0.25}
+	out, err := Apply(shallow, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, ok := out.At(2, 3).(color.NRGBA); !ok || c != (color.NRGBA{0x80, 0xc0, 0xff, 0x20}) || out.Bounds() != shallow.Rect {
+		t.Errorf("8-bit: got %#v in %v", out.At(2, 3), out.Bounds())
+	}
+	out, err = Apply(deep, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, ok := out.At(2, 3).(color.NRGBA64); !ok || c != (color.NRGBA64{0x8000, 0xc000, 0xffff, 0x2000}) {
+		t.Errorf("16-bit: got %#v", out.At(2, 3))
+	}
+
+	for _, img := range []image.Image{shallow, deep} {
+		out, err := Apply(img, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.At(2, 3) != img.At(2, 3) {
+			t.Errorf("nil options changed %v to %v", img.At(2, 3), out.At(2, 3))
+		}
+	}
+}
+
+func TestIsZero(t *testing.T) {
+	for _, o := range []*Options{nil, {}, {WhitePoint: 1, Gamma: 1}} {
+		if !o.IsZero() {
+			t.Errorf("%+v is not zero", o)
+		}
+	}
+	if (&Options{Gamma: 2}).IsZero() {
+		t.Error("gamma 2 is zero")
+	}
+}
+
+func TestInvalidOptions(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	for _, o := range []*Options{
+		{BlackPoint: -0.1},
+		{WhitePoint: 1.5},
+		{BlackPoint: 0.5, WhitePoint: 0.5},
+		{Gamma: -1},
+		{Brightness: 2},
+		{Contrast: -1.5},
+	} {
+		if _, err := Apply(img, o); err == nil {
+			t.Errorf("%+v: no error", o)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+// Package atlas は複数の画像を重ならないように1枚のシート(テクスチャアトラス)に並べる。
+// 配置は高さの順に棚(シェルフ)へ詰める単純な方法で、画像を回転させることはない。
+package atlas
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/kouheiszk/png-reader/internal/imageutil"
+)
+
+// ErrEmpty は並べる画像がないことを表す。
+var ErrEmpty = errors.New("atlas: no images to pack")
+
+// Options は配置の設定
+type Options struct {
+	// MaxWidth はシートの最大の幅。0の場合は面積の合計から正方形に近くなる幅を選ぶ
+	MaxWidth int
+	// Padding は隣り合う画像の間の画素数
+	Padding int
+	// PowerOfTwo はシートの幅と高さを2の累乗に切り上げるかどうか
+	PowerOfTwo bool
+}
+
+// Pack はsizesの大きさの矩形をシートに並べ、それぞれの位置とシートの大きさを返す。
+// 返す矩形の順番はsizesと同じになる。
+func Pack(sizes []image.Point, opts Options) ([]image.Rectangle, image.Point, error) {
+	if len(sizes) == 0 {
+		return nil, image.Point{}, ErrEmpty
+	}
+	if opts.Padding < 0 || opts.MaxWidth < 0 {
+		return nil, image.Point{}, fmt.Errorf("atlas: negative padding or width")
+	}
+	area, widest := 0, 0
+	for _, s := range sizes {
+		if s.X <= 0 || s.Y <= 0 {
+			return nil, image.Point{}, fmt.Errorf("atlas: empty image of size %v", s)
+		}
+		area += (s.X + opts.Padding) * (s.Y + opts.Padding)
+		if s.X > widest {
+			widest = s.X
+		}
+	}
+	width := opts.MaxWidth
+	if width == 0 {
+		width = int(math.Ceil(math.Sqrt(float64(area))))
+		if width < widest {
+			width = widest
+		}
+	} else if widest > width {
+		return nil, image.Point{}, fmt.Errorf("atlas: image width %d exceeds the maximum width %d", widest, width)
+	}
+
+	// 高い順に並べ、収まる最初の棚の右端に置く。どの棚にも収まらなければ新しい棚を作る
+	order := make([]int, len(sizes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		si, sj := sizes[order[i]], sizes[order[j]]
+		if si.Y != sj.Y {
+			return si.Y > sj.Y
+		}
+		return si.X > sj.X
+	})
+	type shelf struct{ y, x int }
+	var shelves []shelf
+	bottom := 0
+	rects := make([]image.Rectangle, len(sizes))
+	var size image.Point
+	for _, i := range order {
+		s := sizes[i]
+		n := 0
+		for n < len(shelves) && shelves[n].x+s.X > width {
+			n++
+		}
+		if n == len(shelves) {
+			shelves = append(shelves, shelf{y: bottom})
+			bottom += s.Y + opts.Padding
+		}
+		r := image.Rectangle{Min: image.Pt(shelves[n].x, shelves[n].y)}
+		r.Max = r.Min.Add(s)
+		rects[i] = r
+		shelves[n].x = r.Max.X + opts.Padding
+		if r.Max.X > size.X {
+			size.X = r.Max.X
+		}
+		if r.Max.Y > size.Y {
+			size.Y = r.Max.Y
+		}
+	}
+	if opts.PowerOfTwo {
+		size = image.Pt(ceilPow2(size.X), ceilPow2(size.Y))
+	}
+	return rects, size, nil
+}
+
+// ceilPow2 はn以上で最小の2の累乗を返す。
+func ceilPow2(n int) int {
+	p := 1
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
+
+// Draw はimagesをrectsの位置に置いた大きさsizeの画像を返す。画像のない部分は透明になる。
+// 値は非乗算済みのまま写すので、半透明な画素の色も変わらない。
+// いずれかの画像が16ビットの場合はNRGBA64、それ以外はNRGBAを返す。
+func Draw(images []image.Image, rects []image.Rectangle, size image.Point) image.Image {
+	bounds := image.Rectangle{Max: size}
+	deep := false
+	for _, img := range images {
+		deep = deep || imageutil.Is16Bit(img)
+	}
+	var nrgba *image.NRGBA
+	var nrgba64 *image.NRGBA64
+	if deep {
+		nrgba64 = image.NewNRGBA64(bounds)
+	} else {
+		nrgba = image.NewNRGBA(bounds)
+	}
+	for i, img := range images {
+		b := img.Bounds()
+		r := rects[i]
+		for y := 0; y < b.Dy(); y++ {
+			for x := 0; x < b.Dx(); x++ {
+				c := imageutil.NRGBA64(img.At(b.Min.X+x, b.Min.Y+y))
+				if deep {
+					nrgba64.SetNRGBA64(r.Min.X+x, r.Min.Y+y, c)
+				} else {
+					nrgba.SetNRGBA(r.Min.X+x, r.Min.Y+y, color.NRGBA{uint8(c.R >> 8), uint8(c.G >> 8), uint8(c.B >> 8), uint8(c.A >> 8)})
+				}
+			}
+		}
+	}
+	if deep {
+		return nrgba64
+	}
+	return nrgba
+}
@@ -0,0 +1,96 @@
+package atlas
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+// TestPack は配置した矩形が元の大きさを保ち、シートに収まり、間隔を空けて重ならないことを確認する。
+func TestPack(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	sizes := make([]image.Point, 60)
+	for i := range sizes {
+		sizes[i] = image.Pt(1+r.Intn(40), 1+r.Intn(40))
+	}
+	for _, opts := range []Options{{}, {Padding: 2}, {MaxWidth: 64, Padding: 1}, {MaxWidth: 40}, {PowerOfTwo: true, Padding: 3}} {
+		rects, size, err := Pack(sizes, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if opts.MaxWidth > 0 && size.X > opts.MaxWidth {
+			t.Errorf("%+v: width %d exceeds the maximum", opts, size.X)
+		}
+		if opts.PowerOfTwo && (size.X&(size.X-1) != 0 || size.Y&(size.Y-1) != 0) {
+			t.Errorf("%+v: size %v is not a power of two", opts, size)
+		}
+		sheet := image.Rectangle{Max: size}
+		pad := func(r image.Rectangle) image.Rectangle {
+			return image.Rectangle{Min: r.Min, Max: r.Max.Add(image.Pt(opts.Padding, opts.Padding))}
+		}
+		for i, rect := range rects {
+			if rect.Size() != sizes[i] || !rect.In(sheet) {
+				t.Errorf("%+v: rect %d is %v for size %v in %v", opts, i, rect, sizes[i], size)
+			}
+			// 間隔の分だけ広げても、他の矩形と重ならない
+			for j := i + 1; j < len(rects); j++ {
+				if pad(rect).Overlaps(rects[j]) || rect.Overlaps(pad(rects[j])) {
+					t.Errorf("%+v: rects %d %v and %d %v are too close", opts, i, rect, j, rects[j])
+				}
+			}
+		}
+	}
+}
+
+func TestPackErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		sizes []image.Point
+		opts  Options
+	}{
+		{"empty", nil, Options{}},
+		{"zero size", []image.Point{{0, 3}}, Options{}},
+		{"too wide", []image.Point{{10, 1}}, Options{MaxWidth: 8}},
+		{"negative padding", []image.Point{{1, 1}}, Options{Padding: -1}},
+	}
+	for _, tt := range tests {
+		if _, _, err := Pack(tt.sizes, tt.opts); err == nil {
+			t.Errorf("%s: no error", tt.name)
+		}
+	}
+	if _, _, err := Pack(nil, Options{}); err != ErrEmpty {
+		t.Errorf("got %v, want ErrEmpty", err)
+	}
+}
+
+// TestDraw は画像が指定した位置に非乗算済みの値のまま描かれ、残りが透明になることを確認する。
+func TestDraw(t *testing.T) {
+	a := image.NewNRGBA(image.Rect(5, 5, 7, 6))
+	a.SetNRGBA(6, 5, color.NRGBA{0xff, 0x80, 0, 0x40})
+	b := image.NewGray(image.Rect(0, 0, 1, 2))
+	b.Pix[1] = 0x33
+	out := Draw([]image.Image{a, b}, []image.Rectangle{image.Rect(0, 0, 2, 1), image.Rect(3, 1, 4, 3)}, image.Pt(4, 3)).(*image.NRGBA)
+
+	if out.Rect != image.Rect(0, 0, 4, 3) {
+		t.Fatalf("bounds %v", out.Rect)
+	}
+	tests := []struct {
+		x, y int
+		want color.NRGBA
+	}{
+		{1, 0, color.NRGBA{0xff, 0x80, 0, 0x40}},
+		{3, 2, color.NRGBA{0x33, 0x33, 0x33, 0xff}},
+		{2, 0, color.NRGBA{}},
+	}
+	for _, tt := range tests {
+		if got := out.NRGBAAt(tt.x, tt.y); got != tt.want {
+			t.Errorf("(%d, %d) = %v, want %v", tt.x, tt.y, got, tt.want)
+		}
+	}
+
+	deep := image.NewGray16(image.Rect(0, 0, 1, 1))
+	if _, ok := Draw([]image.Image{a, deep}, []image.Rectangle{image.Rect(0, 0, 2, 1), image.Rect(0, 1, 1, 2)}, image.Pt(2, 2)).(*image.NRGBA64); !ok {
+		t.Error("a 16-bit image did not produce NRGBA64")
+	}
+}
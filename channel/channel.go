@@ -0,0 +1,164 @@
+// Package channel は画像のチャンネルを取り出し、組み合わせ、別の画像に差し込む。
+// 値は非乗算済みのまま扱うので、透明な画素の色も保たれる。
+// 結果は元の画像が16ビットの場合は16ビット、それ以外は8ビットになる。
+package channel
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/kouheiszk/png-reader/internal/imageutil"
+)
+
+// ErrSize は組み合わせる画像の大きさが異なることを表す。
+var ErrSize = errors.New("channel: images have different sizes")
+
+// Channel はNRGBAのチャンネル
+type Channel int
+
+const (
+	R Channel = iota
+	G
+	B
+	A
+)
+
+// Channels はすべてのチャンネルをR、G、B、Aの順に並べたもの
+var Channels = []Channel{R, G, B, A}
+
+func (c Channel) String() string {
+	return [...]string{"r", "g", "b", "a"}[c]
+}
+
+// value はcのチャンネルcの値を返す。
+func (c Channel) value(v color.NRGBA64) uint16 {
+	return [...]uint16{v.R, v.G, v.B, v.A}[c]
+}
+
+// Extract はimgのチャンネルcをグレースケールの画像として返す。
+func Extract(img image.Image, c Channel) image.Image {
+	b := img.Bounds()
+	rect := image.Rect(0, 0, b.Dx(), b.Dy())
+	if imageutil.Is16Bit(img) {
+		dst := image.NewGray16(rect)
+		for y := 0; y < b.Dy(); y++ {
+			for x := 0; x < b.Dx(); x++ {
+				dst.SetGray16(x, y, color.Gray16{c.value(imageutil.NRGBA64(img.At(b.Min.X+x, b.Min.Y+y)))})
+			}
+		}
+		return dst
+	}
+	dst := image.NewGray(rect)
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			dst.SetGray(x, y, color.Gray{uint8(c.value(imageutil.NRGBA64(img.At(b.Min.X+x, b.Min.Y+y))) >> 8)})
+		}
+	}
+	return dst
+}
+
+// Split はimgをR、G、B、Aの4つのグレースケールの画像に分ける。
+func Split(img image.Image) []image.Image {
+	planes := make([]image.Image, len(Channels))
+	for i, c := range Channels {
+		planes[i] = Extract(img, c)
+	}
+	return planes
+}
+
+// Merge はR、G、B、Aの順に並べたグレースケールの画像の輝度を各チャンネルにした画像を返す。
+// nilのチャンネルは、R、G、Bでは0、Aでは不透明になる。すべてnilの場合や、
+// 大きさが異なる画像がある場合はエラーを返す。
+func Merge(planes []image.Image) (image.Image, error) {
+	if len(planes) != len(Channels) {
+		return nil, fmt.Errorf("channel: Merge needs %d planes, got %d", len(Channels), len(planes))
+	}
+	var size image.Point
+	found, is16Bit := false, false
+	for _, p := range planes {
+		if p == nil {
+			continue
+		}
+		if !found {
+			size, found = p.Bounds().Size(), true
+		} else if p.Bounds().Size() != size {
+			return nil, ErrSize
+		}
+		is16Bit = is16Bit || imageutil.Is16Bit(p)
+	}
+	if !found {
+		return nil, errors.New("channel: Merge with no planes")
+	}
+
+	at := func(c Channel, x, y int) uint16 {
+		p := planes[c]
+		if p == nil {
+			if c == A {
+				return 0xffff
+			}
+			return 0
+		}
+		b := p.Bounds()
+		return luma(p.At(b.Min.X+x, b.Min.Y+y))
+	}
+	rect := image.Rectangle{Max: size}
+	if is16Bit {
+		dst := image.NewNRGBA64(rect)
+		for y := 0; y < size.Y; y++ {
+			for x := 0; x < size.X; x++ {
+				dst.SetNRGBA64(x, y, color.NRGBA64{at(R, x, y), at(G, x, y), at(B, x, y), at(A, x, y)})
+			}
+		}
+		return dst, nil
+	}
+	dst := image.NewNRGBA(rect)
+	for y := 0; y < size.Y; y++ {
+		for x := 0; x < size.X; x++ {
+			dst.SetNRGBA(x, y, color.NRGBA{uint8(at(R, x, y) >> 8), uint8(at(G, x, y) >> 8), uint8(at(B, x, y) >> 8), uint8(at(A, x, y) >> 8)})
+		}
+	}
+	return dst, nil
+}
+
+// Alpha はimgのアルファチャンネルをグレースケールの画像として返す。
+// 不透明な画素は白、透明な画素は黒になる。
+func Alpha(img image.Image) image.Image {
+	return Extract(img, A)
+}
+
+// SetAlpha はimgの色にmaskの輝度をアルファとして組み合わせた画像を返す。
+// imgのアルファは捨てる。imgとmaskの大きさが異なる場合はErrSizeを返す。
+func SetAlpha(img, mask image.Image) (image.Image, error) {
+	b, mb := img.Bounds(), mask.Bounds()
+	if b.Dx() != mb.Dx() || b.Dy() != mb.Dy() {
+		return nil, ErrSize
+	}
+	rect := image.Rect(0, 0, b.Dx(), b.Dy())
+	if imageutil.Is16Bit(img) || imageutil.Is16Bit(mask) {
+		dst := image.NewNRGBA64(rect)
+		for y := 0; y < b.Dy(); y++ {
+			for x := 0; x < b.Dx(); x++ {
+				c := imageutil.NRGBA64(img.At(b.Min.X+x, b.Min.Y+y))
+				c.A = luma(mask.At(mb.Min.X+x, mb.Min.Y+y))
+				dst.SetNRGBA64(x, y, c)
+			}
+		}
+		return dst, nil
+	}
+	dst := image.NewNRGBA(rect)
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			c := imageutil.NRGBA64(img.At(b.Min.X+x, b.Min.Y+y))
+			a := luma(mask.At(mb.Min.X+x, mb.Min.Y+y))
+			dst.SetNRGBA(x, y, color.NRGBA{uint8(c.R >> 8), uint8(c.G >> 8), uint8(c.B >> 8), uint8(a >> 8)})
+		}
+	}
+	return dst, nil
+}
+
+// luma はcの輝度を返す。グレースケールの色はそのままの値を返す。
+func luma(c color.Color) uint16 {
+	return color.Gray16Model.Convert(c).(color.Gray16).Y
+}
@@ -0,0 +1,122 @@
+package channel
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// testImage は各画素のR、G、B、Aに座標から決めた値を持つ、原点がずれた3x2の画像を返す。
+// (0, 0)にあたる画素は透明でも色を持つ。
+func testImage() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(4, 5, 7, 7))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 3; x++ {
+			img.SetNRGBA(4+x, 5+y, color.NRGBA{uint8(10 + x), uint8(20 + y), 30, uint8(x * 100)})
+		}
+	}
+	return img
+}
+
+func TestAlpha(t *testing.T) {
+	mask := Alpha(testImage())
+	gray, ok := mask.(*image.Gray)
+	if !ok || gray.Rect != image.Rect(0, 0, 3, 2) {
+		t.Fatalf("got %T with bounds %v", mask, mask.Bounds())
+	}
+	for x := 0; x < 3; x++ {
+		if v := gray.GrayAt(x, 1).Y; v != uint8(x*100) {
+			t.Errorf("(%d, 1) = %d, want %d", x, v, x*100)
+		}
+	}
+
+	deep := image.NewNRGBA64(image.Rect(0, 0, 1, 1))
+	deep.SetNRGBA64(0, 0, color.NRGBA64{A: 0x1234})
+	if m, ok := Alpha(deep).(*image.Gray16); !ok || m.Gray16At(0, 0).Y != 0x1234 {
+		t.Errorf("16-bit alpha: got %v", Alpha(deep).At(0, 0))
+	}
+}
+
+// TestSetAlpha はマスクの輝度がアルファになり、透明だった画素の色も保たれることを確認する。
+func TestSetAlpha(t *testing.T) {
+	img := testImage()
+	mask := image.NewGray(image.Rect(0, 0, 3, 2))
+	for i := range mask.Pix {
+		mask.Pix[i] = 0xff
+	}
+	mask.Pix[1] = 0x80
+	out, err := SetAlpha(img, mask)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nrgba := out.(*image.NRGBA)
+	if c := nrgba.NRGBAAt(0, 0); c != (color.NRGBA{10, 20, 30, 0xff}) {
+		t.Errorf("(0, 0) = %v, want the transparent pixel's color made opaque", c)
+	}
+	if c := nrgba.NRGBAAt(1, 0); c != (color.NRGBA{11, 20, 30, 0x80}) {
+		t.Errorf("(1, 0) = %v", c)
+	}
+
+	// 色のマスクは輝度を使い、16ビットのマスクなら結果も16ビットになる
+	deep := image.NewRGBA64(image.Rect(0, 0, 3, 2))
+	for i := 0; i < len(deep.Pix); i += 2 {
+		deep.Pix[i], deep.Pix[i+1] = 0xff, 0xff
+	}
+	out, err = SetAlpha(img, deep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, ok := out.At(2, 1).(color.NRGBA64); !ok || c != (color.NRGBA64{12 * 0x101, 21 * 0x101, 30 * 0x101, 0xffff}) {
+		t.Errorf("16-bit mask: got %#v", out.At(2, 1))
+	}
+
+	if _, err := SetAlpha(img, image.NewGray(image.Rect(0, 0, 2, 3))); err != ErrSize {
+		t.Errorf("got %v, want ErrSize", err)
+	}
+}
+
+// TestSplitMerge はSplitした4枚をMergeすると、透明な画素の色も含めて元に戻ることを確認する。
+func TestSplitMerge(t *testing.T) {
+	img := testImage()
+	planes := Split(img)
+	if len(planes) != 4 {
+		t.Fatalf("%d planes", len(planes))
+	}
+	if v := planes[G].(*image.Gray).GrayAt(2, 1).Y; v != 21 {
+		t.Errorf("G at (2, 1) = %d, want 21", v)
+	}
+	merged, err := Merge(planes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 3; x++ {
+			if got, want := merged.At(x, y), img.At(4+x, 5+y); got != want {
+				t.Errorf("(%d, %d) = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestMergeMissing(t *testing.T) {
+	red := image.NewGray16(image.Rect(0, 0, 2, 2))
+	red.SetGray16(1, 1, color.Gray16{0x8000})
+	merged, err := Merge([]image.Image{red, nil, nil, nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := merged.At(1, 1); c != (color.NRGBA64{0x8000, 0, 0, 0xffff}) {
+		t.Errorf("got %#v, want 16-bit red with opaque alpha", c)
+	}
+
+	tests := map[string][]image.Image{
+		"no planes":  {nil, nil, nil, nil},
+		"three":      {red, red, red},
+		"mixed size": {red, image.NewGray(image.Rect(0, 0, 2, 3)), nil, nil},
+	}
+	for name, planes := range tests {
+		if _, err := Merge(planes); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package pngreader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// chunk はPNGのチャンク1つ分のデータ
+type chunk struct {
+	length    uint32
+	chunkType string
+	data      []byte
+	crc       uint32
+
+	// offset はファイル先頭からのチャンクの位置、index は同じタイプの中で何番目か(1から)
+	offset int64
+	index  int
+}
+
+// computeCRC はチャンクタイプとデータからCRCを計算する。
+func (c *chunk) computeCRC() uint32 {
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(c.chunkType))
+	crc.Write(c.data)
+	return crc.Sum32()
+}
+
+// chunkReader はファイル先頭からの位置を追跡しながらチャンクを読み込む。
+type chunkReader struct {
+	buffer *bytes.Buffer
+	offset int64
+}
+
+// next は正確にnバイトを読み出す。残りが足りない場合はエラーを返す。
+func (r *chunkReader) next(n int) ([]byte, error) {
+	if n < 0 || r.buffer.Len() < n {
+		return nil, io.ErrUnexpectedEOF
+	}
+	r.offset += int64(n)
+	return r.buffer.Next(n), nil
+}
+
+// readChunk は次のチャンクを読み込む。残りがない場合はio.EOFを返す。
+// チャンクが途中で切れている場合も、読み込めた範囲のチャンクをエラーとともに返す。
+func (r *chunkReader) readChunk() (*chunk, error) {
+	if r.buffer.Len() == 0 {
+		return nil, io.EOF
+	}
+	c := &chunk{offset: r.offset}
+	header, err := r.next(8)
+	if err != nil {
+		return c, fmt.Errorf("truncated chunk header: %w", err)
+	}
+	c.length = binary.BigEndian.Uint32(header[0:4])
+	c.chunkType = string(header[4:8])
+	if uint64(c.length) > uint64(r.buffer.Len()) {
+		return c, fmt.Errorf("truncated %s chunk: %w", c.chunkType, io.ErrUnexpectedEOF)
+	}
+	c.data, _ = r.next(int(c.length))
+	crc, err := r.next(4)
+	if err != nil {
+		return c, fmt.Errorf("truncated %s chunk: %w", c.chunkType, err)
+	}
+	c.crc = binary.BigEndian.Uint32(crc)
+
+	return c, nil
+}
+
+// crcOffset はチャンクのCRCのファイル先頭からの位置を返す。
+func (c *chunk) crcOffset() int64 {
+	return c.offset + 8 + int64(c.length)
+}
+
+// isCritical はチャンクがクリティカルチャンクかどうかを返す。
+// チャンク名の1文字目が大文字ならクリティカル、小文字ならアンシラリー。
+func isCritical(chunkType string) bool {
+	return chunkType[0]&0x20 == 0
+}
+
+// validChunkName はチャンク名がASCIIの英字4文字かどうかを返す。
+func validChunkName(chunkType string) bool {
+	if len(chunkType) != 4 {
+		return false
+	}
+	for i := 0; i < len(chunkType); i++ {
+		c := chunkType[i] | 0x20
+		if c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// chunkRule はチャンクの出現回数と順序に関する制約
+type chunkRule struct {
+	multiple   bool // 複数回出現してよい
+	beforePLTE bool // PLTEより前に置く
+	afterPLTE  bool // PLTEより後に置く
+	beforeIDAT bool // IDATより前に置く
+}
+
+// chunkRules は既知のチャンクの制約(PNG仕様 5.6)
+var chunkRules = map[string]chunkRule{
+	"IHDR": {},
+	"PLTE": {beforeIDAT: true},
+	"IDAT": {multiple: true},
+	"IEND": {},
+	"cHRM": {beforePLTE: true, beforeIDAT: true},
+	"gAMA": {beforePLTE: true, beforeIDAT: true},
+	"iCCP": {beforePLTE: true, beforeIDAT: true},
+	"sBIT": {beforePLTE: true, beforeIDAT: true},
+	"sRGB": {beforePLTE: true, beforeIDAT: true},
+	"bKGD": {afterPLTE: true, beforeIDAT: true},
+	"hIST": {afterPLTE: true, beforeIDAT: true},
+	"tRNS": {afterPLTE: true, beforeIDAT: true},
+	"pHYs": {beforeIDAT: true},
+	"sPLT": {multiple: true, beforeIDAT: true},
+	"eXIf": {beforeIDAT: true},
+	"tIME": {},
+	"tEXt": {multiple: true},
+	"zTXt": {multiple: true},
+	"iTXt": {multiple: true},
+}
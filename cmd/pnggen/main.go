@@ -0,0 +1,27 @@
+// pnggen はgenパッケージを使って、すべてのカラータイプとビット深度の
+// 組み合わせのPNGをディレクトリに書き出す。
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/kouheiszk/png-reader/gen"
+)
+
+func main() {
+	dir := flag.String("o", ".", "output directory")
+	width := flag.Int("width", 33, "image width")
+	height := flag.Int("height", 17, "image height")
+	flag.Parse()
+
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := gen.WriteAll(*dir, *width, *height); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
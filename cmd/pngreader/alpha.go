@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/kouheiszk/png-reader/channel"
+)
+
+// alphaCommand はアルファチャンネルをグレースケールのマスクとして書き出し、
+// またはマスクを別の画像のアルファチャンネルとして差し込む。
+//
+//	alpha extract input.png mask.png         アルファを白(不透明)から黒(透明)のマスクにする
+//	alpha apply input.png mask.png out.png   inputの色とmaskの輝度をアルファにした画像を書き込む
+var alphaCommand = &command{
+	name:  "alpha",
+	usage: "alpha extract|apply [-fs dir|zip] input|URL mask.png [output.png]",
+}
+
+func init() {
+	alphaCommand.run = runAlpha
+}
+
+func runAlpha(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "usage: pngreader %s\n", alphaCommand.usage)
+		return flag.ErrHelp
+	}
+	op := args[0]
+	fs := newFlagSet(alphaCommand)
+	input := addInputFlag(fs)
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	switch op {
+	case "extract":
+		if fs.NArg() != 2 {
+			fs.Usage()
+			return flag.ErrHelp
+		}
+		img, err := decodeFile(input, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		return writeImageFile(fs.Arg(1), channel.Alpha(img), formatForPath(fs.Arg(1)))
+
+	case "apply":
+		if fs.NArg() != 3 {
+			fs.Usage()
+			return flag.ErrHelp
+		}
+		img, err := decodeFile(input, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		mask, err := decodeFile(input, fs.Arg(1))
+		if err != nil {
+			return err
+		}
+		result, err := channel.SetAlpha(img, mask)
+		if err != nil {
+			return err
+		}
+		return writeImageFile(fs.Arg(2), result, formatForPath(fs.Arg(2)))
+	}
+	return fmt.Errorf("unknown alpha operation %q (want extract or apply)", op)
+}
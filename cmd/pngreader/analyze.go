@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	pngreader "github.com/kouheiszk/png-reader"
+	"github.com/kouheiszk/png-reader/colors"
+	"github.com/kouheiszk/png-reader/deflate"
+)
+
+// analyzeCommand は画像を1つの観点で分析し、結果をJSONで出力する。
+//
+//	analyze colors input.png          異なる色の数と、パレットに変換できるかどうか、変換した場合のサイズ
+//	analyze filters [-rows] input.png 走査線ごとのフィルタタイプの集計と、圧縮が悪くなりそうな使い方の指摘
+//	analyze compression [-rows] input.png
+//	                                  IDATのDEFLATEのブロック構造、リテラルと一致の割合と、
+//	                                  圧縮し直した場合と最適化した場合のIDATの大きさ
+var analyzeCommand = &command{
+	name:  "analyze",
+	usage: "analyze colors|filters|compression [-rows] [-fs dir|zip] input|URL",
+}
+
+func init() {
+	analyzeCommand.run = runAnalyze
+}
+
+// colorReport はanalyze colorsの結果
+type colorReport struct {
+	*colors.Analysis
+
+	// MinimalSize はパレット以外で最小のカラータイプにした場合のPNGのサイズ、
+	// PaletteSize はパレットにした場合のサイズ、Savings はその差
+	MinimalSize int `json:"minimalSize"`
+	PaletteSize int `json:"paletteSize,omitempty"`
+	Savings     int `json:"paletteSavings,omitempty"`
+}
+
+// filterReport はanalyze filtersの結果
+type filterReport struct {
+	Rows   int            `json:"rows"`
+	Counts map[string]int `json:"counts"`
+	// PerRow は-rowsの場合の各走査線のフィルタタイプの名前
+	PerRow []string `json:"perRow,omitempty"`
+	// Notes は圧縮が悪くなりそうなフィルタの使い方
+	Notes []string `json:"notes,omitempty"`
+}
+
+// compressionReport はanalyze compressionの結果
+type compressionReport struct {
+	// Level はzlibヘッダが申告する圧縮レベル(0から3)
+	Level int `json:"level"`
+	// BlockCounts はブロックの種類ごとの数、Blocks は-rowsの場合の各ブロックの集計
+	BlockCounts map[string]int  `json:"blockCounts"`
+	Blocks      []deflate.Block `json:"blocks,omitempty"`
+
+	// RawSize はフィルタを適用した画像データの大きさ、IDATSize はIDATの合計
+	RawSize  int `json:"rawSize"`
+	IDATSize int `json:"idatSize"`
+	// BitsPerByte は画像データ1バイトあたりの圧縮後のビット数、Entropy は画像データのバイトのエントロピー
+	BitsPerByte float64 `json:"bitsPerByte"`
+	Entropy     float64 `json:"entropy"`
+	// LiteralRatio は画像データのうちリテラルで表されたバイトの割合、AverageMatch は一致の平均の長さ
+	LiteralRatio float64 `json:"literalRatio"`
+	AverageMatch float64 `json:"averageMatch"`
+
+	// RecompressedSize は同じフィルタのまま最高圧縮にした場合、
+	// OptimizedSize はvalidate -optimizeと同じ最適化をした場合のIDATの合計
+	RecompressedSize int `json:"recompressedIdatSize"`
+	OptimizedSize    int `json:"optimizedIdatSize"`
+	// Savings はOptimizedSizeにした場合に減るバイト数。増える場合は0
+	Savings int `json:"savings"`
+	// Worthwhile は最適化でIDATが5%以上小さくなるかどうか
+	Worthwhile bool `json:"worthwhile"`
+}
+
+func runAnalyze(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "usage: pngreader %s\n", analyzeCommand.usage)
+		return flag.ErrHelp
+	}
+	mode := args[0]
+	fs := newFlagSet(analyzeCommand)
+	rows := fs.Bool("rows", false, "filters, compression: also list every scanline or deflate block")
+	input := addInputFlag(fs)
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	var result interface{}
+	switch mode {
+	case "colors":
+		img, err := decodeFile(input, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		report := &colorReport{Analysis: colors.Analyze(img)}
+		minimal, err := minimalPNG(img, report.Analysis)
+		if err != nil {
+			return err
+		}
+		report.MinimalSize = len(minimal)
+		if report.FitsPalette {
+			indexed, err := palettePNG(img, report.Analysis)
+			if err != nil {
+				return err
+			}
+			report.PaletteSize = len(indexed)
+			report.Savings = report.MinimalSize - report.PaletteSize
+		}
+		result = report
+	case "filters":
+		f, err := input.open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		stats, err := new(pngreader.Decoder).DecodeStatsOptions(f, &pngreader.StatsOptions{SkipRows: !*rows})
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", fs.Arg(0), err)
+		}
+		result = newFilterReport(stats, *rows)
+	case "compression":
+		f, err := input.open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		report, err := newCompressionReport(data, *rows)
+		if err != nil {
+			return fmt.Errorf("%s: %w", fs.Arg(0), err)
+		}
+		result = report
+	default:
+		return fmt.Errorf("unknown analyze mode %q (want colors, filters or compression)", mode)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
+
+func newFilterReport(stats *pngreader.Stats, rows bool) *filterReport {
+	filters := stats.Filters
+	report := &filterReport{Rows: filters.Total(), Counts: make(map[string]int)}
+	for t, n := range filters.Counts {
+		report.Counts[pngreader.FilterNames[t]] = n
+	}
+	if rows {
+		report.PerRow = make([]string, len(filters.Rows))
+		for i, t := range filters.Rows {
+			report.PerRow[i] = pngreader.FilterNames[t]
+		}
+	}
+
+	// PNG仕様の推奨に従い、パレットと8ビット未満の画像はフィルタなし、それ以外は行ごとに選ぶのがよい
+	none := filters.Counts[0]
+	if stats.ColorType == pngreader.Indexed || stats.BitDepth < 8 {
+		if none < report.Rows {
+			report.Notes = append(report.Notes, fmt.Sprintf("%d of %d rows are filtered; indexed and low bit depth images usually compress best without filtering", report.Rows-none, report.Rows))
+		}
+	} else if none == report.Rows {
+		report.Notes = append(report.Notes, "no rows are filtered; truecolor and grayscale images usually compress better with adaptive filtering")
+	} else {
+		for t, n := range filters.Counts {
+			if n == report.Rows && report.Rows > 1 {
+				report.Notes = append(report.Notes, fmt.Sprintf("every row uses the %s filter; choosing a filter per row may compress better", pngreader.FilterNames[t]))
+			}
+		}
+	}
+	return report
+}
+
+func newCompressionReport(data []byte, rows bool) (*compressionReport, error) {
+	stats, err := new(pngreader.Decoder).DecodeStatsOptions(bytes.NewReader(data), &pngreader.StatsOptions{SkipRows: true})
+	if err != nil {
+		return nil, err
+	}
+	img, err := pngreader.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	optimized, err := optimizePNG(img, colors.Analyze(img))
+	if err != nil {
+		return nil, err
+	}
+	info, err := pngreader.DecodeInfo(bytes.NewReader(optimized))
+	if err != nil {
+		return nil, err
+	}
+
+	c := stats.Compression
+	report := &compressionReport{
+		Level:            c.Level,
+		BlockCounts:      make(map[string]int),
+		RawSize:          c.Size,
+		IDATSize:         c.CompressedSize,
+		Entropy:          c.Entropy,
+		RecompressedSize: stats.Recompressed,
+		OptimizedSize:    idatSize(info),
+	}
+	for _, b := range c.Blocks {
+		report.BlockCounts[b.Type.String()]++
+	}
+	if rows {
+		report.Blocks = c.Blocks
+	}
+	if c.Size > 0 {
+		report.BitsPerByte = float64(8*c.CompressedSize) / float64(c.Size)
+		report.LiteralRatio = float64(c.Literals) / float64(c.Size)
+	}
+	if c.Matches > 0 {
+		report.AverageMatch = float64(c.MatchBytes) / float64(c.Matches)
+	}
+	if saved := report.IDATSize - report.OptimizedSize; saved > 0 {
+		report.Savings = saved
+		report.Worthwhile = saved*20 >= report.IDATSize
+	}
+	return report, nil
+}
+
+// idatSize はIDATチャンクのデータの合計を返す。
+func idatSize(info *pngreader.Info) int {
+	n := 0
+	for _, c := range info.Chunks {
+		if c.Type == "IDAT" {
+			n += c.Length
+		}
+	}
+	return n
+}
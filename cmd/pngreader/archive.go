@@ -0,0 +1,267 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// archiveKind はアーカイブの形式
+type archiveKind int
+
+const (
+	notArchive archiveKind = iota
+	zipArchive
+	tarArchive
+	tarGzipArchive
+)
+
+// archiveKindForPath はファイル名の拡張子からアーカイブの形式を返す。
+func archiveKindForPath(name string) archiveKind {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return zipArchive
+	case strings.HasSuffix(name, ".tar"):
+		return tarArchive
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return tarGzipArchive
+	}
+	return notArchive
+}
+
+// archiveMember はアーカイブの1つのエントリ
+type archiveMember struct {
+	name    string
+	mode    fs.FileMode
+	modTime time.Time
+	// data はエントリの内容。ディレクトリの場合はnil。
+	// zipのシンボリックリンクはリンク先を内容として持つ
+	data []byte
+	// tarHeader はtarから読み込んだエントリの元のヘッダ。tarに書き込むときは
+	// これを写し、リンク先や所有者をそのまま残す
+	tarHeader *tar.Header
+}
+
+// isFile は通常のファイルかどうかを返す。tarのハードリンクはモードが
+// 通常のファイルと同じだが、内容を持たないので含めない。
+func (m *archiveMember) isFile() bool {
+	if m.tarHeader != nil {
+		return m.tarHeader.Typeflag == tar.TypeReg || m.tarHeader.Typeflag == tar.TypeRegA
+	}
+	return m.mode.IsRegular()
+}
+
+func (m *archiveMember) isPNG() bool {
+	return strings.EqualFold(path.Ext(m.name), ".png") || bytes.HasPrefix(m.data, []byte(pngSignature))
+}
+
+// walkArchive はrのアーカイブのエントリを先頭から1つずつ読み込み、fnを呼ぶ。
+// tarはストリームのまま読み、zipは中央ディレクトリが末尾にあるため
+// rがファイルでなければ全体をメモリに読み込む。maxSizeバイトを超えるエントリはエラーにする。
+func walkArchive(r io.Reader, kind archiveKind, maxSize int64, fn func(m *archiveMember) error) error {
+	switch kind {
+	case zipArchive:
+		return walkZip(r, maxSize, fn)
+	case tarGzipArchive:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	case tarArchive:
+	default:
+		return fmt.Errorf("not a zip or tar archive")
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		m := &archiveMember{name: header.Name, mode: header.FileInfo().Mode(), modTime: header.ModTime, tarHeader: header}
+		if header.Typeflag != tar.TypeDir {
+			if m.data, err = readMember(tr, header.Name, header.Size, maxSize); err != nil {
+				return err
+			}
+		}
+		if err := fn(m); err != nil {
+			return err
+		}
+	}
+}
+
+func walkZip(r io.Reader, maxSize int64, fn func(m *archiveMember) error) error {
+	var zr *zip.Reader
+	if f, ok := r.(*os.File); ok {
+		stat, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		if zr, err = zip.NewReader(f, stat.Size()); err != nil {
+			return err
+		}
+	} else {
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		if zr, err = zip.NewReader(bytes.NewReader(data), int64(len(data))); err != nil {
+			return err
+		}
+	}
+
+	for _, file := range zr.File {
+		m := &archiveMember{name: file.Name, mode: file.Mode(), modTime: file.Modified}
+		if !m.mode.IsDir() {
+			rc, err := file.Open()
+			if err != nil {
+				return fmt.Errorf("%s: %w", file.Name, err)
+			}
+			m.data, err = readMember(rc, file.Name, int64(file.UncompressedSize64), maxSize)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+		if err := fn(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readMember はエントリの内容を読み込む。sizeはヘッダが示す大きさで、
+// 偽ることもできるので、読んだ大きさもmaxSizeと比べる。
+func readMember(r io.Reader, name string, size, maxSize int64) ([]byte, error) {
+	if size > maxSize {
+		return nil, fmt.Errorf("%s: %d bytes exceeds limit of %d bytes", name, size, maxSize)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("%s: exceeds limit of %d bytes", name, maxSize)
+	}
+	return data, nil
+}
+
+// archiveWriter はエントリを1つずつアーカイブに書き込む。
+type archiveWriter interface {
+	add(m *archiveMember) error
+	Close() error
+}
+
+// newArchiveWriter はkindの形式でwに書き込むarchiveWriterを返す。
+func newArchiveWriter(w io.Writer, kind archiveKind) (archiveWriter, error) {
+	switch kind {
+	case zipArchive:
+		return &zipWriter{zip.NewWriter(w)}, nil
+	case tarArchive:
+		return &tarWriter{w: tar.NewWriter(w)}, nil
+	case tarGzipArchive:
+		gz := gzip.NewWriter(w)
+		return &tarWriter{w: tar.NewWriter(gz), gz: gz}, nil
+	}
+	return nil, fmt.Errorf("output must be a .zip, .tar, .tar.gz or .tgz file")
+}
+
+type zipWriter struct {
+	w *zip.Writer
+}
+
+func (z *zipWriter) add(m *archiveMember) error {
+	header := &zip.FileHeader{Name: m.name, Method: zip.Deflate, Modified: m.modTime}
+	header.SetMode(m.mode)
+	data := m.data
+	switch {
+	case m.mode.IsDir():
+		header.Method = zip.Store
+		if !strings.HasSuffix(header.Name, "/") {
+			header.Name += "/"
+		}
+	case m.tarHeader != nil && m.tarHeader.Typeflag == tar.TypeLink:
+		return fmt.Errorf("%s: zip archives cannot hold hard links", m.name)
+	case m.tarHeader != nil && m.tarHeader.Typeflag == tar.TypeSymlink:
+		// zipのシンボリックリンクはリンク先を内容に書く
+		header.Method = zip.Store
+		data = []byte(m.tarHeader.Linkname)
+	case m.isPNG():
+		// PNGはすでに圧縮されているので、もう一度圧縮しない
+		header.Method = zip.Store
+	}
+	w, err := z.w.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (z *zipWriter) Close() error {
+	return z.w.Close()
+}
+
+type tarWriter struct {
+	w  *tar.Writer
+	gz *gzip.Writer
+}
+
+func (t *tarWriter) add(m *archiveMember) error {
+	header := &tar.Header{
+		Name:     m.name,
+		Mode:     int64(m.mode.Perm()),
+		ModTime:  m.modTime,
+		Size:     int64(len(m.data)),
+		Typeflag: tar.TypeReg,
+	}
+	data := m.data
+	switch {
+	case m.tarHeader != nil:
+		// 内容は最適化で変わることがあるので、大きさだけ合わせる
+		h := *m.tarHeader
+		header = &h
+		header.Size = int64(len(m.data))
+	case m.mode.IsDir():
+		header.Typeflag = tar.TypeDir
+		header.Size = 0
+	case m.mode&fs.ModeSymlink != 0:
+		// zipのシンボリックリンクの内容はリンク先
+		header.Typeflag = tar.TypeSymlink
+		header.Linkname = string(m.data)
+		header.Size = 0
+		data = nil
+	case !m.mode.IsRegular():
+		return fmt.Errorf("%s: tar archives cannot hold %v entries from zip", m.name, m.mode.Type())
+	}
+	if err := t.w.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := t.w.Write(data)
+	return err
+}
+
+func (t *tarWriter) Close() error {
+	err := t.w.Close()
+	if t.gz != nil {
+		if gzErr := t.gz.Close(); err == nil {
+			err = gzErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kouheiszk/png-reader/channel"
+)
+
+// channelsCommand は画像をチャンネルごとのグレースケールのPNGに分け、また組み合わせる。
+// ゲームのテクスチャで、複数のマスクを1枚の画像のR、G、B、Aにまとめる用途を想定している。
+//
+//	channels split input.png [prefix]  prefix_r.png、prefix_g.png、prefix_b.png、prefix_a.pngを書き込む
+//	                                   prefixの省略時はinputから拡張子を除いたもの
+//	channels merge [-r r.png] [-g g.png] [-b b.png] [-a a.png] out.png
+//	                                   各チャンネルに指定した画像の輝度を使う。省略したR、G、Bは0、Aは不透明
+var channelsCommand = &command{
+	name:  "channels",
+	usage: "channels split|merge [-r file] [-g file] [-b file] [-a file] [-fs dir|zip] input|output [prefix]",
+}
+
+func init() {
+	channelsCommand.run = runChannels
+}
+
+func runChannels(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "usage: pngreader %s\n", channelsCommand.usage)
+		return flag.ErrHelp
+	}
+	op := args[0]
+	fs := newFlagSet(channelsCommand)
+	files := make([]*string, len(channel.Channels))
+	for i, c := range channel.Channels {
+		files[i] = fs.String(c.String(), "", fmt.Sprintf("merge: grayscale image for the %s channel", strings.ToUpper(c.String())))
+	}
+	input := addInputFlag(fs)
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	switch op {
+	case "split":
+		if fs.NArg() < 1 || fs.NArg() > 2 {
+			fs.Usage()
+			return flag.ErrHelp
+		}
+		prefix := strings.TrimSuffix(fs.Arg(0), filepath.Ext(fs.Arg(0)))
+		if fs.NArg() == 2 {
+			prefix = fs.Arg(1)
+		}
+		img, err := decodeFile(input, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		for i, plane := range channel.Split(img) {
+			name := fmt.Sprintf("%s_%s.png", prefix, channel.Channels[i])
+			if err := writeImageFile(name, plane, "png"); err != nil {
+				return err
+			}
+			fmt.Println(name)
+		}
+		return nil
+
+	case "merge":
+		if fs.NArg() != 1 {
+			fs.Usage()
+			return flag.ErrHelp
+		}
+		planes := make([]image.Image, len(channel.Channels))
+		for i, name := range files {
+			if *name == "" {
+				continue
+			}
+			img, err := decodeFile(input, *name)
+			if err != nil {
+				return err
+			}
+			planes[i] = img
+		}
+		img, err := channel.Merge(planes)
+		if err != nil {
+			return err
+		}
+		return writeImageFile(fs.Arg(0), img, formatForPath(fs.Arg(0)))
+	}
+	return fmt.Errorf("unknown channels operation %q (want split or merge)", op)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	pngreader "github.com/kouheiszk/png-reader"
+)
+
+// copyToClipboard はimgをPNGとしてOSのクリップボードに置く。
+// macOSではosascript、Windowsではpowershell、それ以外ではWaylandならwl-copy、
+// X11ならxclipを使う。
+func copyToClipboard(img image.Image) error {
+	var data bytes.Buffer
+	if err := pngreader.Encode(&data, img); err != nil {
+		return err
+	}
+
+	switch runtime.GOOS {
+	case "darwin":
+		return withTempPNG(data.Bytes(), func(path string) *exec.Cmd {
+			script := fmt.Sprintf(`set the clipboard to (read (POSIX file %q) as «class PNGf»)`, path)
+			return exec.Command("osascript", "-e", script)
+		})
+	case "windows":
+		return withTempPNG(data.Bytes(), func(path string) *exec.Cmd {
+			return exec.Command("powershell", "-NoProfile", "-STA", "-Command", powershellClipboardScript(path))
+		})
+	}
+
+	var cmd *exec.Cmd
+	switch {
+	case os.Getenv("WAYLAND_DISPLAY") != "":
+		cmd = exec.Command("wl-copy", "--type", "image/png")
+	case os.Getenv("DISPLAY") != "":
+		cmd = exec.Command("xclip", "-selection", "clipboard", "-t", "image/png", "-i")
+	default:
+		return fmt.Errorf("no clipboard available: neither WAYLAND_DISPLAY nor DISPLAY is set")
+	}
+	cmd.Stdin = &data
+	return runClipboard(cmd)
+}
+
+// powershellClipboardScript はpathの画像をクリップボードに置くPowerShellのスクリプトを返す。
+// pathは単一引用符の文字列に入れるので、PowerShellが単一引用符として扱う文字を2つ重ねてエスケープする。
+func powershellClipboardScript(path string) string {
+	quoted := powershellQuoteReplacer.Replace(path)
+	return `Add-Type -AssemblyName System.Windows.Forms, System.Drawing; ` +
+		`$image = [System.Drawing.Image]::FromFile('` + quoted + `'); ` +
+		`[System.Windows.Forms.Clipboard]::SetImage($image); $image.Dispose()`
+}
+
+// powershellQuoteReplacer はPowerShellが単一引用符として扱う'と‘’‚‛を2つ重ねる。
+var powershellQuoteReplacer = strings.NewReplacer("'", "''", "\u2018", "\u2018\u2018", "\u2019", "\u2019\u2019", "\u201a", "\u201a\u201a", "\u201b", "\u201b\u201b")
+
+// withTempPNG はdataを一時ファイルに書き込み、そのパスでnewCmdが作るコマンドを実行する。
+func withTempPNG(data []byte, newCmd func(path string) *exec.Cmd) error {
+	f, err := ioutil.TempFile("", "pngreader-*.png")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return runClipboard(newCmd(f.Name()))
+}
+
+// runClipboard はクリップボードのコマンドを実行し、失敗した場合は標準エラー出力を含めたエラーを返す。
+func runClipboard(cmd *exec.Cmd) error {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := bytes.TrimSpace(stderr.Bytes()); len(msg) > 0 {
+			return fmt.Errorf("copying to clipboard with %s: %v: %s", cmd.Path, err, msg)
+		}
+		return fmt.Errorf("copying to clipboard with %s: %v", cmd.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestPowershellClipboardScript は一時ファイルのパスに引用符が含まれても、
+// 単一引用符の文字列から抜け出さないことを確認する。
+func TestPowershellClipboardScript(t *testing.T) {
+	tests := []struct {
+		path, literal string
+	}{
+		{`C:\Temp\pngreader-1.png`, `'C:\Temp\pngreader-1.png'`},
+		{`C:\Users\O'Brien\a.png`, `'C:\Users\O''Brien\a.png'`},
+		{`C:\x'); Remove-Item C:\ -Recurse; ('.png`, `'C:\x''); Remove-Item C:\ -Recurse; (''.png'`},
+		{"C:\\Temp\\\u2018a\u2019.png", "'C:\\Temp\\\u2018\u2018a\u2019\u2019.png'"},
+	}
+	for _, tt := range tests {
+		script := powershellClipboardScript(tt.path)
+		if !strings.Contains(script, "::FromFile("+tt.literal+");") {
+			t.Errorf("%q: got script %s", tt.path, script)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	pngreader "github.com/kouheiszk/png-reader"
+	"github.com/kouheiszk/png-reader/adjust"
+	"github.com/kouheiszk/png-reader/internal/imageutil"
+)
+
+var convertCommand = &command{
+	name:  "convert",
+	usage: "convert [-strict] [-flatten] [-icc] [-auto-orient] [-black-point v] [-white-point v] [-gamma v] [-brightness v] [-contrast v] [-format name] [-to-clipboard] [-fs dir|zip] [input|URL [output]]",
+}
+
+func init() {
+	convertCommand.run = runConvert
+}
+
+func runConvert(args []string) error {
+	fs := newFlagSet(convertCommand)
+	strict := fs.Bool("strict", false, "treat every spec violation as an error")
+	flatten := fs.Bool("flatten", false, "composite onto the bKGD color (or white) and drop transparency")
+	applyICC := fs.Bool("icc", false, "convert pixels from the embedded ICC profile to sRGB")
+	autoOrient := fs.Bool("auto-orient", false, "rotate and flip pixels according to the EXIF Orientation tag")
+	adjustment := new(adjust.Options)
+	fs.Float64Var(&adjustment.BlackPoint, "black-point", 0, "levels: map this value (0-1) and below to black")
+	fs.Float64Var(&adjustment.WhitePoint, "white-point", 1, "levels: map this value (0-1) and above to white")
+	fs.Float64Var(&adjustment.Gamma, "gamma", 1, "raise values to the power 1/gamma; above 1 brightens midtones")
+	fs.Float64Var(&adjustment.Brightness, "brightness", 0, "add this value (-1 to 1) to every channel")
+	fs.Float64Var(&adjustment.Contrast, "contrast", 0, "scale values around mid-gray by 1+contrast (-1 to 1)")
+	format := fs.String("format", "", "output format ("+formatNames()+"); default from the output file extension")
+	toClipboard := fs.Bool("to-clipboard", false, "also copy the result to the system clipboard as PNG; without an output argument, only copy")
+	input := addInputFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	inputFilePath := filepath.Join("images", "lenna-interlace.png")
+	outputFilePath := "output.png"
+	if fs.NArg() > 0 {
+		inputFilePath = fs.Arg(0)
+	}
+	if fs.NArg() > 1 {
+		outputFilePath = fs.Arg(1)
+	}
+	if *format == "" {
+		*format = formatForPath(outputFilePath)
+	}
+	outputFormat, err := lookupFormat(*format)
+	if err != nil {
+		return err
+	}
+
+	inputFile, err := input.open(inputFilePath)
+	if err != nil {
+		return err
+	}
+	defer inputFile.Close()
+	// iCCPとeXIfを出力に引き継ぐため、入力を一度読み込んでチャンクも調べる
+	data, err := ioutil.ReadAll(inputFile)
+	if err != nil {
+		return err
+	}
+
+	decoder := &pngreader.Decoder{
+		Strict:     *strict,
+		ApplyICC:   *applyICC,
+		AutoOrient: *autoOrient,
+		Warn: func(err error) {
+			fmt.Fprintln(os.Stderr, "warning:", err)
+		},
+	}
+	img, err := decodeInput(bytes.NewReader(data), decoder, *flatten)
+	if err != nil {
+		return err
+	}
+	var info *pngreader.Info
+	if bytes.HasPrefix(data, []byte(pngSignature)) {
+		if info, err = new(pngreader.Decoder).DecodeInfo(bytes.NewReader(data)); err != nil {
+			return err
+		}
+	}
+	if !adjustment.IsZero() {
+		if img, err = adjust.Apply(img, adjustment); err != nil {
+			return err
+		}
+	}
+	bounds := img.Bounds()
+	fmt.Println("width:", bounds.Dx(), "height:", bounds.Dy())
+
+	if *toClipboard {
+		if err := copyToClipboard(img); err != nil {
+			return err
+		}
+		if fs.NArg() < 2 {
+			fmt.Println("Copied to clipboard")
+			return nil
+		}
+	}
+
+	outputFile, err := os.Create(outputFilePath)
+	if err != nil {
+		return err
+	}
+	defer outputFile.Close()
+
+	if outputFormat.Name == "png" {
+		err = pngEncoder(info, img, *applyICC, *autoOrient).Encode(outputFile, img)
+	} else {
+		err = encodeImage(outputFile, img, *format)
+	}
+	if err != nil {
+		return err
+	}
+	if err := writeSidecar(outputFilePath, img, *format); err != nil {
+		return err
+	}
+	fmt.Println("Complete")
+
+	return nil
+}
+
+// pngEncoder は入力のinfoからiCCPとeXIfを引き継ぐEncoderを返す。infoがnilの場合は何も引き継がない。
+// applyICCで画素をsRGBに変換した場合はプロファイルを埋め込まず、autoOrientで向きを直した場合は
+// EXIFのOrientationを1にする。
+func pngEncoder(info *pngreader.Info, img image.Image, applyICC, autoOrient bool) *pngreader.Encoder {
+	e := new(pngreader.Encoder)
+	if info == nil {
+		return e
+	}
+	if !applyICC && len(info.ICCProfile) > 0 {
+		e.ICCProfile, e.ICCProfileName = info.ICCProfile, info.ICCProfileName
+		// グレースケールのプロファイルはグレースケールのカラータイプでしか埋め込めない
+		if info.ColorType == pngreader.Grayscale || info.ColorType == pngreader.GrayscaleAlpha {
+			e.ColorType, e.BitDepth = pngreader.GrayscaleAlpha, 8
+			if imageutil.Is16Bit(img) {
+				e.BitDepth = 16
+			}
+		}
+	}
+	e.EXIF = info.EXIF
+	if autoOrient {
+		e.EXIF = pngreader.ResetOrientation(info.EXIF)
+	}
+	return e
+}
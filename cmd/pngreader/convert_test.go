@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pngreader "github.com/kouheiszk/png-reader"
+)
+
+// testProfile は色空間がspaceの、ヘッダとkTRCタグだけを持つICCプロファイルを返す。
+func testProfile(space string) []byte {
+	tag := []byte("curv\x00\x00\x00\x00\x00\x00\x00\x00")
+	p := make([]byte, 128+4+12)
+	binary.BigEndian.PutUint32(p[8:], 0x04000000)
+	copy(p[12:], "mntr")
+	copy(p[16:], space)
+	copy(p[20:], "XYZ ")
+	copy(p[36:], "acsp")
+	binary.BigEndian.PutUint32(p[128:], 1)
+	copy(p[132:], "kTRC")
+	binary.BigEndian.PutUint32(p[136:], uint32(len(p)))
+	binary.BigEndian.PutUint32(p[140:], uint32(len(tag)))
+	p = append(p, tag...)
+	binary.BigEndian.PutUint32(p[0:], uint32(len(p)))
+	return p
+}
+
+// writeTestPNG はmをencoderでdirのnameに書き込み、そのパスを返す。
+func writeTestPNG(t *testing.T, dir, name string, encoder *pngreader.Encoder, m image.Image) string {
+	t.Helper()
+	var b bytes.Buffer
+	if err := encoder.Encode(&b, m); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// convertInfo はinputをargsを付けてPNGに変換し、出力のInfoを返す。
+func convertInfo(t *testing.T, input string, args ...string) *pngreader.Info {
+	t.Helper()
+	output := filepath.Join(filepath.Dir(input), "output.png")
+	if err := runConvert(append(args, input, output)); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	info, err := (&pngreader.Decoder{Strict: true}).DecodeInfo(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
+
+// TestConvertKeepsICCProfile はconvertが入力のiCCPを同じバイト列のまま出力に埋め込むことを確認する。
+func TestConvertKeepsICCProfile(t *testing.T) {
+	gray := image.NewGray(image.Rect(0, 0, 3, 2))
+	gray.SetGray(1, 1, color.Gray{200})
+	rgba := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	rgba.SetNRGBA(2, 0, color.NRGBA{10, 20, 30, 128})
+
+	tests := []struct {
+		name    string
+		encoder *pngreader.Encoder
+		m       image.Image
+	}{
+		{"gray", &pngreader.Encoder{ColorType: pngreader.Grayscale, BitDepth: 8, ICCProfile: testProfile("GRAY"), ICCProfileName: "Linear gray"}, gray},
+		{"rgb", &pngreader.Encoder{ICCProfile: testProfile("RGB "), ICCProfileName: "Linear RGB"}, rgba},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := writeTestPNG(t, t.TempDir(), "input.png", tt.encoder, tt.m)
+			info := convertInfo(t, input)
+			if info.ICCProfileName != tt.encoder.ICCProfileName {
+				t.Errorf("profile name %q, want %q", info.ICCProfileName, tt.encoder.ICCProfileName)
+			}
+			if !bytes.Equal(info.ICCProfile, tt.encoder.ICCProfile) {
+				t.Errorf("profile is %d bytes and differs from the %d byte input", len(info.ICCProfile), len(tt.encoder.ICCProfile))
+			}
+		})
+	}
+}
+
+// TestConvertApplyICCDropsProfile は-iccで画素をsRGBに変換した場合にプロファイルを埋め込まないことを確認する。
+func TestConvertApplyICCDropsProfile(t *testing.T) {
+	encoder := &pngreader.Encoder{ColorType: pngreader.Grayscale, BitDepth: 8, ICCProfile: testProfile("GRAY")}
+	input := writeTestPNG(t, t.TempDir(), "input.png", encoder, image.NewGray(image.Rect(0, 0, 2, 2)))
+	if info := convertInfo(t, input, "-icc"); info.ICCProfile != nil {
+		t.Errorf("got a %d byte profile, want none", len(info.ICCProfile))
+	}
+}
+
+// testEXIF はOrientationタグだけを持つビッグエンディアンのEXIFを返す。
+func testEXIF(orientation int) []byte {
+	exif := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01\x01\x12\x00\x03\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00\x00\x00")
+	binary.BigEndian.PutUint16(exif[18:], uint16(orientation))
+	return exif
+}
+
+// TestConvertOrientation は-auto-orientで向きを直した出力のOrientationを1にし、
+// 向きを直さない場合はEXIFをそのまま引き継ぐことを確認する。
+func TestConvertOrientation(t *testing.T) {
+	encoder := &pngreader.Encoder{EXIF: testEXIF(6)}
+	input := writeTestPNG(t, t.TempDir(), "input.png", encoder, image.NewNRGBA(image.Rect(0, 0, 3, 2)))
+
+	info := convertInfo(t, input, "-auto-orient")
+	if info.Width != 2 || info.Height != 3 {
+		t.Errorf("auto-orient: size %dx%d, want 2x3", info.Width, info.Height)
+	}
+	if info.Orientation != 1 || !bytes.Equal(info.EXIF, testEXIF(1)) {
+		t.Errorf("auto-orient: orientation %d, want 1", info.Orientation)
+	}
+
+	info = convertInfo(t, input)
+	if info.Width != 3 || info.Height != 2 {
+		t.Errorf("size %dx%d, want 3x2", info.Width, info.Height)
+	}
+	if info.Orientation != 6 || !bytes.Equal(info.EXIF, testEXIF(6)) {
+		t.Errorf("orientation %d, want 6", info.Orientation)
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	pngreader "github.com/kouheiszk/png-reader"
+	"github.com/kouheiszk/png-reader/compare"
+	"github.com/kouheiszk/png-reader/phash"
+)
+
+// dedupeCommand はディレクトリ以下のPNGをデコードし、画素が同じ画像をまとめて表示する。
+// 圧縮の方法やメタデータ、ビット深度だけが異なるファイルも重複として見つかる。
+// -phashを指定すると、pHashのハミング距離がその値以下の画像も同じグループにする。
+// -linkを指定すると、画素が同じファイルをグループの先頭のファイルへのハードリンクに置き換える。
+// 引数にはディレクトリのほか、ファイルやURLも指定でき、-fsのディレクトリやzipの中も調べられる。
+// ただし-linkはローカルのファイルにしか使えない。
+//
+// グループごとに、先頭に残すファイルを、続けて重複したファイルを字下げして出力する。
+var dedupeCommand = &command{
+	name:  "dedupe",
+	usage: "dedupe [-phash distance] [-link] [-fs dir|zip] dir|file|URL...",
+}
+
+func init() {
+	dedupeCommand.run = runDedupe
+}
+
+// dedupeFile は重複を調べる1つのファイル
+type dedupeFile struct {
+	path   string
+	digest [32]byte
+	hash   phash.Hash
+	// group は画素が同じファイルのグループの先頭の添字
+	group int
+}
+
+func runDedupe(args []string) error {
+	fs := newFlagSet(dedupeCommand)
+	distance := fs.Int("phash", -1, "also group images whose pHash differs by at most this many bits (0-64)")
+	link := fs.Bool("link", false, "replace pixel-identical duplicates with hard links to the first file of each group")
+	input := addInputFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	if *distance > 64 {
+		return fmt.Errorf("-phash must be at most 64")
+	}
+	if *link {
+		// ハードリンクはローカルのファイルにしか作れない
+		if input.root != "" {
+			return fmt.Errorf("-link cannot be used with -fs")
+		}
+		for _, root := range fs.Args() {
+			if isRemote(root) {
+				return fmt.Errorf("-link cannot be used with URL %s", root)
+			}
+		}
+	}
+
+	files, err := readDedupeFiles(input, fs.Args(), *distance >= 0)
+	if err != nil {
+		return err
+	}
+	duplicates, linked := 0, 0
+	for _, members := range groupDuplicates(files, *distance) {
+		fmt.Println(files[members[0]].path)
+		for _, i := range members[1:] {
+			f := files[i]
+			duplicates++
+			if f.group != i {
+				fmt.Printf("  %s\n", f.path)
+			} else {
+				fmt.Printf("  %s (similar)\n", f.path)
+			}
+			if *link && f.group != i {
+				if err := hardLink(files[f.group].path, f.path); err != nil {
+					return err
+				}
+				linked++
+			}
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d files, %d duplicates", len(files), duplicates)
+	if *link {
+		fmt.Fprintf(os.Stderr, ", %d linked", linked)
+	}
+	fmt.Fprintln(os.Stderr)
+	return nil
+}
+
+// readDedupeFiles はrootsの下のPNGを読み込み、パスの順に返す。
+// 引数で直接指定したファイルは拡張子によらず読み込む。デコードできないファイルは警告して飛ばす。
+func readDedupeFiles(input *inputFlag, roots []string, withHash bool) ([]*dedupeFile, error) {
+	var files []*dedupeFile
+	for _, root := range roots {
+		err := input.walk(root, func(path string) error {
+			if path != root && !strings.EqualFold(filepath.Ext(path), ".png") {
+				return nil
+			}
+			f, err := readDedupeFile(input, path, withHash)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "warning:", err)
+				return nil
+			}
+			files = append(files, f)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+	return files, nil
+}
+
+// groupDuplicates は画素が同じファイルをまとめ、distanceが0以上ならpHashの距離が
+// それ以下のグループ同士をさらにつなぐ。2つ以上のファイルを持つグループを先頭のファイルの順に返す。
+// 各ファイルのgroupには、画素が同じファイルのうち先頭の添字を設定する。
+func groupDuplicates(files []*dedupeFile, distance int) [][]int {
+	parent := make([]int, len(files))
+	find := func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+	union := func(i, j int) {
+		if i, j = find(i), find(j); i != j {
+			if j < i {
+				i, j = j, i
+			}
+			parent[j] = i
+		}
+	}
+	first := make(map[[32]byte]int)
+	for i, f := range files {
+		parent[i] = i
+		if g, ok := first[f.digest]; ok {
+			f.group = g
+			union(g, i)
+		} else {
+			f.group = i
+			first[f.digest] = i
+		}
+	}
+	if distance >= 0 {
+		for i := range files {
+			for j := i + 1; j < len(files); j++ {
+				if files[i].group == i && files[j].group == j && phash.Distance(files[i].hash, files[j].hash) <= distance {
+					union(i, j)
+				}
+			}
+		}
+	}
+
+	groups := make(map[int][]int)
+	var roots []int
+	for i := range files {
+		r := find(i)
+		if len(groups[r]) == 0 {
+			roots = append(roots, r)
+		}
+		groups[r] = append(groups[r], i)
+	}
+	var result [][]int
+	for _, r := range roots {
+		if len(groups[r]) >= 2 {
+			result = append(result, groups[r])
+		}
+	}
+	return result
+}
+
+func readDedupeFile(input *inputFlag, path string, withHash bool) (*dedupeFile, error) {
+	f, err := input.open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := pngreader.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	d := &dedupeFile{path: path, digest: compare.Digest(img)}
+	if withHash {
+		d.hash = phash.PHash(img)
+	}
+	return d, nil
+}
+
+// hardLink はdupをtargetへのハードリンクに置き換える。すでに同じファイルの場合は何もしない。
+func hardLink(target, dup string) error {
+	ts, err := os.Stat(target)
+	if err != nil {
+		return err
+	}
+	ds, err := os.Stat(dup)
+	if err != nil {
+		return err
+	}
+	if os.SameFile(ts, ds) {
+		return nil
+	}
+	// 途中で失敗しても元のファイルが残るよう、一時的な名前でリンクしてから置き換える
+	tmp := dup + ".dedupe-tmp"
+	if err := os.Link(target, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dup); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
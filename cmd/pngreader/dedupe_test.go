@@ -0,0 +1,155 @@
+package main
+
+import (
+	"archive/zip"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	pngreader "github.com/kouheiszk/png-reader"
+)
+
+// writeDedupeFiles はdirに、画素が同じで符号化の異なるa.pngとsub/b.png、
+// 少し明るいc.png、まったく違うd.png、PNGでないファイルを書き込む。
+func writeDedupeFiles(t *testing.T, dir string) {
+	t.Helper()
+	base := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+	other := image.NewNRGBA(base.Rect)
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			v := uint8(128 + 60*math.Sin(float64(x)*0.3) + 40*math.Cos(float64(y)*0.2) + 20*math.Sin(float64(x+y)*0.5))
+			base.SetNRGBA(x, y, color.NRGBA{v, v, 0x40, 0xff})
+			other.SetNRGBA(x, y, color.NRGBA{uint8((x ^ y) & 1 * 0xff), 0, uint8(y * 8), 0xff})
+		}
+	}
+	// 少し明るくした画像は画素が違うが、pHashは近い
+	similar := image.NewNRGBA(base.Rect)
+	for i, v := range base.Pix {
+		similar.Pix[i] = v
+		if i%4 != 3 && v < 0xff {
+			similar.Pix[i] = v + 1
+		}
+	}
+
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestPNG(t, dir, "a.png", new(pngreader.Encoder), base)
+	writeTestPNG(t, dir, "sub/b.png", &pngreader.Encoder{Interlace: true, CompressionLevel: pngreader.BestCompression}, base)
+	writeTestPNG(t, dir, "c.png", new(pngreader.Encoder), similar)
+	writeTestPNG(t, dir, "d.png", new(pngreader.Encoder), other)
+	for name, data := range map[string]string{"notes.txt": "not an image", "broken.png": "not a PNG"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// dedupeGroups はrootsのPNGをまとめ、グループごとのパスを返す。
+func dedupeGroups(t *testing.T, input *inputFlag, roots []string, distance int) [][]string {
+	t.Helper()
+	files, err := readDedupeFiles(input, roots, distance >= 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var groups [][]string
+	for _, members := range groupDuplicates(files, distance) {
+		var paths []string
+		for _, i := range members {
+			paths = append(paths, files[i].path)
+		}
+		groups = append(groups, paths)
+	}
+	return groups
+}
+
+func TestDedupeGroups(t *testing.T) {
+	dir := t.TempDir()
+	writeDedupeFiles(t, dir)
+	a, b, c := filepath.Join(dir, "a.png"), filepath.Join(dir, "sub", "b.png"), filepath.Join(dir, "c.png")
+
+	if got, want := dedupeGroups(t, &inputFlag{}, []string{dir}, -1), [][]string{{a, b}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("identical: got %v, want %v", got, want)
+	}
+	if got, want := dedupeGroups(t, &inputFlag{}, []string{dir}, 4), [][]string{{a, c, b}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("similar: got %v, want %v", got, want)
+	}
+	// 引数で指定したファイルは拡張子がなくても読み込む
+	noExt := filepath.Join(dir, "copy")
+	data, err := os.ReadFile(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(noExt, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := dedupeGroups(t, &inputFlag{}, []string{b, noExt}, -1), [][]string{{noExt, b}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("files: got %v, want %v", got, want)
+	}
+}
+
+// TestDedupeFS は-fsのzipの中のPNGも同じようにまとめることを確認する。
+func TestDedupeFS(t *testing.T) {
+	dir := t.TempDir()
+	writeDedupeFiles(t, dir)
+	archive := filepath.Join(t.TempDir(), "images.zip")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for _, name := range []string{"a.png", "sub/b.png", "c.png", "d.png", "notes.txt", "broken.png"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	input := &inputFlag{root: archive}
+	for _, root := range []string{".", "/"} {
+		if got, want := dedupeGroups(t, input, []string{root}, -1), [][]string{{"a.png", "sub/b.png"}}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: got %v, want %v", root, got, want)
+		}
+	}
+	if err := runDedupe([]string{"-link", "-fs", archive, "."}); err == nil {
+		t.Error("-link accepted with -fs")
+	}
+}
+
+func TestDedupeLink(t *testing.T) {
+	dir := t.TempDir()
+	writeDedupeFiles(t, dir)
+	if err := runDedupe([]string{"-link", "-phash", "4", dir}); err != nil {
+		t.Fatal(err)
+	}
+	stat := func(name string) os.FileInfo {
+		s, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	if !os.SameFile(stat("a.png"), stat("sub/b.png")) {
+		t.Error("identical file not linked")
+	}
+	// 似ているだけの画像はリンクしない
+	if os.SameFile(stat("a.png"), stat("c.png")) {
+		t.Error("similar file linked")
+	}
+	if err := runDedupe([]string{"-link", "https://example.com/a.png"}); err == nil {
+		t.Error("-link accepted with a URL")
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/kouheiszk/png-reader/compare"
+)
+
+// diffCommand は同じ大きさの2つの画像を比べ、PSNR、SSIM、異なる画素の数を表示する。
+// -min-psnrや-min-ssimを下回った場合はエラーで終了するので、変換後の画質の確認に使える。
+// -oを指定すると、異なる画素を赤で、同じ画素を薄いグレーで示した画像を書き込む。
+var diffCommand = &command{
+	name:  "diff",
+	usage: "diff [-min-psnr dB] [-min-ssim value] [-o diff.png] [-fs dir|zip] a|URL b|URL",
+}
+
+func init() {
+	diffCommand.run = runDiff
+}
+
+func runDiff(args []string) error {
+	fs := newFlagSet(diffCommand)
+	minPSNR := fs.Float64("min-psnr", 0, "fail if the PSNR is below this many dB")
+	minSSIM := fs.Float64("min-ssim", 0, "fail if the SSIM is below this value")
+	output := fs.String("o", "", "write an image highlighting the differing pixels to this file")
+	input := addInputFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	a, err := decodeFile(input, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	b, err := decodeFile(input, fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	result, err := compare.Compare(a, b)
+	if err != nil {
+		return fmt.Errorf("%w: %dx%d and %dx%d", err, a.Bounds().Dx(), a.Bounds().Dy(), b.Bounds().Dx(), b.Bounds().Dy())
+	}
+
+	if math.IsInf(result.PSNR, 1) {
+		fmt.Println("psnr: inf")
+	} else {
+		fmt.Printf("psnr: %.2f dB\n", result.PSNR)
+	}
+	fmt.Printf("ssim: %.4f\n", result.SSIM)
+	fmt.Printf("differing pixels: %d of %d\n", result.Differing, result.Pixels)
+
+	if *output != "" {
+		if err := writeImageFile(*output, diffImage(a, b), formatForPath(*output)); err != nil {
+			return err
+		}
+	}
+
+	if result.PSNR < *minPSNR {
+		return fmt.Errorf("PSNR %.2f dB is below %.2f dB", result.PSNR, *minPSNR)
+	}
+	if result.SSIM < *minSSIM {
+		return fmt.Errorf("SSIM %.4f is below %.4f", result.SSIM, *minSSIM)
+	}
+	return nil
+}
+
+// diffImage はaとbで異なる画素を差の大きさに応じた赤で、同じ画素をaの輝度を薄めたグレーで描いた画像を返す。
+func diffImage(a, b image.Image) *image.NRGBA {
+	ab, bb := a.Bounds(), b.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, ab.Dx(), ab.Dy()))
+	for y := 0; y < ab.Dy(); y++ {
+		for x := 0; x < ab.Dx(); x++ {
+			ca := color.NRGBA64Model.Convert(a.At(ab.Min.X+x, ab.Min.Y+y)).(color.NRGBA64)
+			cb := color.NRGBA64Model.Convert(b.At(bb.Min.X+x, bb.Min.Y+y)).(color.NRGBA64)
+			if ca == cb {
+				gray := color.GrayModel.Convert(ca).(color.Gray).Y
+				dst.SetNRGBA(x, y, color.NRGBA{0xc0 + gray/4, 0xc0 + gray/4, 0xc0 + gray/4, 0xff})
+				continue
+			}
+			d := maxDelta(ca.R, cb.R)
+			for _, v := range [][2]uint16{{ca.G, cb.G}, {ca.B, cb.B}, {ca.A, cb.A}} {
+				if dv := maxDelta(v[0], v[1]); dv > d {
+					d = dv
+				}
+			}
+			// 小さな差も見えるよう、最も弱い赤でも半分の明るさにする
+			dst.SetNRGBA(x, y, color.NRGBA{uint8(0x80 + d>>9), 0, 0, 0xff})
+		}
+	}
+	return dst
+}
+
+func maxDelta(a, b uint16) uint16 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
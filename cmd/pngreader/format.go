@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	pngreader "github.com/kouheiszk/png-reader"
+	"github.com/kouheiszk/png-reader/colors"
+	"github.com/kouheiszk/png-reader/farbfeld"
+	"github.com/kouheiszk/png-reader/internal/imageutil"
+	"github.com/kouheiszk/png-reader/output"
+	"github.com/kouheiszk/png-reader/pnm"
+	"github.com/kouheiszk/png-reader/qoi"
+	"github.com/kouheiszk/png-reader/raw"
+)
+
+// 組み込みの形式を登録する。最初に登録したPNGを既定の形式にする。
+// 他のパッケージが登録した形式も、ブランクインポートすればconvertやserveで使える。
+func init() {
+	register := func(names []string, contentType string, encoder output.Encoder) {
+		output.Register(output.Format{Name: names[0], Aliases: names[1:], ContentType: contentType, Encoder: encoder})
+	}
+	register([]string{"png"}, "image/png", output.EncoderFunc((&pngreader.Encoder{}).Encode))
+	register([]string{"jpeg", "jpg"}, "image/jpeg", output.EncoderFunc(func(w io.Writer, img image.Image) error {
+		return jpeg.Encode(w, img, nil)
+	}))
+	register([]string{"pnm"}, "image/x-portable-anymap", output.EncoderFunc(pnm.Encode))
+	register([]string{"pgm"}, "image/x-portable-graymap", &pnm.Encoder{Format: pnm.PGM})
+	register([]string{"ppm"}, "image/x-portable-pixmap", &pnm.Encoder{Format: pnm.PPM})
+	register([]string{"pam"}, "image/x-portable-arbitrarymap", &pnm.Encoder{Format: pnm.PAM})
+	register([]string{"farbfeld", "ff"}, "image/x-farbfeld", output.EncoderFunc(farbfeld.Encode))
+	register([]string{"qoi"}, "image/qoi", output.EncoderFunc(qoi.Encode))
+	register([]string{"datauri"}, "text/plain", output.EncoderFunc(encodeDataURI))
+	register([]string{"raw", "rgba"}, "application/octet-stream", rawEncoder{&raw.Encoder{Layout: raw.RGBA}})
+	register([]string{"bgra"}, "application/octet-stream", rawEncoder{&raw.Encoder{Layout: raw.BGRA}})
+	register([]string{"rgb"}, "application/octet-stream", rawEncoder{&raw.Encoder{Layout: raw.RGB}})
+	register([]string{"planar"}, "application/octet-stream", rawEncoder{&raw.Encoder{Layout: raw.Planar}})
+}
+
+// rawEncoder はピクセル列を書き出し、サイドカーに構造を記録する。
+type rawEncoder struct {
+	*raw.Encoder
+}
+
+func (e rawEncoder) Sidecar(img image.Image) (interface{}, error) {
+	return e.Geometry(img)
+}
+
+// encodeDataURI はimgを最適化したPNGにエンコードし、"data:image/png;base64,"で始まる
+// data URIとして書き込む。HTMLやCSSに埋め込む小さな画像向け。
+func encodeDataURI(w io.Writer, img image.Image) error {
+	if _, err := io.WriteString(w, "data:image/png;base64,"); err != nil {
+		return err
+	}
+	b64 := base64.NewEncoder(base64.StdEncoding, w)
+	if err := encodeOptimizedPNG(b64, img); err != nil {
+		return err
+	}
+	return b64.Close()
+}
+
+// encodeOptimizedPNG はimgを表現できる最小のカラータイプ(パレットの方が小さければパレット)を選び、
+// 最大圧縮でPNGにエンコードする。
+func encodeOptimizedPNG(w io.Writer, img image.Image) error {
+	data, err := optimizePNG(img, colors.Analyze(img))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// optimizePNG はanalysisをもとにimgをエンコードし、パレットで表せる場合は
+// minimalPNGとpalettePNGのうち小さい方を返す。
+func optimizePNG(img image.Image, analysis *colors.Analysis) ([]byte, error) {
+	data, err := minimalPNG(img, analysis)
+	if err != nil || !analysis.FitsPalette {
+		return data, err
+	}
+	indexed, err := palettePNG(img, analysis)
+	if err != nil {
+		return nil, err
+	}
+	if len(indexed) < len(data) {
+		return indexed, nil
+	}
+	return data, nil
+}
+
+// minimalPNG はパレット以外で最小のカラータイプを選び、最大圧縮でimgをエンコードする。
+func minimalPNG(img image.Image, analysis *colors.Analysis) ([]byte, error) {
+	enc := &pngreader.Encoder{
+		ColorType:        pngreader.TruecolorAlpha,
+		BitDepth:         8,
+		CompressionLevel: pngreader.BestCompression,
+	}
+	if imageutil.Is16Bit(img) {
+		enc.BitDepth = 16
+	}
+	switch opaque, gray := analysis.Opaque, analysis.Gray; {
+	case opaque && gray:
+		enc.ColorType = pngreader.Grayscale
+	case gray:
+		enc.ColorType = pngreader.GrayscaleAlpha
+	case opaque:
+		enc.ColorType = pngreader.Truecolor
+	}
+	var data bytes.Buffer
+	if err := enc.Encode(&data, img); err != nil {
+		return nil, err
+	}
+	return data.Bytes(), nil
+}
+
+// palettePNG はanalysis.FitsPaletteの画像を、足りる最小のビット深度のパレットと最大圧縮でエンコードする。
+func palettePNG(img image.Image, analysis *colors.Analysis) ([]byte, error) {
+	enc := &pngreader.Encoder{
+		ColorType:        pngreader.Indexed,
+		BitDepth:         analysis.PaletteBitDepth,
+		CompressionLevel: pngreader.BestCompression,
+	}
+	var data bytes.Buffer
+	if err := enc.Encode(&data, analysis.Paletted(img)); err != nil {
+		return nil, err
+	}
+	return data.Bytes(), nil
+}
+
+// formatNames は出力できる形式の正式名を","で区切って返す。
+func formatNames() string {
+	var names []string
+	for _, f := range output.Formats() {
+		names = append(names, f.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// lookupFormat は名前か別名がnameの形式を返す。nameが空の場合はPNGを返す。
+func lookupFormat(name string) (output.Format, error) {
+	if name == "" {
+		name = "png"
+	}
+	f, ok := output.Lookup(name)
+	if !ok {
+		return output.Format{}, fmt.Errorf("unsupported output format %q", name)
+	}
+	return f, nil
+}
+
+// formatForPath はファイルの拡張子から形式名を返す。該当する形式がない場合はPNGにする。
+func formatForPath(path string) string {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	if f, ok := output.Lookup(ext); ok {
+		return f.Name
+	}
+	return "png"
+}
+
+// encodeImage はimgをformatの形式でwに書き込む。formatが空の場合はPNGにする。
+func encodeImage(w io.Writer, img image.Image, format string) error {
+	f, err := lookupFormat(format)
+	if err != nil {
+		return err
+	}
+	return f.Encoder.Encode(w, img)
+}
+
+// writeSidecar はformatの形式がサイドカーを持つ場合、outputPathに".json"を付けたファイルに書き込む。
+func writeSidecar(outputPath string, img image.Image, format string) error {
+	f, err := lookupFormat(format)
+	if err != nil {
+		return err
+	}
+	encoder, ok := f.Encoder.(output.SidecarEncoder)
+	if !ok {
+		return nil
+	}
+	v, err := encoder.Sidecar(img)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(outputPath+".json", append(data, '\n'), 0644)
+}
+
+// contentType はformatの形式のMIMEタイプを返す。
+func contentType(format string) string {
+	if f, err := lookupFormat(format); err == nil {
+		return f.ContentType
+	}
+	return "application/octet-stream"
+}
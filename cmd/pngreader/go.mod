@@ -0,0 +1,24 @@
+module github.com/kouheiszk/png-reader/cmd/pngreader
+
+go 1.21
+
+require (
+	github.com/kouheiszk/png-reader v0.0.0-00010101000000-000000000000
+	github.com/kouheiszk/png-reader/pngreaderpb v0.0.0-00010101000000-000000000000
+	golang.org/x/image v0.18.0
+	google.golang.org/grpc v1.65.0
+	google.golang.org/protobuf v1.34.2
+)
+
+require (
+	golang.org/x/crypto v0.23.0 // indirect
+	golang.org/x/net v0.25.0 // indirect
+	golang.org/x/sys v0.20.0 // indirect
+	golang.org/x/text v0.16.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240528184218-531527333157 // indirect
+)
+
+replace (
+	github.com/kouheiszk/png-reader => ../../
+	github.com/kouheiszk/png-reader/pngreaderpb => ../../pngreaderpb
+)
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"strings"
+
+	pngreader "github.com/kouheiszk/png-reader"
+	"github.com/kouheiszk/png-reader/phash"
+)
+
+// hashCommand は画像の知覚ハッシュを表示する。sha256sumと同様に1ファイル1行で
+// "ハッシュ  ファイル名"を出力する。-compareを指定すると、2つの画像のハッシュの
+// ハミング距離を表示する。
+var hashCommand = &command{
+	name:  "hash",
+	usage: "hash [-algorithm ahash|dhash|phash|all] [-compare] [-fs dir|zip] input|URL...",
+}
+
+func init() {
+	hashCommand.run = runHash
+}
+
+func runHash(args []string) error {
+	fs := newFlagSet(hashCommand)
+	algorithm := fs.String("algorithm", string(phash.Perceptual), "hash algorithm (ahash, dhash, phash, or all)")
+	compare := fs.Bool("compare", false, "print the Hamming distance between the hashes of exactly two inputs")
+	input := addInputFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 || *compare && fs.NArg() != 2 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	algorithms := phash.Algorithms
+	if *algorithm != "all" {
+		algorithms = nil
+		for _, a := range phash.Algorithms {
+			if string(a) == *algorithm {
+				algorithms = []phash.Algorithm{a}
+			}
+		}
+		if algorithms == nil {
+			return fmt.Errorf("unknown hash algorithm %q", *algorithm)
+		}
+	}
+
+	var hashes [][]phash.Hash
+	for _, name := range fs.Args() {
+		img, err := decodeFile(input, name)
+		if err != nil {
+			return err
+		}
+		var hs []phash.Hash
+		var fields []string
+		for _, a := range algorithms {
+			h, err := phash.Compute(img, a)
+			if err != nil {
+				return err
+			}
+			hs = append(hs, h)
+			if len(algorithms) > 1 {
+				fields = append(fields, fmt.Sprintf("%s:%s", a, h))
+			} else {
+				fields = append(fields, h.String())
+			}
+		}
+		hashes = append(hashes, hs)
+		if !*compare {
+			fmt.Printf("%s  %s\n", strings.Join(fields, " "), name)
+		}
+	}
+
+	if *compare {
+		var fields []string
+		for i, a := range algorithms {
+			d := phash.Distance(hashes[0][i], hashes[1][i])
+			if len(algorithms) > 1 {
+				fields = append(fields, fmt.Sprintf("%s:%d", a, d))
+			} else {
+				fields = append(fields, fmt.Sprint(d))
+			}
+		}
+		fmt.Println(strings.Join(fields, " "))
+	}
+	return nil
+}
+
+// decodeFile はnameの画像を開いてデコードする。
+func decodeFile(input *inputFlag, name string) (image.Image, error) {
+	f, err := input.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := decodeInput(f, &pngreader.Decoder{}, false)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return img, nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"flag"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	pngreader "github.com/kouheiszk/png-reader"
+	_ "github.com/kouheiszk/png-reader/farbfeld"
+	_ "github.com/kouheiszk/png-reader/mng"
+	_ "github.com/kouheiszk/png-reader/qoi"
+)
+
+// inputFlag はサブコマンドの入力ファイルを読み込むファイルシステムを指定する-fsフラグ
+type inputFlag struct {
+	root string
+}
+
+func addInputFlag(flags *flag.FlagSet) *inputFlag {
+	f := new(inputFlag)
+	flags.StringVar(&f.root, "fs", "", "read input files from this directory or zip archive")
+	return f
+}
+
+// open は入力ファイルを開く。nameがhttp://、https://、s3://のURLの場合はダウンロードする。
+// -fsが指定されている場合、URL以外のnameはその中のパスになる。
+func (f *inputFlag) open(name string) (io.ReadCloser, error) {
+	if isRemote(name) {
+		return openRemote(name)
+	}
+	if f.root == "" {
+		return os.Open(name)
+	}
+	fsys, closer, err := openFS(f.root)
+	if err != nil {
+		return nil, err
+	}
+	file, err := fsys.Open(strings.TrimPrefix(name, "/"))
+	if err != nil {
+		closer.Close()
+		return nil, err
+	}
+	return &fsFile{File: file, closer: closer}, nil
+}
+
+// walk はnameがディレクトリならその下の通常のファイルを、ファイルかURLならname自身を
+// 順にfnに渡す。-fsが指定されている場合、URL以外のnameはその中のパスになる。
+// fnに渡した名前はopenで開ける。
+func (f *inputFlag) walk(name string, fn func(name string) error) error {
+	if isRemote(name) {
+		return fn(name)
+	}
+	visit := func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		return fn(path)
+	}
+	if f.root == "" {
+		return filepath.WalkDir(name, visit)
+	}
+	fsys, closer, err := openFS(f.root)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+	if name = strings.Trim(name, "/"); name == "" {
+		name = "."
+	}
+	return fs.WalkDir(fsys, name, visit)
+}
+
+// openFS はディレクトリかzipアーカイブをfs.FSとして開く。
+func openFS(root string) (fs.FS, io.Closer, error) {
+	stat, err := os.Stat(root)
+	if err != nil {
+		return nil, nil, err
+	}
+	if stat.IsDir() {
+		return os.DirFS(root), io.NopCloser(nil), nil
+	}
+	r, err := zip.OpenReader(root)
+	if err != nil {
+		return nil, nil, err
+	}
+	return r, r, nil
+}
+
+// fsFile は閉じるときにファイルシステムも閉じるファイル
+type fsFile struct {
+	fs.File
+	closer io.Closer
+}
+
+func (f *fsFile) Close() error {
+	err := f.File.Close()
+	if closeErr := f.closer.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// pngSignature はPNGファイルの先頭8バイト
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// decodeInput はrの画像をデコードする。PNGはdecoderでデコードし、それ以外の形式
+// (JPEG、GIF、farbfeld、QOI、MNGの最初のフレーム、JNG、ximageタグ付きのビルドではBMP、TIFF、WebP)は
+// シグネチャから判別してimage.Decodeでデコードする。
+// flattenが真の場合、bKGDの色(なければ白)の上に合成する。
+func decodeInput(r io.Reader, decoder *pngreader.Decoder, flatten bool) (image.Image, error) {
+	br := bufio.NewReader(r)
+	if signature, _ := br.Peek(len(pngSignature)); string(signature) == pngSignature {
+		if flatten {
+			return decoder.DecodeFlatten(br, color.White)
+		}
+		return decoder.Decode(br)
+	}
+
+	img, _, err := image.Decode(br)
+	if err == image.ErrFormat {
+		// どの形式とも判別できない入力はPNGとして扱い、シグネチャのエラーを報告する
+		return decoder.Decode(br)
+	}
+	if err != nil || !flatten {
+		return img, err
+	}
+	return pngreader.Flatten(img, color.White), nil
+}
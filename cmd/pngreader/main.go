@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// command はpngreaderのサブコマンド
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []*command{
+	convertCommand,
+	reportCommand,
+	validateCommand,
+	showCommand,
+	hashCommand,
+	diffCommand,
+	alphaCommand,
+	channelsCommand,
+	analyzeCommand,
+	dedupeCommand,
+	sliceCommand,
+	packCommand,
+	generateCommand,
+	stegoCommand,
+	serveCommand,
+	serveGRPCCommand,
+	workerCommand,
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: pngreader <command> [arguments]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "commands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %s\n", c.usage)
+	}
+}
+
+func main() {
+	args := os.Args[1:]
+	// 引数がない場合は従来どおりサンプル画像を変換する
+	if len(args) == 0 {
+		args = []string{convertCommand.name}
+	}
+
+	for _, c := range commands {
+		if c.name != args[0] {
+			continue
+		}
+		if err := c.run(args[1:]); err != nil {
+			if err != flag.ErrHelp {
+				fmt.Fprintln(os.Stderr, err)
+			}
+			os.Exit(1)
+		}
+		return
+	}
+
+	usage()
+	os.Exit(2)
+}
+
+// newFlagSet はサブコマンド用のFlagSetを作成する。
+func newFlagSet(c *command) *flag.FlagSet {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: pngreader %s\n", c.usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
@@ -0,0 +1,55 @@
+//go:build !ximage
+// +build !ximage
+
+package main
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"io"
+)
+
+// ximageタグなしのビルドでは、BMPの入力を判別してタグが必要なことを伝え、
+// showの縮小には画素の平均を使う。
+func init() {
+	image.RegisterFormat("bmp", "BM????\x00\x00\x00\x00", decodeBMP, decodeBMPConfig)
+}
+
+var errBMP = errors.New("BMP input requires a build with the ximage tag")
+
+func decodeBMP(io.Reader) (image.Image, error) {
+	return nil, errBMP
+}
+
+func decodeBMPConfig(io.Reader) (image.Config, error) {
+	return image.Config{}, errBMP
+}
+
+// scale はsrcをdstの大きさに縮小する。dstの各ピクセルは、対応するsrcの範囲の
+// 乗算済みの色の平均にする。
+func scale(dst *image.NRGBA, src image.Image) {
+	sb, db := src.Bounds(), dst.Bounds()
+	span := func(i, n, size, min int) (int, int) {
+		lo, hi := min+i*size/n, min+(i+1)*size/n
+		if hi == lo {
+			hi++
+		}
+		return lo, hi
+	}
+	for y := 0; y < db.Dy(); y++ {
+		y0, y1 := span(y, db.Dy(), sb.Dy(), sb.Min.Y)
+		for x := 0; x < db.Dx(); x++ {
+			x0, x1 := span(x, db.Dx(), sb.Dx(), sb.Min.X)
+			var r, g, b, a uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+				}
+			}
+			n := uint64((y1 - y0) * (x1 - x0))
+			dst.Set(db.Min.X+x, db.Min.Y+y, color.RGBA64{uint16(r / n), uint16(g / n), uint16(b / n), uint16(a / n)})
+		}
+	}
+}
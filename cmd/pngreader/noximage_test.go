@@ -0,0 +1,35 @@
+//go:build !ximage
+// +build !ximage
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestConvertBMPWithoutTag はタグなしのビルドでBMPの入力がPNGの解析エラーではなく、
+// タグが必要なことを伝えるエラーになることを確認する。
+func TestConvertBMPWithoutTag(t *testing.T) {
+	// 1x1、24ビットのBMP
+	bmp := make([]byte, 58)
+	copy(bmp, "BM")
+	binary.LittleEndian.PutUint32(bmp[2:], uint32(len(bmp)))
+	binary.LittleEndian.PutUint32(bmp[10:], 54)
+	binary.LittleEndian.PutUint32(bmp[14:], 40)
+	binary.LittleEndian.PutUint32(bmp[18:], 1)
+	binary.LittleEndian.PutUint32(bmp[22:], 1)
+	binary.LittleEndian.PutUint16(bmp[26:], 1)
+	binary.LittleEndian.PutUint16(bmp[28:], 24)
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.bmp")
+	if err := os.WriteFile(input, bmp, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runConvert([]string{input, filepath.Join(dir, "out.png")}); !errors.Is(err, errBMP) {
+		t.Errorf("got %v, want %v", err, errBMP)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kouheiszk/png-reader/atlas"
+)
+
+// packCommand は複数の画像を1枚のスプライトシートに並べ、各画像の位置をJSONで書き込む。
+// sliceの逆の操作で、JSONはTexturePacker形式なのでslice -atlasでそのまま切り分けられる。
+//
+//	pack sheet.png a.png b.png sprites/  sheet.pngとsheet.jsonを書き込む
+//
+// ディレクトリを指定すると、その下のPNGをディレクトリからの相対パスの名前で並べる。
+// ファイルはファイル名がフレームの名前になる。
+var packCommand = &command{
+	name:  "pack",
+	usage: "pack [-max-width n] [-padding n] [-pot] [-manifest file] output input...",
+}
+
+func init() {
+	packCommand.run = runPack
+}
+
+// atlasMeta はアトラスのシートの情報
+type atlasMeta struct {
+	Image string `json:"image"`
+	Size  struct {
+		W int `json:"w"`
+		H int `json:"h"`
+	} `json:"size"`
+}
+
+// packManifest はpackが書き込むJSON
+type packManifest struct {
+	Frames []*atlasFrame `json:"frames"`
+	Meta   atlasMeta     `json:"meta"`
+}
+
+func runPack(args []string) error {
+	fs := newFlagSet(packCommand)
+	maxWidth := fs.Int("max-width", 0, "maximum sheet width (0 chooses a roughly square sheet)")
+	padding := fs.Int("padding", 0, "pixels between images")
+	pot := fs.Bool("pot", false, "round the sheet size up to powers of two")
+	manifest := fs.String("manifest", "", "output JSON path (default: output with a .json extension)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	output := fs.Arg(0)
+	if *manifest == "" {
+		*manifest = strings.TrimSuffix(output, filepath.Ext(output)) + ".json"
+	}
+
+	var names []string
+	var images []image.Image
+	seen := make(map[string]string)
+	add := func(name, path string) error {
+		if prev, ok := seen[name]; ok {
+			return fmt.Errorf("%s and %s have the same frame name %q", prev, path, name)
+		}
+		seen[name] = path
+		img, err := decodeFile(new(inputFlag), path)
+		if err != nil {
+			return err
+		}
+		names = append(names, name)
+		images = append(images, img)
+		return nil
+	}
+	for _, arg := range fs.Args()[1:] {
+		stat, err := os.Stat(arg)
+		if err != nil {
+			return err
+		}
+		if !stat.IsDir() {
+			if err := add(filepath.Base(arg), arg); err != nil {
+				return err
+			}
+			continue
+		}
+		err = filepath.WalkDir(arg, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !strings.EqualFold(filepath.Ext(path), ".png") {
+				return nil
+			}
+			rel, err := filepath.Rel(arg, path)
+			if err != nil {
+				return err
+			}
+			return add(filepath.ToSlash(rel), path)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	sizes := make([]image.Point, len(images))
+	for i, img := range images {
+		sizes[i] = img.Bounds().Size()
+	}
+	rects, size, err := atlas.Pack(sizes, atlas.Options{MaxWidth: *maxWidth, Padding: *padding, PowerOfTwo: *pot})
+	if err != nil {
+		return err
+	}
+	if err := writeImageFile(output, atlas.Draw(images, rects, size), formatForPath(output)); err != nil {
+		return err
+	}
+
+	m := &packManifest{Frames: make([]*atlasFrame, len(rects))}
+	for i, r := range rects {
+		m.Frames[i] = &atlasFrame{Filename: names[i], Frame: atlasRect{X: r.Min.X, Y: r.Min.Y, W: r.Dx(), H: r.Dy()}}
+	}
+	m.Meta.Image = filepath.Base(output)
+	m.Meta.Size.W, m.Meta.Size.H = size.X, size.Y
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(*manifest, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%d images packed into %dx%d\n", len(images), size.X, size.Y)
+	return nil
+}
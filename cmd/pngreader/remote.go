@@ -0,0 +1,174 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// isRemote はnameがopenRemoteで開けるURLかどうかを返す。
+func isRemote(name string) bool {
+	for _, scheme := range []string{"http://", "https://", "s3://"} {
+		if strings.HasPrefix(name, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteClient はURLの入力をダウンロードするクライアント。応答が止まった接続先で待ち続けないよう、
+// ボディの読み込みまでを含めた時間を制限する。
+var remoteClient = &http.Client{Timeout: 5 * time.Minute}
+
+// maxRemoteSize はURLの入力としてダウンロードするボディの最大のバイト数
+const maxRemoteSize = 256 << 20
+
+// openRemote はHTTP(S)かS3互換ストレージのURLを開く。ボディはダウンロードしながら読み込める。
+// ボディがmaxRemoteSizeを超える場合は、超えた時点で読み込みがエラーになる。
+func openRemote(rawURL string) (io.ReadCloser, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if u.Scheme == "s3" {
+		req, err = s3Request(u)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := remoteClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %s", rawURL, resp.Status)
+	}
+	if resp.ContentLength > maxRemoteSize {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: response of %d bytes exceeds %d bytes", rawURL, resp.ContentLength, maxRemoteSize)
+	}
+	return &limitedBody{ReadCloser: resp.Body, url: rawURL, limit: maxRemoteSize, remaining: maxRemoteSize}, nil
+}
+
+// limitedBody はlimitバイトを超えて読もうとするとエラーを返すボディ。
+// io.LimitReaderと違い、超えた分を黙って切り捨てない。
+type limitedBody struct {
+	io.ReadCloser
+	url              string
+	limit, remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	// 上限ちょうどで終わるボディと超えるボディを区別するため、1バイト多く読む
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		return int(b.remaining), fmt.Errorf("%s: response exceeds %d bytes", b.url, b.limit)
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// s3Request はs3://bucket/keyのオブジェクトを取得するリクエストを作成する。
+// 接続先と認証情報はAWS CLIと同じ環境変数から読み込む。
+//
+//	AWS_ENDPOINT_URL       S3互換ストレージのエンドポイント。指定した場合はパス形式でアクセスする
+//	AWS_REGION             リージョン(既定はus-east-1)
+//	AWS_ACCESS_KEY_ID      アクセスキー。指定しない場合は署名せずにアクセスする
+//	AWS_SECRET_ACCESS_KEY  シークレットキー
+//	AWS_SESSION_TOKEN      一時的な認証情報のセッショントークン
+func s3Request(u *url.URL) (*http.Request, error) {
+	bucket, key := u.Host, strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || key == "" {
+		return nil, fmt.Errorf("%s: expected s3://bucket/key", u)
+	}
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	endpoint := "https://" + bucket + ".s3." + region + ".amazonaws.com/" + s3Escape(key)
+	if e := os.Getenv("AWS_ENDPOINT_URL"); e != "" {
+		endpoint = strings.TrimSuffix(e, "/") + "/" + bucket + "/" + s3Escape(key)
+	}
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	accessKey := os.Getenv("AWS_ACCESS_KEY_ID")
+	if accessKey != "" {
+		signV4(req, region, accessKey, os.Getenv("AWS_SECRET_ACCESS_KEY"), os.Getenv("AWS_SESSION_TOKEN"), time.Now())
+	}
+	return req, nil
+}
+
+// s3Escape はオブジェクトキーを、区切りの/を残してURLエンコードする。
+func s3Escape(key string) string {
+	parts := strings.Split(key, "/")
+	for i, p := range parts {
+		parts[i] = strings.Replace(url.QueryEscape(p), "+", "%20", -1)
+	}
+	return strings.Join(parts, "/")
+}
+
+// emptySHA256 はボディのないリクエストのペイロードのハッシュ
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// signV4 はボディのないreqにAWS署名バージョン4の署名を付ける。
+func signV4(req *http.Request, region, accessKey, secretKey, sessionToken string, now time.Time) {
+	now = now.UTC()
+	date := now.Format("20060102")
+	timestamp := now.Format("20060102T150405Z")
+
+	req.Header.Set("x-amz-date", timestamp)
+	req.Header.Set("x-amz-content-sha256", emptySHA256)
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + emptySHA256 + "\n" +
+		"x-amz-date:" + timestamp + "\n"
+	if sessionToken != "" {
+		req.Header.Set("x-amz-security-token", sessionToken)
+		signedHeaders += ";x-amz-security-token"
+		canonicalHeaders += "x-amz-security-token:" + sessionToken + "\n"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		emptySHA256,
+	}, "\n")
+	scope := date + "/" + region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + timestamp + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOpenRemote(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/image.png":
+			io.WriteString(w, "png data")
+		case "/huge.png":
+			// 宣言した大きさだけで拒否し、ボディは読まない
+			w.Header().Set("Content-Length", "999999999999")
+			w.WriteHeader(http.StatusOK)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	body, err := openRemote(server.URL + "/image.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(body)
+	body.Close()
+	if err != nil || string(data) != "png data" {
+		t.Errorf("got %q, %v", data, err)
+	}
+
+	if _, err := openRemote(server.URL + "/missing.png"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("missing: got %v, want a 404 error", err)
+	}
+	if _, err := openRemote(server.URL + "/huge.png"); err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Errorf("huge: got %v, want a size error", err)
+	}
+}
+
+// TestLimitedBody は上限ちょうどのボディは読み切れ、超えるボディは切り捨てずにエラーにすることを確認する。
+func TestLimitedBody(t *testing.T) {
+	for _, tt := range []struct {
+		body string
+		ok   bool
+	}{
+		{"1234", true},
+		{"12345", false},
+	} {
+		body := &limitedBody{ReadCloser: ioutil.NopCloser(strings.NewReader(tt.body)), url: "test", limit: 4, remaining: 4}
+		data, err := ioutil.ReadAll(body)
+		if (err == nil) != tt.ok {
+			t.Errorf("%q: got error %v", tt.body, err)
+		}
+		if string(data) != "1234" {
+			t.Errorf("%q: read %q, want %q", tt.body, data, "1234")
+		}
+	}
+}
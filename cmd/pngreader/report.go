@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"google.golang.org/protobuf/proto"
+
+	pngreader "github.com/kouheiszk/png-reader"
+	pb "github.com/kouheiszk/png-reader/pngreaderpb"
+)
+
+var reportCommand = &command{
+	name:  "report",
+	usage: "report [-o report.json] [-format json|protobuf] [-fs dir|zip] input.png|URL",
+}
+
+func init() {
+	reportCommand.run = runReport
+}
+
+func runReport(args []string) error {
+	fs := newFlagSet(reportCommand)
+	output := fs.String("o", "", "write the report to this file instead of stdout")
+	format := fs.String("format", "json", "report format (json, or protobuf for the Report message of pngreader.proto)")
+	input := addInputFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	if *format != "json" && *format != "protobuf" {
+		return fmt.Errorf("unknown report format %q", *format)
+	}
+
+	inputFile, err := input.open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer inputFile.Close()
+
+	report, err := pngreader.ConformanceReport(inputFile)
+	if err != nil {
+		return err
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		out, err = os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+	if *format == "protobuf" {
+		data, err := proto.Marshal(pb.FromReport(report))
+		if err != nil {
+			return err
+		}
+		if _, err := out.Write(data); err != nil {
+			return err
+		}
+	} else {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	}
+
+	if !report.Conformant {
+		return fmt.Errorf("%s: not conformant", fs.Arg(0))
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	pngreader "github.com/kouheiszk/png-reader"
+	pb "github.com/kouheiszk/png-reader/pngreaderpb"
+)
+
+// serveCommand はHTTPのAPIを提供する。いずれもリクエストボディにPNGを送る。
+//
+//	POST /v1/info                      ヘッダとチャンク構成をJSONで返す(pngreader.Info)
+//	POST /v1/validate                  仕様適合性の検証結果をJSONで返す(pngreader.Report)
+//	POST /v1/convert?format=png|jpeg   デコードして指定した形式で返す。strict=1でStrictにする
+//
+// infoとvalidateは、Acceptにapplication/x-protobufを含めるとpngreader.protoの
+// InfoとReportのバイナリで返す。
+//
+// エラーは{"error": "..."}の形で、次のステータスを返す。
+//
+//	400  PNGの形式の違反、CRCの不一致、未知の出力形式
+//	405  POST以外のメソッド
+//	413  ボディが-max-sizeを超えた、画像が-max-pixelsを超えた、またはデコードの制限を超えた
+//	501  未対応のPNGの機能
+//	503  -timeout以内に処理が終わらなかった。デコードもその時点で中断する
+var serveCommand = &command{
+	name:  "serve",
+	usage: "serve [-addr :8080] [-max-size bytes] [-max-pixels n] [-timeout duration]",
+}
+
+func init() {
+	serveCommand.run = runServe
+}
+
+func runServe(args []string) error {
+	fs := newFlagSet(serveCommand)
+	addr := fs.String("addr", ":8080", "listen address")
+	maxSize := fs.Int64("max-size", 32<<20, "maximum size of a request body in bytes")
+	maxPixels := fs.Int("max-pixels", defaultMaxPixels, "maximum width×height of an input PNG")
+	timeout := fs.Duration("timeout", 30*time.Second, "maximum time to handle a request")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	api := &httpAPI{maxSize: *maxSize, limits: serverLimits(*maxPixels)}
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           http.TimeoutHandler(api.handler(), *timeout, `{"error": "request timed out"}`),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       *timeout,
+		WriteTimeout:      *timeout + 5*time.Second,
+	}
+
+	// 割り込まれたら処理中のリクエストを終えてから停止する
+	done := make(chan struct{})
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		server.Shutdown(context.Background())
+		close(done)
+	}()
+
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	<-done
+	return nil
+}
+
+// httpAPI はserveコマンドのハンドラ
+type httpAPI struct {
+	maxSize int64
+	limits  pngreader.Limits
+}
+
+// decoder はサーバーの制限を設定したDecoderを返す。
+// TimeoutHandlerは期限を過ぎるとリクエストのContextを終了するので、デコードにはr.Context()を渡す。
+func (a *httpAPI) decoder(strict bool) *pngreader.Decoder {
+	return &pngreader.Decoder{Strict: strict, Limits: a.limits}
+}
+
+// handler はAPIのエンドポイントを登録したハンドラを返す。
+func (a *httpAPI) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/info", a.post(a.info))
+	mux.HandleFunc("/v1/validate", a.post(a.validate))
+	mux.HandleFunc("/v1/convert", a.post(a.convert))
+	return mux
+}
+
+// post はPOSTのみを受け付け、ボディをmaxSizeまで読み込んでhandlerに渡す。
+func (a *httpAPI) post(handler func(w http.ResponseWriter, r *http.Request, body []byte)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, a.maxSize))
+		if err != nil {
+			writeError(w, http.StatusRequestEntityTooLarge, err)
+			return
+		}
+		handler(w, r, body)
+	}
+}
+
+func (a *httpAPI) info(w http.ResponseWriter, r *http.Request, body []byte) {
+	info, err := a.decoder(false).DecodeInfo(bytes.NewReader(body))
+	if err != nil {
+		writeError(w, httpStatus(err), err)
+		return
+	}
+	writeMetadata(w, r, info, pb.FromInfo(info))
+}
+
+func (a *httpAPI) validate(w http.ResponseWriter, r *http.Request, body []byte) {
+	report, err := a.decoder(false).ConformanceReportContext(r.Context(), bytes.NewReader(body))
+	if err != nil {
+		writeError(w, httpStatus(err), err)
+		return
+	}
+	writeMetadata(w, r, report, pb.FromReport(report))
+}
+
+func (a *httpAPI) convert(w http.ResponseWriter, r *http.Request, body []byte) {
+	format := r.URL.Query().Get("format")
+	img, err := a.decoder(r.URL.Query().Get("strict") == "1").DecodeContext(r.Context(), bytes.NewReader(body))
+	if err != nil {
+		writeError(w, httpStatus(err), err)
+		return
+	}
+	var output bytes.Buffer
+	if err := encodeImage(&output, img, format); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	w.Header().Set("Content-Type", contentType(format))
+	output.WriteTo(w)
+}
+
+// httpStatus はデコードエラーを分類に応じたHTTPのステータスに変換する。
+func httpStatus(err error) int {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, pngreader.ErrLimit):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, pngreader.ErrUnsupported):
+		return http.StatusNotImplemented
+	case errors.Is(err, pngreader.ErrFormat), errors.Is(err, pngreader.ErrIntegrity):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// protobufType はメタデータをprotobufで返すときのContent-Type
+const protobufType = "application/x-protobuf"
+
+// writeMetadata はAcceptがprotobufを求めている場合はmを、それ以外はvをJSONで返す。
+func writeMetadata(w http.ResponseWriter, r *http.Request, v interface{}, m proto.Message) {
+	if !strings.Contains(r.Header.Get("Accept"), protobufType) {
+		writeJSON(w, http.StatusOK, v)
+		return
+	}
+	data, err := proto.Marshal(m)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", protobufType)
+	w.Write(data)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
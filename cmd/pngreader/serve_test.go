@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestHTTPAPI() http.Handler {
+	return (&httpAPI{maxSize: 1 << 20, limits: serverLimits(defaultMaxPixels)}).handler()
+}
+
+// TestServeHugeImage は、小さなファイルで巨大な大きさを宣言したPNGを、画素のメモリを
+// 確保せずに413で拒否することを確認する。
+func TestServeHugeImage(t *testing.T) {
+	handler := newTestHTTPAPI()
+	for _, path := range []string{"/v1/convert?format=png", "/v1/info"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, bytes.NewReader(hugePNG())))
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s: got status %d, want 413: %s", path, rec.Code, rec.Body)
+		}
+	}
+}
+
+// TestServeCanceled は、リクエストのContextが終了したときにデコードを中断することを確認する。
+func TestServeCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodPost, "/v1/validate", bytes.NewReader(hugePNG())).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	newTestHTTPAPI().ServeHTTP(rec, req)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("got status %d, want 503: %s", rec.Code, rec.Body)
+	}
+}
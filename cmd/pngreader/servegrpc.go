@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pngreader "github.com/kouheiszk/png-reader"
+	pb "github.com/kouheiszk/png-reader/pngreaderpb"
+)
+
+var serveGRPCCommand = &command{
+	name:  "serve-grpc",
+	usage: "serve-grpc [-addr :50051] [-max-size bytes] [-max-pixels n] [-timeout duration]",
+}
+
+func init() {
+	serveGRPCCommand.run = runServeGRPC
+}
+
+// convertChunkSize はConvertの応答1メッセージあたりのデータの大きさ
+const convertChunkSize = 64 * 1024
+
+// defaultMaxPixels はサーバーがデコードする画像の画素数の既定の上限
+const defaultMaxPixels = 64 << 20
+
+// serverLimits はサーバーがデコードに使う制限。小さなファイルでもIHDRの大きさだけ
+// 画素のメモリを確保するので、画素数を必ず制限する。
+func serverLimits(maxPixels int) pngreader.Limits {
+	return pngreader.Limits{
+		MaxPixels:    maxPixels,
+		MaxChunks:    1 << 16,
+		MaxTextBytes: 1 << 20,
+	}
+}
+
+func runServeGRPC(args []string) error {
+	fs := newFlagSet(serveGRPCCommand)
+	addr := fs.String("addr", ":50051", "listen address")
+	maxSize := fs.Int("max-size", 32<<20, "maximum size of an input PNG in bytes")
+	maxPixels := fs.Int("max-pixels", defaultMaxPixels, "maximum width×height of an input PNG")
+	timeout := fs.Duration("timeout", 30*time.Second, "maximum time to handle a request")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	server := grpc.NewServer(grpc.MaxRecvMsgSize(*maxSize + 1024))
+	pb.RegisterPNGReaderServer(server, &grpcServer{maxSize: *maxSize, limits: serverLimits(*maxPixels), timeout: *timeout})
+
+	// 割り込まれたら処理中のリクエストを終えてから停止する
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		server.GracefulStop()
+	}()
+
+	return server.Serve(listener)
+}
+
+// grpcServer はPNGReaderサービスの実装
+type grpcServer struct {
+	pb.UnimplementedPNGReaderServer
+
+	maxSize int
+	limits  pngreader.Limits
+	// timeout はクライアントの期限とは別に、1つのリクエストの処理に許す時間
+	timeout time.Duration
+}
+
+// decoder はサーバーの制限を設定したDecoderを返す。
+func (s *grpcServer) decoder(strict bool) *pngreader.Decoder {
+	return &pngreader.Decoder{Strict: strict, Limits: s.limits}
+}
+
+func (s *grpcServer) DecodeInfo(ctx context.Context, req *pb.DecodeInfoRequest) (*pb.Info, error) {
+	info, err := s.decoder(false).DecodeInfo(bytes.NewReader(req.Data))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return pb.FromInfo(info), nil
+}
+
+func (s *grpcServer) Validate(ctx context.Context, req *pb.ValidateRequest) (*pb.Report, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	report, err := s.decoder(false).ConformanceReportContext(ctx, bytes.NewReader(req.Data))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return pb.FromReport(report), nil
+}
+
+func (s *grpcServer) Convert(stream pb.PNGReader_ConvertServer) error {
+	var (
+		first *pb.ConvertRequest
+		input bytes.Buffer
+	)
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if first == nil {
+			first = req
+		}
+		if input.Len()+len(req.Data) > s.maxSize {
+			return status.Errorf(codes.ResourceExhausted, "input exceeds %d bytes", s.maxSize)
+		}
+		input.Write(req.Data)
+	}
+	if first == nil {
+		return status.Error(codes.InvalidArgument, "no input")
+	}
+
+	ctx, cancel := context.WithTimeout(stream.Context(), s.timeout)
+	defer cancel()
+	img, err := s.decoder(first.Strict).DecodeContext(ctx, &input)
+	if err != nil {
+		return grpcError(err)
+	}
+	var output bytes.Buffer
+	if err := encodeImage(&output, img, first.Format); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	for output.Len() > 0 {
+		if err := stream.Send(&pb.ConvertResponse{Data: output.Next(convertChunkSize)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// grpcError はデコードエラーを分類に応じたgRPCのステータスに変換する。
+func grpcError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return status.FromContextError(err).Err()
+	}
+	code := codes.Internal
+	switch {
+	case errors.Is(err, pngreader.ErrLimit):
+		code = codes.ResourceExhausted
+	case errors.Is(err, pngreader.ErrUnsupported):
+		code = codes.Unimplemented
+	case errors.Is(err, pngreader.ErrFormat), errors.Is(err, pngreader.ErrIntegrity):
+		code = codes.InvalidArgument
+	}
+	return status.Error(code, err.Error())
+}
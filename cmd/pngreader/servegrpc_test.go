@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/kouheiszk/png-reader/pngreaderpb"
+)
+
+// hugePNG はIHDRで805306373x5の大きさを宣言する、60バイトほどのグレースケールのPNGを返す。
+// 制限なしでデコードすると、画素のために数GBを確保しようとする。
+func hugePNG() []byte {
+	var idat bytes.Buffer
+	zw := zlib.NewWriter(&idat)
+	zw.Close()
+
+	var b bytes.Buffer
+	b.WriteString("\x89PNG\r\n\x1a\n")
+	chunk := func(chunkType string, data []byte) {
+		binary.Write(&b, binary.BigEndian, uint32(len(data)))
+		b.WriteString(chunkType)
+		b.Write(data)
+		binary.Write(&b, binary.BigEndian, crc32.ChecksumIEEE(append([]byte(chunkType), data...)))
+	}
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], 805306373)
+	binary.BigEndian.PutUint32(ihdr[4:], 5)
+	ihdr[8] = 8
+	chunk("IHDR", ihdr)
+	chunk("IDAT", idat.Bytes())
+	chunk("IEND", nil)
+	return b.Bytes()
+}
+
+func newTestGRPCServer() *grpcServer {
+	return &grpcServer{maxSize: 1 << 20, limits: serverLimits(defaultMaxPixels), timeout: time.Minute}
+}
+
+func TestGRPCValidateHugeImage(t *testing.T) {
+	report, err := newTestGRPCServer().Validate(context.Background(), &pb.ValidateRequest{Data: hugePNG()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Conformant || !strings.Contains(report.Error, "limit") {
+		t.Errorf("got conformant=%v error=%q, want a limit error", report.Conformant, report.Error)
+	}
+}
+
+func TestGRPCValidateCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := newTestGRPCServer().Validate(ctx, &pb.ValidateRequest{Data: hugePNG()})
+	if status.Code(err) != codes.Canceled {
+		t.Fatalf("got %v, want Canceled", err)
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	pngreader "github.com/kouheiszk/png-reader"
+)
+
+// showCommand はデコードした画像を端末に表示する。
+// 表示方法は-protocolで選ぶか、環境変数から端末が対応するものを推測する。
+//
+//	kitty   Kitty graphics protocol (kitty、WezTerm、Ghostty)
+//	sixel   Sixel (mlterm、foot、xterm -ti vt340など)
+//	blocks  24ビットカラーのANSIエスケープと上半分のブロック文字。どの端末でも表示できる
+var showCommand = &command{
+	name:  "show",
+	usage: "show [-protocol auto|kitty|sixel|blocks] [-width columns] [-fs dir|zip] input|URL",
+}
+
+func init() {
+	showCommand.run = runShow
+}
+
+// cellWidth はSixelとKittyで画像の幅を決めるときに仮定する1文字の幅(ピクセル)
+const cellWidth = 8
+
+func runShow(args []string) error {
+	fs := newFlagSet(showCommand)
+	protocol := fs.String("protocol", "auto", "terminal graphics protocol (auto, kitty, sixel, blocks)")
+	width := fs.Int("width", 0, "maximum width in terminal columns (default $COLUMNS or 80)")
+	input := addInputFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	if *protocol == "auto" {
+		*protocol = detectProtocol()
+	}
+	columns := *width
+	if columns <= 0 {
+		columns = terminalColumns()
+	}
+
+	inputFile, err := input.open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer inputFile.Close()
+
+	// 画面で見たとおりに表示するため、色と向きを補正する
+	decoder := &pngreader.Decoder{
+		ApplyICC:   true,
+		AutoOrient: true,
+		Warn: func(err error) {
+			fmt.Fprintln(os.Stderr, "warning:", err)
+		},
+	}
+	img, err := decodeInput(inputFile, decoder, false)
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	switch *protocol {
+	case "kitty":
+		err = writeKitty(out, fit(img, columns*cellWidth))
+	case "sixel":
+		err = writeSixel(out, fit(img, columns*cellWidth))
+	case "blocks":
+		err = writeBlocks(out, fit(img, columns))
+	default:
+		return fmt.Errorf("unknown protocol %q", *protocol)
+	}
+	if err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+// detectProtocol は環境変数から端末が対応する表示方法を推測する。
+func detectProtocol() string {
+	term := os.Getenv("TERM")
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "", term == "xterm-kitty", term == "xterm-ghostty":
+		return "kitty"
+	case os.Getenv("TERM_PROGRAM") == "WezTerm", os.Getenv("TERM_PROGRAM") == "ghostty":
+		return "kitty"
+	case strings.Contains(term, "sixel"), term == "mlterm", strings.HasPrefix(term, "foot"), term == "yaft-256color":
+		return "sixel"
+	}
+	return "blocks"
+}
+
+// terminalColumns は端末の幅を$COLUMNSから返す。設定されていなければ80にする。
+func terminalColumns() int {
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	return 80
+}
+
+// fit はimgの幅がmaxWidthを超える場合、縦横比を保って縮小する。
+func fit(img image.Image, maxWidth int) image.Image {
+	b := img.Bounds()
+	if b.Dx() <= maxWidth {
+		return img
+	}
+	height := b.Dy() * maxWidth / b.Dx()
+	if height < 1 {
+		height = 1
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, maxWidth, height))
+	scale(dst, img)
+	return dst
+}
+
+// writeBlocks は上半分のブロック文字の前景色と背景色で、1文字に縦2ピクセルを表示する。
+// 透明度が半分未満のピクセルは端末の既定の色のままにする。
+func writeBlocks(w io.Writer, img image.Image) error {
+	b := img.Bounds()
+	visible := func(x, y int) (color.NRGBA, bool) {
+		if y >= b.Max.Y {
+			return color.NRGBA{}, false
+		}
+		c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+		return c, c.A >= 0x80
+	}
+	for y := b.Min.Y; y < b.Max.Y; y += 2 {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			top, topVisible := visible(x, y)
+			bottom, bottomVisible := visible(x, y+1)
+			switch {
+			case topVisible && bottomVisible:
+				fmt.Fprintf(w, "\x1b[38;2;%d;%d;%dm\x1b[48;2;%d;%d;%dm▀", top.R, top.G, top.B, bottom.R, bottom.G, bottom.B)
+			case topVisible:
+				fmt.Fprintf(w, "\x1b[38;2;%d;%d;%dm\x1b[49m▀", top.R, top.G, top.B)
+			case bottomVisible:
+				fmt.Fprintf(w, "\x1b[38;2;%d;%d;%dm\x1b[49m▄", bottom.R, bottom.G, bottom.B)
+			default:
+				io.WriteString(w, "\x1b[39;49m ")
+			}
+		}
+		if _, err := io.WriteString(w, "\x1b[0m\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeKitty はimgをPNGにエンコードし、Kitty graphics protocolのエスケープシーケンスで送る。
+// データは4096バイトずつに分け、最後以外にm=1を付ける。
+func writeKitty(w io.Writer, img image.Image) error {
+	var buf bytes.Buffer
+	if err := pngreader.Encode(&buf, img); err != nil {
+		return err
+	}
+	data := base64.StdEncoding.EncodeToString(buf.Bytes())
+	for first := true; first || len(data) > 0; first = false {
+		chunk := data
+		if len(chunk) > 4096 {
+			chunk = chunk[:4096]
+		}
+		data = data[len(chunk):]
+		more := 0
+		if len(data) > 0 {
+			more = 1
+		}
+		control := fmt.Sprintf("m=%d", more)
+		if first {
+			control = "a=T,f=100," + control
+		}
+		if _, err := fmt.Fprintf(w, "\x1b_G%s;%s\x1b\\", control, chunk); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// writeSixel はimgを216色のパレットに減色し、Sixelで送る。
+// 透明度が半分未満のピクセルは描かず、端末の背景を残す。
+func writeSixel(w io.Writer, img image.Image) error {
+	b := img.Bounds()
+	paletted := image.NewPaletted(image.Rect(0, 0, b.Dx(), b.Dy()), palette.WebSafe)
+	draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), img, b.Min)
+	transparent := func(x, y int) bool {
+		_, _, _, a := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+		return a < 0x8000
+	}
+
+	// P2=1で、描かなかったピクセルを透明にする
+	fmt.Fprintf(w, "\x1bP0;1;0q\"1;1;%d;%d", b.Dx(), b.Dy())
+	for i, c := range palette.WebSafe {
+		r, g, bl, _ := c.RGBA()
+		fmt.Fprintf(w, "#%d;2;%d;%d;%d", i, r*100/0xffff, g*100/0xffff, bl*100/0xffff)
+	}
+
+	// 縦6ピクセルの帯ごとに、使われている色を1色ずつ重ねて描く
+	sixels := make([]byte, b.Dx())
+	for top := 0; top < b.Dy(); top += 6 {
+		used := make(map[uint8]bool)
+		for y := top; y < top+6 && y < b.Dy(); y++ {
+			for x := 0; x < b.Dx(); x++ {
+				if !transparent(x, y) {
+					used[paletted.ColorIndexAt(x, y)] = true
+				}
+			}
+		}
+		first := true
+		for index := 0; index < len(palette.WebSafe); index++ {
+			if !used[uint8(index)] {
+				continue
+			}
+			for x := range sixels {
+				bits := byte(0)
+				for dy := 0; dy < 6 && top+dy < b.Dy(); dy++ {
+					if paletted.ColorIndexAt(x, top+dy) == uint8(index) && !transparent(x, top+dy) {
+						bits |= 1 << uint(dy)
+					}
+				}
+				sixels[x] = '?' + bits
+			}
+			if !first {
+				io.WriteString(w, "$")
+			}
+			first = false
+			fmt.Fprintf(w, "#%d", index)
+			writeSixelRun(w, sixels)
+		}
+		io.WriteString(w, "-")
+	}
+	_, err := io.WriteString(w, "\x1b\\\n")
+	return err
+}
+
+// writeSixelRun はSixelの文字列を、4文字以上の繰り返しを"!回数文字"に縮めて書き込む。
+func writeSixelRun(w io.Writer, sixels []byte) {
+	for i := 0; i < len(sixels); {
+		j := i
+		for j < len(sixels) && sixels[j] == sixels[i] {
+			j++
+		}
+		if n := j - i; n >= 4 {
+			fmt.Fprintf(w, "!%d%c", n, sixels[i])
+		} else {
+			w.Write(sixels[i:j])
+		}
+		i = j
+	}
+}
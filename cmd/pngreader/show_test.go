@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"flag"
+	"image"
+	"image/color"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	pngreader "github.com/kouheiszk/png-reader"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/show")
+
+// showImage はブロック、Sixel、Kittyの出力を比べるための3x7の画像を返す。
+// 不透明な色、半分の透明度の境目、Sixelの2つ目の帯を含む。
+func showImage() *image.NRGBA {
+	m := image.NewNRGBA(image.Rect(0, 0, 3, 7))
+	colors := []color.NRGBA{
+		{0xff, 0, 0, 0xff}, {0, 0xff, 0, 0xff}, {},
+		{0, 0, 0xff, 0xff}, {0xff, 0xff, 0xff, 0x7f}, {0xff, 0xff, 0xff, 0x80},
+		{0x33, 0x66, 0x99, 0xff}, {0x33, 0x66, 0x99, 0xff}, {0x33, 0x66, 0x99, 0xff},
+	}
+	for i, c := range colors {
+		m.SetNRGBA(i%3, i/3, c)
+	}
+	for x := 0; x < 3; x++ {
+		m.SetNRGBA(x, 6, color.NRGBA{0, 0, 0, 0xff})
+	}
+	return m
+}
+
+// checkGolden はgotをtestdata/show/nameと比べる。-updateの場合は書き換える。
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", "show", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs:\ngot  %q\nwant %q", name, got, want)
+	}
+}
+
+func TestShowGolden(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(io.Writer, image.Image) error
+	}{
+		{"blocks.golden", writeBlocks},
+		{"sixel.golden", writeSixel},
+		{"kitty.golden", writeKitty},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		if err := tt.write(&b, showImage()); err != nil {
+			t.Fatal(err)
+		}
+		checkGolden(t, tt.name, b.Bytes())
+	}
+}
+
+// TestWriteKitty は大きな画像のデータを4096バイトずつに分け、最後以外にm=1を付け、
+// つなげたデータが元の画像のPNGになることを確認する。
+func TestWriteKitty(t *testing.T) {
+	m := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	rand.New(rand.NewSource(1)).Read(m.Pix)
+	var b bytes.Buffer
+	if err := writeKitty(&b, m); err != nil {
+		t.Fatal(err)
+	}
+	commands := regexp.MustCompile("\x1b_G([^;]*);([^\x1b]*)\x1b\\\\").FindAllStringSubmatch(b.String(), -1)
+	if len(commands) < 3 {
+		t.Fatalf("%d commands", len(commands))
+	}
+	var data string
+	for i, c := range commands {
+		control, chunk := c[1], c[2]
+		want := "m=1"
+		switch {
+		case i == 0:
+			want = "a=T,f=100,m=1"
+		case i == len(commands)-1:
+			want = "m=0"
+		}
+		if control != want || len(chunk) > 4096 || i < len(commands)-1 && len(chunk) != 4096 {
+			t.Errorf("command %d: %q with %d bytes", i, control, len(chunk))
+		}
+		data += chunk
+	}
+	png, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := pngreader.Decode(bytes.NewReader(png))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(img.(*image.NRGBA).Pix, m.Pix) {
+		t.Error("pixels changed")
+	}
+}
+
+func TestFit(t *testing.T) {
+	uniform := func(w, h int) *image.NRGBA {
+		m := image.NewNRGBA(image.Rect(0, 0, w, h))
+		for i := range m.Pix {
+			m.Pix[i] = []byte{0x20, 0x40, 0x60, 0xff}[i%4]
+		}
+		return m
+	}
+	small := uniform(10, 4)
+	if fit(small, 10) != image.Image(small) {
+		t.Error("an image within the width was scaled")
+	}
+	tests := []struct {
+		w, h, maxWidth int
+		want           image.Point
+	}{
+		{40, 10, 20, image.Pt(20, 5)},
+		{30, 20, 7, image.Pt(7, 4)},
+		// 高さは1未満にしない
+		{100, 1, 10, image.Pt(10, 1)},
+	}
+	for _, tt := range tests {
+		got := fit(uniform(tt.w, tt.h), tt.maxWidth)
+		if got.Bounds() != (image.Rectangle{Max: tt.want}) {
+			t.Errorf("%dx%d in %d: bounds %v, want %v", tt.w, tt.h, tt.maxWidth, got.Bounds(), tt.want)
+			continue
+		}
+		if c := color.NRGBAModel.Convert(got.At(tt.want.X-1, tt.want.Y-1)); c != (color.NRGBA{0x20, 0x40, 0x60, 0xff}) {
+			t.Errorf("%dx%d in %d: color %v", tt.w, tt.h, tt.maxWidth, c)
+		}
+	}
+}
+
+func TestDetectProtocol(t *testing.T) {
+	tests := []struct {
+		term, termProgram, kittyWindow string
+		want                           string
+	}{
+		{"xterm-256color", "", "", "blocks"},
+		{"xterm-256color", "", "1", "kitty"},
+		{"xterm-kitty", "", "", "kitty"},
+		{"xterm-ghostty", "", "", "kitty"},
+		{"xterm-256color", "WezTerm", "", "kitty"},
+		{"xterm-256color", "ghostty", "", "kitty"},
+		{"mlterm", "", "", "sixel"},
+		{"foot-extra", "", "", "sixel"},
+		{"xterm-sixel", "", "", "sixel"},
+		{"yaft-256color", "", "", "sixel"},
+		{"", "", "", "blocks"},
+	}
+	for _, tt := range tests {
+		t.Setenv("TERM", tt.term)
+		t.Setenv("TERM_PROGRAM", tt.termProgram)
+		t.Setenv("KITTY_WINDOW_ID", tt.kittyWindow)
+		if got := detectProtocol(); got != tt.want {
+			t.Errorf("TERM=%q TERM_PROGRAM=%q KITTY_WINDOW_ID=%q: got %s, want %s", tt.term, tt.termProgram, tt.kittyWindow, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	pngreader "github.com/kouheiszk/png-reader"
+)
+
+// sliceCommand はスプライトシートを切り分け、タイルごとの画像として書き込む。
+//
+//	slice -size 32x32 sheet.png [dir]     左上から32x32のタイルに切り分け、dir/sheet_行_列.pngに書き込む
+//	slice -atlas sheet.json sheet.png [dir]
+//	                                      TexturePacker形式のJSONのframesに従って切り分け、dir/名前に書き込む
+//
+// PNGの入力はタイルの行やフレームごとに必要な範囲だけを展開するので、巨大なシートでも
+// 画像全体の画素をメモリに持たない。PNG以外の入力は全体をデコードしてから切り出す。
+// 画像の端で-sizeに満たないタイルは書き込まない。-atlasのJSONも入力と同じく-fsの中やURLから読む。
+var sliceCommand = &command{
+	name:  "slice",
+	usage: "slice -size WxH|-atlas file [-format name] [-fs dir|zip] input|URL [dir]",
+}
+
+func init() {
+	sliceCommand.run = runSlice
+}
+
+// atlasFrame はアトラスの1つのフレーム
+type atlasFrame struct {
+	Filename string    `json:"filename"`
+	Frame    atlasRect `json:"frame"`
+	// Rotated はシートの中で時計回りに90度回転して置かれていることを表す。Frameの幅と高さは回転前の値
+	Rotated bool `json:"rotated"`
+}
+
+// atlasRect はアトラスの中の矩形
+type atlasRect struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// rect はシートの中でフレームが占める範囲を返す。
+func (f *atlasFrame) rect() image.Rectangle {
+	w, h := f.Frame.W, f.Frame.H
+	if f.Rotated {
+		w, h = h, w
+	}
+	return image.Rect(f.Frame.X, f.Frame.Y, f.Frame.X+w, f.Frame.Y+h)
+}
+
+// atlasJSON はTexturePacker形式のJSON。framesは配列と、名前をキーにしたオブジェクトのどちらも読める
+type atlasJSON struct {
+	Frames json.RawMessage `json:"frames"`
+}
+
+func runSlice(args []string) error {
+	fs := newFlagSet(sliceCommand)
+	size := fs.String("size", "", "tile size as WxH")
+	atlasFile := fs.String("atlas", "", "TexturePacker JSON atlas describing the frames")
+	format := fs.String("format", "png", "output format: "+formatNames())
+	input := addInputFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 || fs.NArg() > 2 || (*size == "") == (*atlasFile == "") {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	if _, err := lookupFormat(*format); err != nil {
+		return err
+	}
+	dir := "."
+	if fs.NArg() == 2 {
+		dir = fs.Arg(1)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+
+	sheet, err := openSheet(input, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	write := func(name string, img image.Image) error {
+		path := filepath.Join(dir, name)
+		if err := writeImageFile(path, img, *format); err != nil {
+			return err
+		}
+		fmt.Println(path)
+		return nil
+	}
+
+	if *atlasFile != "" {
+		frames, err := readAtlas(input, *atlasFile)
+		if err != nil {
+			return err
+		}
+		for _, f := range frames {
+			img, err := sheet.region(f.rect())
+			if err != nil {
+				return fmt.Errorf("frame %q: %w", f.Filename, err)
+			}
+			if f.Rotated {
+				img = pngreader.Orient(img, 8)
+			}
+			name := strings.TrimSuffix(f.Filename, filepath.Ext(f.Filename)) + "." + *format
+			if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0o755); err != nil {
+				return err
+			}
+			if err := write(name, img); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var tw, th int
+	if _, err := fmt.Sscanf(*size, "%dx%d", &tw, &th); err != nil || tw <= 0 || th <= 0 {
+		return fmt.Errorf("invalid -size %q (want WxH)", *size)
+	}
+	base := filepath.Base(fs.Arg(0))
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+	cols, rows := sheet.bounds.Dx()/tw, sheet.bounds.Dy()/th
+	for row := 0; row < rows; row++ {
+		// 1行分のタイルをまとめて展開し、そこから各タイルを切り出す
+		band, err := sheet.region(image.Rect(0, row*th, cols*tw, (row+1)*th))
+		if err != nil {
+			return err
+		}
+		sub := band.(interface {
+			SubImage(image.Rectangle) image.Image
+		})
+		for col := 0; col < cols; col++ {
+			tile := sub.SubImage(image.Rect(col*tw, row*th, (col+1)*tw, (row+1)*th))
+			if err := write(fmt.Sprintf("%s_%d_%d.%s", base, row, col, *format), tile); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sheet は切り分ける画像。PNGは圧縮されたデータのまま持ち、それ以外はデコードした画像を持つ
+type sheet struct {
+	data   []byte
+	img    image.Image
+	bounds image.Rectangle
+}
+
+func openSheet(input *inputFlag, name string) (*sheet, error) {
+	f, err := input.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, []byte(pngSignature)) {
+		img, err := decodeInput(bytes.NewReader(data), &pngreader.Decoder{}, false)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		return &sheet{img: img, bounds: img.Bounds()}, nil
+	}
+	info, err := pngreader.DecodeInfo(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return &sheet{data: data, bounds: image.Rect(0, 0, info.Width, info.Height)}, nil
+}
+
+// region はシートのrの範囲の画像を返す。rはシートに収まっていなければならない。
+func (s *sheet) region(r image.Rectangle) (image.Image, error) {
+	if r.Empty() || !r.In(s.bounds) {
+		return nil, fmt.Errorf("%w: %v is outside the %dx%d image", pngreader.ErrRegion, r, s.bounds.Dx(), s.bounds.Dy())
+	}
+	if s.img == nil {
+		return pngreader.DecodeRegion(bytes.NewReader(s.data), r)
+	}
+	return s.img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}).SubImage(r), nil
+}
+
+// readAtlas はTexturePacker形式のJSONを読み、フレームを返す。
+// オブジェクト形式のframesはキーを名前にし、名前の順に並べる。
+func readAtlas(input *inputFlag, path string) ([]*atlasFrame, error) {
+	f, err := input.open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	var a atlasJSON
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	var frames []*atlasFrame
+	if err := json.Unmarshal(a.Frames, &frames); err != nil {
+		named := make(map[string]*atlasFrame)
+		if err := json.Unmarshal(a.Frames, &named); err != nil {
+			return nil, fmt.Errorf("%s: frames must be an array or an object", path)
+		}
+		frames = frames[:0]
+		for name, f := range named {
+			f.Filename = name
+			frames = append(frames, f)
+		}
+		sort.Slice(frames, func(i, j int) bool { return frames[i].Filename < frames[j].Filename })
+	}
+	for _, f := range frames {
+		// 出力先のディレクトリの外に書き込まないよう、親ディレクトリを指す名前は受け付けない
+		name := filepath.Clean(filepath.FromSlash(f.Filename))
+		if f.Filename == "" || filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("%s: invalid frame name %q", path, f.Filename)
+		}
+		f.Filename = name
+	}
+	return frames, nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pngreader "github.com/kouheiszk/png-reader"
+	"github.com/kouheiszk/png-reader/internal/imageutil"
+)
+
+// testSheet は画素ごとに異なる色を持つw×hのシートを返す。
+func testSheet(w, h int) *image.NRGBA {
+	m := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			m.SetNRGBA(x, y, color.NRGBA{uint8(x * 40), uint8(y * 60), 7, 255})
+		}
+	}
+	return m
+}
+
+func decodeTestFile(t *testing.T, path string) image.Image {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	m, err := pngreader.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// TestSliceAtlas はアトラスのフレームを切り出し、回転したフレームを元の向きに戻すことを確認する。
+// framesは配列とオブジェクトのどちらの形式でも同じ結果になる。
+func TestSliceAtlas(t *testing.T) {
+	sheet := testSheet(6, 4)
+	atlases := map[string]string{
+		"array": `{"frames": [
+			{"filename": "a.png", "frame": {"x": 0, "y": 0, "w": 2, "h": 2}},
+			{"filename": "sub/b.png", "frame": {"x": 2, "y": 1, "w": 3, "h": 2}, "rotated": true}
+		]}`,
+		"object": `{"frames": {
+			"a.png": {"frame": {"x": 0, "y": 0, "w": 2, "h": 2}},
+			"sub/b.png": {"frame": {"x": 2, "y": 1, "w": 3, "h": 2}, "rotated": true}
+		}}`,
+	}
+	for name, atlas := range atlases {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			input := writeTestPNG(t, dir, "sheet.png", new(pngreader.Encoder), sheet)
+			atlasPath := filepath.Join(dir, "sheet.json")
+			if err := os.WriteFile(atlasPath, []byte(atlas), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			out := filepath.Join(dir, "out")
+			if err := runSlice([]string{"-atlas", atlasPath, input, out}); err != nil {
+				t.Fatal(err)
+			}
+
+			a := decodeTestFile(t, filepath.Join(out, "a.png"))
+			if got := a.Bounds(); got != image.Rect(0, 0, 2, 2) {
+				t.Fatalf("a.png: bounds %v", got)
+			}
+			for y := 0; y < 2; y++ {
+				for x := 0; x < 2; x++ {
+					if got, want := imageutil.NRGBA64(a.At(x, y)), imageutil.NRGBA64(sheet.At(x, y)); got != want {
+						t.Errorf("a.png (%d, %d): got %v, want %v", x, y, got, want)
+					}
+				}
+			}
+
+			// シートには時計回りに回転して置かれているので、元の(x, y)はシートの(3-y, 1+x)にある
+			b := decodeTestFile(t, filepath.Join(out, "sub", "b.png"))
+			if got := b.Bounds(); got != image.Rect(0, 0, 3, 2) {
+				t.Fatalf("sub/b.png: bounds %v", got)
+			}
+			for y := 0; y < 2; y++ {
+				for x := 0; x < 3; x++ {
+					if got, want := imageutil.NRGBA64(b.At(x, y)), imageutil.NRGBA64(sheet.At(3-y, 1+x)); got != want {
+						t.Errorf("sub/b.png (%d, %d): got %v, want %v", x, y, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestSliceAtlasInvalid はシートからはみ出すフレームと、出力先の外を指す名前を拒否することを確認する。
+func TestSliceAtlasInvalid(t *testing.T) {
+	dir := t.TempDir()
+	input := writeTestPNG(t, dir, "sheet.png", new(pngreader.Encoder), testSheet(4, 4))
+	tests := []struct {
+		name   string
+		atlas  string
+		region bool
+	}{
+		{"outside", `{"frames": [{"filename": "a.png", "frame": {"x": 3, "y": 3, "w": 2, "h": 2}}]}`, true},
+		{"parent", `{"frames": [{"filename": "../a.png", "frame": {"x": 0, "y": 0, "w": 2, "h": 2}}]}`, false},
+	}
+	for _, tt := range tests {
+		atlasPath := filepath.Join(dir, tt.name+".json")
+		if err := os.WriteFile(atlasPath, []byte(tt.atlas), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		err := runSlice([]string{"-atlas", atlasPath, input, filepath.Join(dir, "out")})
+		if err == nil {
+			t.Errorf("%s: no error", tt.name)
+		} else if errors.Is(err, pngreader.ErrRegion) != tt.region {
+			t.Errorf("%s: got %v", tt.name, err)
+		}
+	}
+}
+
+// TestSliceSize は-sizeでシートを左上からタイルに切り分け、端の半端なタイルを書き込まないことを確認する。
+func TestSliceSize(t *testing.T) {
+	dir := t.TempDir()
+	sheet := testSheet(5, 4)
+	input := writeTestPNG(t, dir, "sheet.png", &pngreader.Encoder{Interlace: true}, sheet)
+	out := filepath.Join(dir, "out")
+	if err := runSlice([]string{"-size", "2x2", input, out}); err != nil {
+		t.Fatal(err)
+	}
+	files, err := filepath.Glob(filepath.Join(out, "*.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 4 {
+		t.Fatalf("got %d tiles, want 4: %v", len(files), files)
+	}
+	tile := decodeTestFile(t, filepath.Join(out, "sheet_1_1.png"))
+	if got, want := imageutil.NRGBA64(tile.At(0, 0)), imageutil.NRGBA64(sheet.At(2, 2)); got != want {
+		t.Errorf("sheet_1_1.png (0, 0): got %v, want %v", got, want)
+	}
+}
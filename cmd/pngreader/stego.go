@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	pngreader "github.com/kouheiszk/png-reader"
+	"github.com/kouheiszk/png-reader/stego"
+)
+
+// stegoCommand は画素の下位ビットにデータを埋め込み、取り出す。
+//
+//	stego capacity input.png               埋め込めるバイト数を表示する
+//	stego embed input.png payload out.png  payload(-は標準入力)を埋め込んだPNGを書き込む
+//	stego extract input.png [payload]      埋め込まれたデータを書き込む(省略時は標準出力)
+//
+// 取り出すときはembedと同じ-bits、-channels、-passphrase-fileを指定する。
+var stegoCommand = &command{
+	name:  "stego",
+	usage: "stego capacity|embed|extract [-bits n] [-channels rgb] [-passphrase-file file] [-fs dir|zip] input.png|URL [payload|-] [output.png]",
+}
+
+func init() {
+	stegoCommand.run = runStego
+}
+
+func runStego(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "usage: pngreader %s\n", stegoCommand.usage)
+		return flag.ErrHelp
+	}
+	op := args[0]
+	fs := newFlagSet(stegoCommand)
+	bits := fs.Int("bits", 1, "number of least-significant bits to use in each channel (1-8)")
+	channels := fs.String("channels", "rgb", "channels to use, in order, from r, g, b and a")
+	passphraseFile := fs.String("passphrase-file", "", "encrypt the payload with AES-256-GCM using the passphrase in this file")
+	input := addInputFlag(fs)
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	var nargs int
+	switch op {
+	case "capacity":
+		nargs = 1
+	case "embed":
+		nargs = 3
+	case "extract":
+		nargs = fs.NArg()
+		if nargs < 1 || nargs > 2 {
+			nargs = 1
+		}
+	default:
+		return fmt.Errorf("unknown stego operation %q (want capacity, embed or extract)", op)
+	}
+	if fs.NArg() != nargs {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	options := &stego.Options{Bits: *bits, Channels: *channels}
+	if *passphraseFile != "" {
+		passphrase, err := ioutil.ReadFile(*passphraseFile)
+		if err != nil {
+			return err
+		}
+		if options.Passphrase = bytes.TrimRight(passphrase, "\r\n"); len(options.Passphrase) == 0 {
+			return fmt.Errorf("%s: empty passphrase", *passphraseFile)
+		}
+	}
+
+	inputFile, err := input.open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	img, err := decodeInput(inputFile, &pngreader.Decoder{}, false)
+	inputFile.Close()
+	if err != nil {
+		return err
+	}
+
+	capacity, err := stego.Capacity(img, options)
+	if err != nil {
+		return err
+	}
+	switch op {
+	case "capacity":
+		fmt.Println(capacity)
+
+	case "embed":
+		var payload []byte
+		if fs.Arg(1) == "-" {
+			payload, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			payload, err = ioutil.ReadFile(fs.Arg(1))
+		}
+		if err != nil {
+			return err
+		}
+		if len(payload) > capacity {
+			return fmt.Errorf("payload is %d bytes, but %s can hold only %d bytes with these settings", len(payload), fs.Arg(0), capacity)
+		}
+		result, err := stego.Embed(img, payload, options)
+		if err != nil {
+			return err
+		}
+		// 非可逆な形式ではデータが失われるので、出力は常にPNGにする
+		if err := writeImageFile(fs.Arg(2), result, "png"); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "embedded %d of %d bytes\n", len(payload), capacity)
+
+	case "extract":
+		payload, err := stego.Extract(img, options)
+		if err != nil {
+			return err
+		}
+		if fs.NArg() == 2 {
+			return ioutil.WriteFile(fs.Arg(1), payload, 0644)
+		}
+		_, err = os.Stdout.Write(payload)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	pngreader "github.com/kouheiszk/png-reader"
+)
+
+// validateCommand はzipかtarのアーカイブに含まれるPNGをまとめて検証する。
+// 展開せずにエントリを先頭から順に読み、PNG(拡張子が.pngかシグネチャがPNGのもの)ごとに
+// 適合性を1行で報告する。-oを指定すると、-optimizeで再エンコードしたPNGと
+// それ以外のエントリ(ディレクトリやリンクを含む)をそのまま書き込んだアーカイブを作成する。
+// アーカイブ爆弾でメモリを使い果たさないよう、エントリの大きさとPNGの画素数を制限する。
+var validateCommand = &command{
+	name:  "validate",
+	usage: "validate [-optimize] [-o output.zip|.tar|.tar.gz] [-max-member-size bytes] [-max-pixels n] [-fs dir|zip] input.png|archive.zip|archive.tar[.gz]|URL",
+}
+
+func init() {
+	validateCommand.run = runValidate
+}
+
+func runValidate(args []string) error {
+	fs := newFlagSet(validateCommand)
+	optimize := fs.Bool("optimize", false, "re-encode conformant PNGs with the smallest color type and best compression, keeping the result only if smaller (ancillary chunks are dropped)")
+	output := fs.String("o", "", "write a rewritten archive with the optimized PNGs and all other entries to this file")
+	maxMemberSize := fs.Int64("max-member-size", 256<<20, "maximum size of an archive entry in bytes")
+	maxPixels := fs.Int("max-pixels", defaultMaxPixels, "maximum width×height of a PNG")
+	input := addInputFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	inputFile, err := input.open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer inputFile.Close()
+
+	v := &validator{optimize: *optimize, decoder: &pngreader.Decoder{Limits: serverLimits(*maxPixels)}}
+	kind := archiveKindForPath(fs.Arg(0))
+	if kind == notArchive {
+		if *output != "" {
+			return fmt.Errorf("-o requires an archive input")
+		}
+		data, err := readMember(inputFile, fs.Arg(0), 0, *maxMemberSize)
+		if err != nil {
+			return err
+		}
+		if err := v.member(&archiveMember{name: fs.Arg(0), data: data}); err != nil {
+			return err
+		}
+		return v.result()
+	}
+
+	if *output != "" {
+		outputFile, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer outputFile.Close()
+		if v.w, err = newArchiveWriter(outputFile, archiveKindForPath(*output)); err != nil {
+			return err
+		}
+	}
+	if err := walkArchive(inputFile, kind, *maxMemberSize, v.member); err != nil {
+		return err
+	}
+	if v.w != nil {
+		if err := v.w.Close(); err != nil {
+			return err
+		}
+	}
+	return v.result()
+}
+
+// validator はアーカイブのエントリを検証し、集計する。
+type validator struct {
+	optimize bool
+	decoder  *pngreader.Decoder
+	w        archiveWriter
+
+	total, failed int
+	saved         int
+}
+
+// member は1つのエントリを検証して結果を表示し、wがあれば書き込む。
+func (v *validator) member(m *archiveMember) error {
+	if m.isFile() && m.isPNG() {
+		v.total++
+		report, err := v.decoder.ConformanceReportContext(context.Background(), bytes.NewReader(m.data))
+		switch {
+		case err != nil:
+			v.failed++
+			fmt.Printf("FAIL %s: %v\n", m.name, err)
+		case !report.Conformant:
+			v.failed++
+			fmt.Printf("FAIL %s: %s\n", m.name, firstViolation(report))
+		case v.optimize:
+			saved, err := v.optimizeMember(m)
+			if err != nil {
+				return fmt.Errorf("%s: %w", m.name, err)
+			}
+			fmt.Printf("ok   %s (%d bytes saved)\n", m.name, saved)
+		default:
+			fmt.Printf("ok   %s\n", m.name)
+		}
+	}
+	if v.w == nil {
+		return nil
+	}
+	return v.w.add(m)
+}
+
+// optimizeMember はm.dataを再エンコードし、小さくなった場合は置き換えて削減したバイト数を返す。
+func (v *validator) optimizeMember(m *archiveMember) (int, error) {
+	img, err := v.decoder.Decode(bytes.NewReader(m.data))
+	if err != nil {
+		return 0, err
+	}
+	var data bytes.Buffer
+	if err := encodeOptimizedPNG(&data, img); err != nil {
+		return 0, err
+	}
+	if data.Len() >= len(m.data) {
+		return 0, nil
+	}
+	saved := len(m.data) - data.Len()
+	m.data = data.Bytes()
+	v.saved += saved
+	return saved, nil
+}
+
+// result は集計を表示し、適合しないPNGがあればエラーを返す。
+func (v *validator) result() error {
+	if v.optimize {
+		fmt.Printf("%d PNG files, %d not conformant, %d bytes saved\n", v.total, v.failed, v.saved)
+	} else {
+		fmt.Printf("%d PNG files, %d not conformant\n", v.total, v.failed)
+	}
+	if v.failed > 0 {
+		return fmt.Errorf("%d of %d PNG files not conformant", v.failed, v.total)
+	}
+	return nil
+}
+
+// firstViolation はreportの最初の違反を返す。
+func firstViolation(report *pngreader.Report) string {
+	if report.Error != "" {
+		return report.Error
+	}
+	for _, c := range report.Checks {
+		if c.Status == pngreader.StatusFail && len(c.Messages) > 0 {
+			return c.Section + " " + c.Title + ": " + c.Messages[0]
+		}
+	}
+	return "not conformant"
+}
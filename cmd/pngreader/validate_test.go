@@ -0,0 +1,176 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestTar はheadersとcontentsの各エントリを持つtarをdirのnameに書き込み、そのパスを返す。
+func writeTestTar(t *testing.T, dir, name string, headers []*tar.Header, contents map[string][]byte) string {
+	t.Helper()
+	var b bytes.Buffer
+	tw := tar.NewWriter(&b)
+	for _, h := range headers {
+		h.Size = int64(len(contents[h.Name]))
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write(contents[h.Name])
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestValidateTarPassThrough は-oで書き直したtarに、PNG以外のファイル、ディレクトリ、
+// シンボリックリンク、ハードリンクが元の型とリンク先のまま残ることを確認する。
+func TestValidateTarPassThrough(t *testing.T) {
+	dir := t.TempDir()
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	headers := []*tar.Header{
+		{Name: "img/", Typeflag: tar.TypeDir, Mode: 0o755, ModTime: modTime},
+		{Name: "img/a.png", Typeflag: tar.TypeReg, Mode: 0o644, ModTime: modTime},
+		{Name: "img/link.png", Typeflag: tar.TypeSymlink, Linkname: "a.png", Mode: 0o777, ModTime: modTime},
+		{Name: "img/hard.png", Typeflag: tar.TypeLink, Linkname: "img/a.png", Mode: 0o644, ModTime: modTime},
+		{Name: "notes.txt", Typeflag: tar.TypeReg, Mode: 0o600, ModTime: modTime, Uname: "alice"},
+	}
+	contents := map[string][]byte{"img/a.png": testPNGBytes(t), "notes.txt": []byte("not an image")}
+	input := writeTestTar(t, dir, "in.tar", headers, contents)
+	output := filepath.Join(dir, "out.tar")
+
+	if err := runValidate([]string{"-o", output, input}); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tr := tar.NewReader(f)
+	for _, want := range headers {
+		got, err := tr.Next()
+		if err != nil {
+			t.Fatalf("%s: %v", want.Name, err)
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Name != want.Name || got.Typeflag != want.Typeflag || got.Linkname != want.Linkname ||
+			got.Mode != want.Mode || !got.ModTime.Equal(modTime) || got.Uname != want.Uname {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+		if !bytes.Equal(data, contents[want.Name]) {
+			t.Errorf("%s: content changed", want.Name)
+		}
+	}
+	if _, err := tr.Next(); err != io.EOF {
+		t.Errorf("extra entries: %v", err)
+	}
+}
+
+// TestValidateZipSymlink はzipのシンボリックリンクが、zipとtarのどちらに書き直しても
+// リンクのまま残ることを確認する。
+func TestValidateZipSymlink(t *testing.T) {
+	dir := t.TempDir()
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	for _, e := range []struct {
+		name string
+		mode fs.FileMode
+		data []byte
+	}{
+		{"a.png", 0o644, testPNGBytes(t)},
+		{"link.png", fs.ModeSymlink | 0o777, []byte("a.png")},
+	} {
+		header := &zip.FileHeader{Name: e.name, Method: zip.Store}
+		header.SetMode(e.mode)
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(e.data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	input := filepath.Join(dir, "in.zip")
+	if err := os.WriteFile(input, b.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	output := filepath.Join(dir, "out.zip")
+	if err := runValidate([]string{"-o", output, input}); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.OpenReader(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	if len(zr.File) != 2 || zr.File[1].Name != "link.png" || zr.File[1].Mode().Type() != fs.ModeSymlink {
+		t.Fatalf("entries %v", zr.File)
+	}
+	rc, err := zr.File[1].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, _ := ioutil.ReadAll(rc)
+	rc.Close()
+	if string(target) != "a.png" {
+		t.Errorf("zip link target %q", target)
+	}
+
+	output = filepath.Join(dir, "out.tar")
+	if err := runValidate([]string{"-o", output, input}); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tr := tar.NewReader(f)
+	tr.Next()
+	if h, err := tr.Next(); err != nil || h.Typeflag != tar.TypeSymlink || h.Linkname != "a.png" {
+		t.Errorf("tar link: %+v, %v", h, err)
+	}
+}
+
+func TestValidateArchiveErrors(t *testing.T) {
+	dir := t.TempDir()
+	contents := map[string][]byte{"big.txt": bytes.Repeat([]byte("x"), 2048)}
+	big := writeTestTar(t, dir, "big.tar", []*tar.Header{{Name: "big.txt", Typeflag: tar.TypeReg, Mode: 0o644}}, contents)
+	if err := runValidate([]string{"-max-member-size", "1024", big}); err == nil || !strings.Contains(err.Error(), "exceeds limit") {
+		t.Errorf("member size: got %v", err)
+	}
+
+	hard := writeTestTar(t, dir, "hard.tar", []*tar.Header{
+		{Name: "a.txt", Typeflag: tar.TypeReg, Mode: 0o644},
+		{Name: "b.txt", Typeflag: tar.TypeLink, Linkname: "a.txt"},
+	}, nil)
+	if err := runValidate([]string{"-o", filepath.Join(dir, "out.zip"), hard}); err == nil {
+		t.Error("hard link written to a zip archive")
+	}
+
+	// 画素数の制限を超えるPNGは適合しないと報告する
+	contents = map[string][]byte{"a.png": testPNGBytes(t)}
+	limited := writeTestTar(t, dir, "limited.tar", []*tar.Header{{Name: "a.png", Typeflag: tar.TypeReg, Mode: 0o644}}, contents)
+	if err := runValidate([]string{"-max-pixels", "11", limited}); err == nil {
+		t.Error("12 pixels accepted with a limit of 11")
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"os"
+
+	pngreader "github.com/kouheiszk/png-reader"
+)
+
+// workerCommand は標準入力から1行1リクエストのJSONを読み、1行1レスポンスのJSONを
+// 標準出力に書き続ける。ビルドシステムなどがファイルごとにプロセスを起動せずに済むよう、
+// 標準入力が閉じられるまで終了しない。リクエストは到着順に1つずつ処理する。
+//
+//	{"id": 1, "op": "info", "path": "a.png"}
+//	{"id": 2, "op": "validate", "data": "<base64>"}
+//	{"id": 3, "op": "decode", "path": "a.png", "strict": true, "output": "a.qoi"}
+//
+// opは次のいずれか。入力はpath(-fsのファイルやURLも使える)かdata(base64)で渡す。
+//
+//	info      ヘッダとチャンク構成を返す(pngreader.Info)
+//	validate  仕様適合性の検証結果を返す(pngreader.Report)。strictの場合は警告も違反とする
+//	decode    デコードして幅と高さを返す。outputがあればformat(省略時は拡張子)の形式で書き出す
+//
+// 信頼できない入力を扱い続けるので、serveと同じく画素数などを制限してデコードする。
+//
+// レスポンスはidをそのまま返し、成功時は"ok": trueと結果を、失敗時はerrorと
+// class(format、integrity、unsupported、limit、request、other)を持つ。
+var workerCommand = &command{
+	name:  "worker",
+	usage: "worker [-max-pixels n] [-fs dir|zip]",
+}
+
+func init() {
+	workerCommand.run = runWorker
+}
+
+// workerRequest はworkerの1つのリクエスト
+type workerRequest struct {
+	ID     json.RawMessage `json:"id"`
+	Op     string          `json:"op"`
+	Path   string          `json:"path"`
+	Data   []byte          `json:"data"`
+	Strict bool            `json:"strict"`
+	Output string          `json:"output"`
+	Format string          `json:"format"`
+}
+
+// workerResponse はworkerの1つのレスポンス
+type workerResponse struct {
+	ID     json.RawMessage   `json:"id"`
+	OK     bool              `json:"ok"`
+	Info   *pngreader.Info   `json:"info,omitempty"`
+	Report *pngreader.Report `json:"report,omitempty"`
+	Width  int               `json:"width,omitempty"`
+	Height int               `json:"height,omitempty"`
+	Error  string            `json:"error,omitempty"`
+	Class  string            `json:"class,omitempty"`
+}
+
+// errRequest は不正なリクエストを表す。
+var errRequest = errors.New("invalid request")
+
+func runWorker(args []string) error {
+	fs := newFlagSet(workerCommand)
+	maxPixels := fs.Int("max-pixels", defaultMaxPixels, "maximum width×height of an input PNG")
+	input := addInputFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	w := &worker{input: input, limits: serverLimits(*maxPixels)}
+	return w.serve(os.Stdin, os.Stdout)
+}
+
+// worker は1行1リクエストを処理する。
+type worker struct {
+	input  *inputFlag
+	limits pngreader.Limits
+}
+
+// serve はrが終わるまでリクエストを読み、レスポンスをwに書く。
+func (wk *worker) serve(r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	// base64で画像を送るため、1行の上限を大きくする
+	scanner.Buffer(make([]byte, 64*1024), 256<<20)
+	out := bufio.NewWriter(w)
+	encoder := json.NewEncoder(out)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var req workerRequest
+		var resp *workerResponse
+		if err := json.Unmarshal(line, &req); err != nil {
+			resp = failure(fmt.Errorf("%w: %v", errRequest, err))
+		} else {
+			resp = wk.handle(&req)
+		}
+		resp.ID = req.ID
+		if len(resp.ID) == 0 {
+			resp.ID = json.RawMessage("null")
+		}
+		if err := encoder.Encode(resp); err != nil {
+			return err
+		}
+		if err := out.Flush(); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func (wk *worker) handle(req *workerRequest) *workerResponse {
+	switch req.Op {
+	case "info", "validate", "decode":
+	default:
+		return failure(fmt.Errorf("%w: unknown op %q", errRequest, req.Op))
+	}
+	if (req.Path == "") == (req.Data == nil) {
+		return failure(fmt.Errorf("%w: exactly one of path and data is required", errRequest))
+	}
+	var r io.Reader = bytes.NewReader(req.Data)
+	if req.Path != "" {
+		f, err := wk.input.open(req.Path)
+		if err != nil {
+			return failure(err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	decoder := &pngreader.Decoder{Strict: req.Strict, Limits: wk.limits}
+	switch req.Op {
+	case "info":
+		info, err := decoder.DecodeInfo(r)
+		if err != nil {
+			return failure(err)
+		}
+		return &workerResponse{OK: true, Info: info}
+
+	case "validate":
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return failure(err)
+		}
+		report, err := decoder.ConformanceReportContext(context.Background(), bytes.NewReader(data))
+		if err != nil {
+			return failure(err)
+		}
+		// 検証結果は仕様違反ではない警告を含まないので、Strictの場合はデコードして確かめる
+		if req.Strict && report.Conformant {
+			if _, err := decoder.Decode(bytes.NewReader(data)); err != nil {
+				report.Conformant = false
+				report.Error = err.Error()
+			}
+		}
+		return &workerResponse{OK: true, Report: report}
+
+	case "decode":
+		format := req.Format
+		if format == "" && req.Output != "" {
+			format = formatForPath(req.Output)
+		}
+		if _, err := lookupFormat(format); err != nil {
+			return failure(fmt.Errorf("%w: %v", errRequest, err))
+		}
+		img, err := decodeInput(r, decoder, false)
+		if err != nil {
+			return failure(err)
+		}
+		if req.Output != "" {
+			if err := writeImageFile(req.Output, img, format); err != nil {
+				return failure(err)
+			}
+		}
+		b := img.Bounds()
+		return &workerResponse{OK: true, Width: b.Dx(), Height: b.Dy()}
+	}
+	return nil
+}
+
+// writeImageFile はimgをformatの形式でpathに書き込み、必要ならサイドカーも書き込む。
+func writeImageFile(path string, img image.Image, format string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = encodeImage(f, img, format)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return writeSidecar(path, img, format)
+}
+
+// failure はerrを分類したエラーのレスポンスを返す。
+func failure(err error) *workerResponse {
+	class := "other"
+	switch {
+	case errors.Is(err, errRequest):
+		class = "request"
+	case errors.Is(err, pngreader.ErrLimit):
+		class = "limit"
+	case errors.Is(err, pngreader.ErrUnsupported):
+		class = "unsupported"
+	case errors.Is(err, pngreader.ErrIntegrity):
+		class = "integrity"
+	case errors.Is(err, pngreader.ErrFormat):
+		class = "format"
+	}
+	return &workerResponse{Error: err.Error(), Class: class}
+}
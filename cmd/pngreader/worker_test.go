@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pngreader "github.com/kouheiszk/png-reader"
+)
+
+// testPNGBytes は画素ごとに異なる色を持つ4×3のPNGを返す。
+func testPNGBytes(t *testing.T) []byte {
+	t.Helper()
+	m := image.NewNRGBA(image.Rect(0, 0, 4, 3))
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 4; x++ {
+			m.SetNRGBA(x, y, color.NRGBA{uint8(x * 40), uint8(y * 60), 7, 255})
+		}
+	}
+	var b bytes.Buffer
+	if err := pngreader.Encode(&b, m); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// insertChunk はpngのIHDRの直後にchunkTypeのチャンクを挿入する。
+func insertChunk(png []byte, chunkType string, data []byte) []byte {
+	chunk := make([]byte, 8, 12+len(data))
+	binary.BigEndian.PutUint32(chunk, uint32(len(data)))
+	copy(chunk[4:], chunkType)
+	chunk = append(chunk, data...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+	// シグネチャ8バイトとIHDR 25バイトの後
+	out := append([]byte(nil), png[:33]...)
+	out = append(out, chunk...)
+	return append(out, png[33:]...)
+}
+
+// runWorkerLines はrequestsを1行ずつworkerに渡し、レスポンスを返す。
+func runWorkerLines(t *testing.T, wk *worker, requests ...string) []workerResponse {
+	t.Helper()
+	var out bytes.Buffer
+	if err := wk.serve(strings.NewReader(strings.Join(requests, "\n")), &out); err != nil {
+		t.Fatal(err)
+	}
+	var responses []workerResponse
+	decoder := json.NewDecoder(&out)
+	for decoder.More() {
+		var resp workerResponse
+		if err := decoder.Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		responses = append(responses, resp)
+	}
+	if len(responses) != len(requests) {
+		t.Fatalf("%d responses for %d requests", len(responses), len(requests))
+	}
+	return responses
+}
+
+func workerRequestLine(t *testing.T, req workerRequest) string {
+	t.Helper()
+	line, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(line)
+}
+
+func TestWorker(t *testing.T) {
+	dir := t.TempDir()
+	png := testPNGBytes(t)
+	withWarning := insertChunk(png, "prIv", []byte("private"))
+	wk := &worker{input: &inputFlag{}, limits: serverLimits(defaultMaxPixels)}
+
+	responses := runWorkerLines(t, wk,
+		workerRequestLine(t, workerRequest{ID: json.RawMessage(`1`), Op: "info", Data: png}),
+		workerRequestLine(t, workerRequest{ID: json.RawMessage(`"v"`), Op: "validate", Data: withWarning}),
+		workerRequestLine(t, workerRequest{ID: json.RawMessage(`3`), Op: "validate", Data: withWarning, Strict: true}),
+		workerRequestLine(t, workerRequest{ID: json.RawMessage(`4`), Op: "decode", Data: png, Output: filepath.Join(dir, "out.qoi")}),
+		`{"id": 5, "op": "resize", "data": ""}`,
+		`{"id": 6, "op": "info"}`,
+		`not json`,
+	)
+	if r := responses[0]; !r.OK || string(r.ID) != "1" || r.Info == nil || r.Info.Width != 4 || r.Info.Height != 3 {
+		t.Errorf("info: %+v", r)
+	}
+	if r := responses[1]; !r.OK || string(r.ID) != `"v"` || !r.Report.Conformant {
+		t.Errorf("validate: %+v", r)
+	}
+	if r := responses[2]; !r.OK || r.Report.Conformant || r.Report.Error == "" {
+		t.Errorf("strict validate: %+v", r.Report)
+	}
+	if r := responses[3]; !r.OK || r.Width != 4 || r.Height != 3 {
+		t.Errorf("decode: %+v", r)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "out.qoi")); err != nil || !bytes.HasPrefix(data, []byte("qoif")) {
+		t.Errorf("output: %q, %v", data, err)
+	}
+	for i, id := range []string{"5", "6", "null"} {
+		if r := responses[4+i]; r.OK || r.Class != "request" || string(r.ID) != id {
+			t.Errorf("bad request %s: %+v", id, r)
+		}
+	}
+}
+
+// TestWorkerLimits はworkerが-max-pixelsを超える画像をデコードせずにlimitとして返すことを確認する。
+func TestWorkerLimits(t *testing.T) {
+	wk := &worker{input: &inputFlag{}, limits: serverLimits(11)}
+	png := testPNGBytes(t)
+	for _, op := range []string{"decode", "info"} {
+		if r := runWorkerLines(t, wk, workerRequestLine(t, workerRequest{Op: op, Data: png}))[0]; r.OK || r.Class != "limit" {
+			t.Errorf("%s: %+v", op, r)
+		}
+	}
+	r := runWorkerLines(t, wk, workerRequestLine(t, workerRequest{Op: "validate", Data: png}))[0]
+	if !r.OK || r.Report.Conformant || !strings.Contains(r.Report.Error, "limit") {
+		t.Errorf("validate: %+v", r.Report)
+	}
+}
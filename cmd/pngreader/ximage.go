@@ -0,0 +1,35 @@
+//go:build ximage
+// +build ximage
+
+package main
+
+import (
+	"image"
+	"io"
+
+	"github.com/kouheiszk/png-reader/output"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// ximageタグを付けてビルドすると、golang.org/x/imageのコーデックで
+// TIFFとBMPの出力、BMP、TIFF、WebPの入力に対応し、showの縮小にCatmullRomを使う。
+// タグなしのビルドはgolang.org/x/imageに依存しない。
+func init() {
+	output.Register(output.Format{
+		Name:        "tiff",
+		Aliases:     []string{"tif"},
+		ContentType: "image/tiff",
+		Encoder: output.EncoderFunc(func(w io.Writer, img image.Image) error {
+			return tiff.Encode(w, img, &tiff.Options{Compression: tiff.Deflate, Predictor: true})
+		}),
+	})
+	output.Register(output.Format{Name: "bmp", ContentType: "image/bmp", Encoder: output.EncoderFunc(bmp.Encode)})
+}
+
+// scale はsrcをdstの大きさにCatmullRomで縮小する。
+func scale(dst *image.NRGBA, src image.Image) {
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Src, nil)
+}
@@ -0,0 +1,136 @@
+// Package colors は画像に使われている色を数え、パレット(カラータイプ3)で
+// 表せるかどうかを調べる。パレットに変換できる画像は、多くの場合トゥルーカラーより小さくなる。
+package colors
+
+import (
+	"image"
+	"image/color"
+	"sort"
+
+	"github.com/kouheiszk/png-reader/internal/imageutil"
+)
+
+// MaxPalette はPNGのパレットに入る最大の色数
+const MaxPalette = 256
+
+// Analysis は画像の色の集計
+type Analysis struct {
+	Pixels int `json:"pixels"`
+	// Unique はアルファを含めた異なる色の数、UniqueRGB はアルファを無視した異なる色の数
+	Unique    int `json:"uniqueColors"`
+	UniqueRGB int `json:"uniqueRGB"`
+
+	Opaque bool `json:"opaque"`
+	Gray   bool `json:"gray"`
+	// Exact8Bit はすべてのサンプルが8ビットで正確に表せるかどうか
+	Exact8Bit bool `json:"exact8Bit"`
+
+	// FitsPalette はアルファを含めてパレットで表せるかどうか。不透明でない場合はtRNSが必要
+	FitsPalette bool `json:"fitsPalette"`
+	// FitsPaletteRGB はアルファを捨てればパレットで表せるかどうか
+	FitsPaletteRGB bool `json:"fitsPaletteIgnoringAlpha"`
+	// PaletteBitDepth はFitsPaletteの場合に足りる最小のビット深度(1、2、4、8)
+	PaletteBitDepth int `json:"paletteBitDepth,omitempty"`
+
+	counts map[color.NRGBA64]int
+}
+
+// Analyze はimgの色を数える。
+func Analyze(img image.Image) *Analysis {
+	a := &Analysis{Opaque: true, Gray: true, Exact8Bit: true, counts: make(map[color.NRGBA64]int)}
+	rgb := make(map[[3]uint16]struct{})
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := imageutil.NRGBA64(img.At(x, y))
+			a.counts[c]++
+			rgb[[3]uint16{c.R, c.G, c.B}] = struct{}{}
+			if c.A != 0xffff {
+				a.Opaque = false
+			}
+			if c.R != c.G || c.G != c.B {
+				a.Gray = false
+			}
+			if c.R%0x101 != 0 || c.G%0x101 != 0 || c.B%0x101 != 0 || c.A%0x101 != 0 {
+				a.Exact8Bit = false
+			}
+		}
+	}
+	a.Pixels = b.Dx() * b.Dy()
+	a.Unique = len(a.counts)
+	a.UniqueRGB = len(rgb)
+	a.FitsPalette = a.Exact8Bit && a.Unique <= MaxPalette
+	a.FitsPaletteRGB = a.Exact8Bit && a.UniqueRGB <= MaxPalette
+	if a.FitsPalette {
+		for _, depth := range []int{1, 2, 4, 8} {
+			if a.Unique <= 1<<uint(depth) {
+				a.PaletteBitDepth = depth
+				break
+			}
+		}
+	}
+	return a
+}
+
+// Palette はFitsPaletteの場合に画像のすべての色を持つパレットを返す。
+// tRNSを短くできるよう半透明の色を前に、同じ透明度では使われている画素が多い順に並べる。
+// パレットで表せない場合はnilを返す。
+func (a *Analysis) Palette() color.Palette {
+	if !a.FitsPalette {
+		return nil
+	}
+	colors := make([]color.NRGBA64, 0, len(a.counts))
+	for c := range a.counts {
+		colors = append(colors, c)
+	}
+	sort.Slice(colors, func(i, j int) bool {
+		ci, cj := colors[i], colors[j]
+		if oi, oj := ci.A == 0xffff, cj.A == 0xffff; oi != oj {
+			return oj
+		}
+		if a.counts[ci] != a.counts[cj] {
+			return a.counts[ci] > a.counts[cj]
+		}
+		return less(ci, cj)
+	})
+	palette := make(color.Palette, len(colors))
+	for i, c := range colors {
+		palette[i] = color.NRGBA{uint8(c.R >> 8), uint8(c.G >> 8), uint8(c.B >> 8), uint8(c.A >> 8)}
+	}
+	return palette
+}
+
+// Paletted はFitsPaletteの場合にimgをPaletteの色で表した*image.Palettedを返す。
+// 非乗算済みの値で色を引くため、透明な画素の色も区別される。
+func (a *Analysis) Paletted(img image.Image) *image.Paletted {
+	palette := a.Palette()
+	if palette == nil {
+		return nil
+	}
+	index := make(map[color.NRGBA64]uint8, len(palette))
+	for i, c := range palette {
+		index[imageutil.NRGBA64(c)] = uint8(i)
+	}
+	b := img.Bounds()
+	dst := image.NewPaletted(b, palette)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			dst.SetColorIndex(x, y, index[imageutil.NRGBA64(img.At(x, y))])
+		}
+	}
+	return dst
+}
+
+// less は色の並びを決めるための比較
+func less(a, b color.NRGBA64) bool {
+	if a.R != b.R {
+		return a.R < b.R
+	}
+	if a.G != b.G {
+		return a.G < b.G
+	}
+	if a.B != b.B {
+		return a.B < b.B
+	}
+	return a.A < b.A
+}
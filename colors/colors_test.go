@@ -0,0 +1,91 @@
+package colors
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(1, 1, 5, 3))
+	for x := 1; x < 5; x++ {
+		img.SetNRGBA(x, 1, color.NRGBA{0x80, 0x80, 0x80, 0xff})
+		img.SetNRGBA(x, 2, color.NRGBA{0x80, 0x80, 0x80, 0xff})
+	}
+	img.SetNRGBA(1, 1, color.NRGBA{0xff, 0, 0, 0xff})
+	img.SetNRGBA(2, 1, color.NRGBA{0xff, 0, 0, 0x40})
+
+	a := Analyze(img)
+	want := Analysis{
+		Pixels: 8, Unique: 3, UniqueRGB: 2,
+		Opaque: false, Gray: false, Exact8Bit: true,
+		FitsPalette: true, FitsPaletteRGB: true, PaletteBitDepth: 2,
+	}
+	a.counts = nil
+	if !reflect.DeepEqual(*a, want) {
+		t.Errorf("got %+v, want %+v", *a, want)
+	}
+
+	gray := Analyze(image.NewGray(image.Rect(0, 0, 3, 3)))
+	if !gray.Opaque || !gray.Gray || gray.Unique != 1 || gray.PaletteBitDepth != 1 {
+		t.Errorf("gray: got %+v", gray)
+	}
+}
+
+// TestTooManyColors はパレットに収まらない画像と、8ビットで表せない画像を見分けることを確認する。
+func TestTooManyColors(t *testing.T) {
+	// 257色のうち2色はアルファだけが違う
+	img := image.NewNRGBA(image.Rect(0, 0, 257, 1))
+	for x := 0; x < 256; x++ {
+		img.SetNRGBA(x, 0, color.NRGBA{uint8(x), 0, 0, 0xff})
+	}
+	img.SetNRGBA(256, 0, color.NRGBA{0, 0, 0, 0x80})
+	a := Analyze(img)
+	if a.Unique != 257 || a.UniqueRGB != 256 || a.FitsPalette || !a.FitsPaletteRGB || a.PaletteBitDepth != 0 {
+		t.Errorf("got %+v", a)
+	}
+	if a.Palette() != nil || a.Paletted(img) != nil {
+		t.Error("palette returned for too many colors")
+	}
+
+	deep := image.NewNRGBA64(image.Rect(0, 0, 1, 1))
+	deep.SetNRGBA64(0, 0, color.NRGBA64{0x1234, 0, 0, 0xffff})
+	if a := Analyze(deep); a.Exact8Bit || a.FitsPalette || a.FitsPaletteRGB {
+		t.Errorf("16-bit: got %+v", a)
+	}
+}
+
+// TestPaletted はパレットが半透明の色を前に、同じ透明度では多い順に並べ、
+// 変換した画像が元の画素と同じ色を持つことを確認する。
+func TestPaletted(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	colors := []color.NRGBA{
+		{0, 0, 0xff, 0xff}, {0, 0, 0xff, 0xff}, {0, 0, 0xff, 0xff}, {0xff, 0, 0, 0xff},
+		{0, 0xff, 0, 0xff}, {0, 0xff, 0, 0xff}, {0x10, 0x20, 0x30, 0}, {0, 0, 0, 0},
+	}
+	for i, c := range colors {
+		img.SetNRGBA(i%4, i/4, c)
+	}
+	a := Analyze(img)
+	palette := a.Palette()
+	want := color.Palette{
+		color.NRGBA{0, 0, 0, 0}, color.NRGBA{0x10, 0x20, 0x30, 0},
+		color.NRGBA{0, 0, 0xff, 0xff}, color.NRGBA{0, 0xff, 0, 0xff}, color.NRGBA{0xff, 0, 0, 0xff},
+	}
+	if len(palette) != len(want) {
+		t.Fatalf("palette %v, want %v", palette, want)
+	}
+	for i := range want {
+		if palette[i] != want[i] {
+			t.Errorf("palette[%d] = %v, want %v", i, palette[i], want[i])
+		}
+	}
+
+	paletted := a.Paletted(img)
+	for i, c := range colors {
+		if got := paletted.Palette[paletted.ColorIndexAt(i%4, i/4)]; got != c {
+			t.Errorf("(%d, %d) = %v, want %v", i%4, i/4, got, c)
+		}
+	}
+}
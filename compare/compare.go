@@ -0,0 +1,238 @@
+// Package compare は同じ大きさの2つの画像の違いを数値にする。
+// 非可逆な形式への変換や減色でどれだけ画質が落ちたかを測るために、
+// PSNR(ピーク信号対雑音比)とSSIM(構造的類似度)を計算する。
+//
+// 透明な画素は白の上に合成してから比べる。
+package compare
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/kouheiszk/png-reader/internal/imageutil"
+)
+
+// ErrSize は2つの画像の大きさが異なることを表す。
+var ErrSize = errors.New("compare: images have different sizes")
+
+// Result は2つの画像の比較結果
+type Result struct {
+	// PSNR はRGBの平均二乗誤差から求めたデシベル値。同一の画像では+Inf
+	PSNR float64
+	// SSIM は輝度の構造的類似度の平均。1が同一で、小さいほど違いが大きい
+	SSIM float64
+	// Pixels は画素数、Differing はNRGBA64で比べて値が異なる画素数
+	Pixels, Differing int
+}
+
+// Compare はaとbを比べる。大きさが異なる場合はErrSizeを返す。
+func Compare(a, b image.Image) (*Result, error) {
+	pa, pb, err := planes(a, b)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{
+		PSNR:   pa.psnr(pb),
+		SSIM:   ssim(pa.luma(), pb.luma(), pa.w, pa.h),
+		Pixels: pa.w * pa.h,
+	}
+	ab, bb := a.Bounds(), b.Bounds()
+	for y := 0; y < pa.h; y++ {
+		for x := 0; x < pa.w; x++ {
+			ca := color.NRGBA64Model.Convert(a.At(ab.Min.X+x, ab.Min.Y+y))
+			cb := color.NRGBA64Model.Convert(b.At(bb.Min.X+x, bb.Min.Y+y))
+			if ca != cb {
+				result.Differing++
+			}
+		}
+	}
+	return result, nil
+}
+
+// PSNR はaとbのPSNRを返す。
+func PSNR(a, b image.Image) (float64, error) {
+	pa, pb, err := planes(a, b)
+	if err != nil {
+		return 0, err
+	}
+	return pa.psnr(pb), nil
+}
+
+// SSIM はaとbのSSIMを返す。
+func SSIM(a, b image.Image) (float64, error) {
+	pa, pb, err := planes(a, b)
+	if err != nil {
+		return 0, err
+	}
+	return ssim(pa.luma(), pb.luma(), pa.w, pa.h), nil
+}
+
+// rgb は白の上に合成したRGBを0から1で持つ画像
+type rgb struct {
+	w, h    int
+	r, g, b []float64
+}
+
+func planes(a, b image.Image) (*rgb, *rgb, error) {
+	if a.Bounds().Dx() != b.Bounds().Dx() || a.Bounds().Dy() != b.Bounds().Dy() {
+		return nil, nil, ErrSize
+	}
+	return newRGB(a), newRGB(b), nil
+}
+
+func newRGB(img image.Image) *rgb {
+	bounds := img.Bounds()
+	p := &rgb{w: bounds.Dx(), h: bounds.Dy()}
+	n := p.w * p.h
+	p.r, p.g, p.b = make([]float64, n), make([]float64, n), make([]float64, n)
+	for y := 0; y < p.h; y++ {
+		for x := 0; x < p.w; x++ {
+			r, g, b, a := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			white := float64(0xffff - a)
+			i := y*p.w + x
+			p.r[i] = (float64(r) + white) / 0xffff
+			p.g[i] = (float64(g) + white) / 0xffff
+			p.b[i] = (float64(b) + white) / 0xffff
+		}
+	}
+	return p
+}
+
+func (p *rgb) psnr(q *rgb) float64 {
+	var sum float64
+	for i := range p.r {
+		dr, dg, db := p.r[i]-q.r[i], p.g[i]-q.g[i], p.b[i]-q.b[i]
+		sum += dr*dr + dg*dg + db*db
+	}
+	if sum == 0 || len(p.r) == 0 {
+		return math.Inf(1)
+	}
+	mse := sum / float64(3*len(p.r))
+	return 10 * math.Log10(1/mse)
+}
+
+// luma はITU-R BT.601の係数で輝度を求める。
+func (p *rgb) luma() []float64 {
+	y := make([]float64, len(p.r))
+	for i := range y {
+		y[i] = 0.299*p.r[i] + 0.587*p.g[i] + 0.114*p.b[i]
+	}
+	return y
+}
+
+// ssimの定数。Wang et al. (2004)の値を使う
+const (
+	ssimWindow = 11
+	ssimSigma  = 1.5
+	ssimC1     = 0.01 * 0.01
+	ssimC2     = 0.03 * 0.03
+)
+
+// ssim は11x11のガウス窓で求めた局所的なSSIMを、窓が画像に収まる範囲で平均する。
+// 窓より小さい画像では、窓を画像の短辺に合わせる。
+func ssim(x, y []float64, w, h int) float64 {
+	if w == 0 || h == 0 {
+		return 1
+	}
+	size := ssimWindow
+	if w < size {
+		size = w
+	}
+	if h < size {
+		size = h
+	}
+	kernel := gaussian(size, ssimSigma)
+
+	xy, xx, yy := make([]float64, len(x)), make([]float64, len(x)), make([]float64, len(x))
+	for i := range x {
+		xy[i], xx[i], yy[i] = x[i]*y[i], x[i]*x[i], y[i]*y[i]
+	}
+	mx, ow, oh := blur(x, w, h, kernel)
+	my, _, _ := blur(y, w, h, kernel)
+	sxx, _, _ := blur(xx, w, h, kernel)
+	syy, _, _ := blur(yy, w, h, kernel)
+	sxy, _, _ := blur(xy, w, h, kernel)
+
+	var sum float64
+	for i := range mx {
+		vx := sxx[i] - mx[i]*mx[i]
+		vy := syy[i] - my[i]*my[i]
+		cov := sxy[i] - mx[i]*my[i]
+		sum += (2*mx[i]*my[i] + ssimC1) * (2*cov + ssimC2) /
+			((mx[i]*mx[i] + my[i]*my[i] + ssimC1) * (vx + vy + ssimC2))
+	}
+	return sum / float64(ow*oh)
+}
+
+// gaussian は合計が1になる長さsizeのガウス関数の重みを返す。
+func gaussian(size int, sigma float64) []float64 {
+	k := make([]float64, size)
+	var sum float64
+	center := float64(size-1) / 2
+	for i := range k {
+		d := float64(i) - center
+		k[i] = math.Exp(-d * d / (2 * sigma * sigma))
+		sum += k[i]
+	}
+	for i := range k {
+		k[i] /= sum
+	}
+	return k
+}
+
+// blur はpを横、縦の順にkernelで畳み込み、窓が画像に収まる範囲の結果と大きさを返す。
+func blur(p []float64, w, h int, kernel []float64) ([]float64, int, int) {
+	ow, oh := w-len(kernel)+1, h-len(kernel)+1
+	tmp := make([]float64, ow*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < ow; x++ {
+			var s float64
+			for k, v := range kernel {
+				s += p[y*w+x+k] * v
+			}
+			tmp[y*ow+x] = s
+		}
+	}
+	out := make([]float64, ow*oh)
+	for y := 0; y < oh; y++ {
+		for x := 0; x < ow; x++ {
+			var s float64
+			for k, v := range kernel {
+				s += tmp[(y+k)*ow+x] * v
+			}
+			out[y*ow+x] = s
+		}
+	}
+	return out, ow, oh
+}
+
+// Digest はimgの大きさと画素から求めたSHA-256を返す。エンコードの方法やメタデータ、
+// 完全に透明な画素の色が違っても、見た目が同じ画像は同じ値になる。
+func Digest(img image.Image) [sha256.Size]byte {
+	b := img.Bounds()
+	h := sha256.New()
+	var buf [8]byte
+	binary.BigEndian.PutUint32(buf[0:], uint32(b.Dx()))
+	binary.BigEndian.PutUint32(buf[4:], uint32(b.Dy()))
+	h.Write(buf[:])
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := imageutil.NRGBA64(img.At(x, y))
+			if c.A == 0 {
+				c = color.NRGBA64{}
+			}
+			binary.BigEndian.PutUint16(buf[0:], c.R)
+			binary.BigEndian.PutUint16(buf[2:], c.G)
+			binary.BigEndian.PutUint16(buf[4:], c.B)
+			binary.BigEndian.PutUint16(buf[6:], c.A)
+			h.Write(buf[:])
+		}
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
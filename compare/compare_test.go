@@ -0,0 +1,122 @@
+package compare
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func uniform(c color.Color, w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// TestPSNR は一様な画像のPSNRが平均二乗誤差から求めた値になることを確認する。
+func TestPSNR(t *testing.T) {
+	tests := []struct {
+		a, b color.Color
+		want float64
+	}{
+		{color.Black, color.White, 0},
+		// 8ビットで1だけ異なれば20log10(255)
+		{color.Gray{128}, color.Gray{129}, 20 * math.Log10(255)},
+		{color.Gray{128}, color.Gray{128}, math.Inf(1)},
+	}
+	for _, tt := range tests {
+		got, err := PSNR(uniform(tt.a, 16, 16), uniform(tt.b, 16, 16))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want && math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("PSNR(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// TestSSIM は一様な画像では分散の項が消えて平均の項だけが残ることと、
+// ノイズが多いほどSSIMが下がることを確認する。
+func TestSSIM(t *testing.T) {
+	for _, v := range [][2]uint8{{0, 255}, {128, 64}, {128, 128}} {
+		got, err := SSIM(uniform(color.Gray{v[0]}, 20, 20), uniform(color.Gray{v[1]}, 20, 20))
+		if err != nil {
+			t.Fatal(err)
+		}
+		x, y := float64(v[0])/255, float64(v[1])/255
+		want := (2*x*y + ssimC1) / (x*x + y*y + ssimC1)
+		if math.Abs(got-want) > 1e-9 {
+			t.Errorf("SSIM of %d and %d = %v, want %v", v[0], v[1], got, want)
+		}
+	}
+
+	r := rand.New(rand.NewSource(1))
+	base := image.NewGray(image.Rect(0, 0, 32, 32))
+	r.Read(base.Pix)
+	noisy := func(amount int) *image.Gray {
+		img := image.NewGray(base.Rect)
+		for i, v := range base.Pix {
+			n := int(v) + r.Intn(2*amount+1) - amount
+			if n < 0 {
+				n = 0
+			} else if n > 255 {
+				n = 255
+			}
+			img.Pix[i] = uint8(n)
+		}
+		return img
+	}
+	slightly := noisy(4)
+	light, _ := SSIM(base, slightly)
+	heavy, _ := SSIM(base, noisy(64))
+	if !(1 > light && light > heavy && heavy > 0) {
+		t.Errorf("SSIM with light noise %v, heavy noise %v", light, heavy)
+	}
+	if s, _ := SSIM(slightly, base); math.Abs(s-light) > 1e-12 {
+		t.Errorf("SSIM is not symmetric: %v and %v", light, s)
+	}
+}
+
+// TestCompare は透明な画素を白として比べ、値の違う画素を数えることを確認する。
+func TestCompare(t *testing.T) {
+	transparent := image.NewNRGBA(image.Rect(5, 5, 15, 13))
+	white := uniform(color.White, 10, 8)
+	result, err := Compare(transparent, white)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !math.IsInf(result.PSNR, 1) || math.Abs(result.SSIM-1) > 1e-9 || result.Pixels != 80 || result.Differing != 80 {
+		t.Errorf("got %+v", result)
+	}
+
+	white.Set(3, 4, color.Black)
+	if result, _ := Compare(uniform(color.White, 10, 8), white); result.Differing != 1 || result.SSIM >= 1 {
+		t.Errorf("one black pixel: got %+v", result)
+	}
+
+	if _, err := Compare(white, uniform(color.White, 8, 10)); err != ErrSize {
+		t.Errorf("got %v, want ErrSize", err)
+	}
+}
+
+// TestDigest は見た目が同じ画像が同じ値に、大きさや画素が違う画像が違う値になることを確認する。
+func TestDigest(t *testing.T) {
+	a := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	b := image.NewNRGBA(image.Rect(2, 3, 6, 7))
+	b.SetNRGBA(2, 3, color.NRGBA{0xff, 0, 0, 0})
+	if Digest(a) != Digest(b) {
+		t.Error("fully transparent pixels of different colors changed the digest")
+	}
+	if Digest(a) == Digest(image.NewNRGBA(image.Rect(0, 0, 2, 8))) {
+		t.Error("images of different sizes have the same digest")
+	}
+	b.SetNRGBA(2, 3, color.NRGBA{0xff, 0, 0, 1})
+	if Digest(a) == Digest(b) {
+		t.Error("a visible pixel did not change the digest")
+	}
+}
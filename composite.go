@@ -0,0 +1,53 @@
+package pngreader
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+
+	"github.com/kouheiszk/png-reader/internal/imageutil"
+)
+
+// Flatten はimgをbackdropの上にdraw.Overで合成し、完全に不透明な画像を返す。
+// backdropの透明度は無視し、nilの場合は白にする。imgがNRGBA64またはRGBA64の場合は*image.RGBA64を、
+// それ以外の場合は*image.RGBAを返す。
+func Flatten(img image.Image, backdrop color.Color) draw.Image {
+	if backdrop == nil {
+		backdrop = color.White
+	}
+	b := imageutil.NRGBA64(backdrop)
+	b.A = 0xffff
+
+	var dst draw.Image
+	switch img.(type) {
+	case *image.NRGBA64, *image.RGBA64:
+		dst = image.NewRGBA64(img.Bounds())
+	default:
+		dst = image.NewRGBA(img.Bounds())
+	}
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(b), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Over)
+	return dst
+}
+
+// DecodeFlatten はrからPNG画像を読み込み、bKGDの背景色の上に合成した不透明な画像を返す。
+// bKGDがない場合はfallbackの上に合成する。
+func DecodeFlatten(r io.Reader, fallback color.Color) (image.Image, error) {
+	return new(Decoder).DecodeFlatten(r, fallback)
+}
+
+// DecodeFlatten はrからPNG画像を読み込み、bKGDの背景色の上に合成した不透明な画像を返す。
+// bKGDがない場合はfallbackの上に合成する。ApplyICCの場合、bKGDの色もsRGBに変換する。
+func (d *Decoder) DecodeFlatten(r io.Reader, fallback color.Color) (image.Image, error) {
+	p := &decoder{Decoder: d, seen: make(map[string]int)}
+	img, err := p.parse(r)
+	if err != nil {
+		return nil, err
+	}
+	backdrop := fallback
+	if p.background != nil {
+		backdrop = p.toSRGB(*p.background)
+	}
+	return Flatten(img, backdrop), nil
+}
@@ -0,0 +1,76 @@
+package pngreader
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// near はaとbの各チャンネルの差が1以内かどうかを返す。
+func near(a, b color.Color) bool {
+	ar, ag, ab, aa := a.RGBA()
+	br, bg, bb, ba := b.RGBA()
+	for _, d := range []int{int(ar>>8) - int(br>>8), int(ag>>8) - int(bg>>8), int(ab>>8) - int(bb>>8), int(aa>>8) - int(ba>>8)} {
+		if d < -1 || d > 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// TestFlatten は透明な画素が背景色に、不透明な画素がそのまま、半透明な画素が混ざった色になることを確認する。
+func TestFlatten(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(2, 3, 5, 4))
+	img.SetNRGBA(2, 3, color.NRGBA{0, 0, 0, 0})
+	img.SetNRGBA(3, 3, color.NRGBA{10, 20, 30, 255})
+	img.SetNRGBA(4, 3, color.NRGBA{255, 0, 0, 128})
+
+	tests := []struct {
+		backdrop color.Color
+		want     []color.RGBA
+	}{
+		{nil, []color.RGBA{{255, 255, 255, 255}, {10, 20, 30, 255}, {255, 127, 127, 255}}},
+		// 背景色の透明度は無視する
+		{color.NRGBA{0, 0, 255, 0}, []color.RGBA{{0, 0, 255, 255}, {10, 20, 30, 255}, {128, 0, 127, 255}}},
+	}
+	for _, tt := range tests {
+		dst := Flatten(img, tt.backdrop)
+		if dst.Bounds() != img.Bounds() {
+			t.Fatalf("bounds %v, want %v", dst.Bounds(), img.Bounds())
+		}
+		for i, want := range tt.want {
+			if got := dst.At(2+i, 3); !near(got, want) {
+				t.Errorf("backdrop %v, pixel %d: got %v, want %v", tt.backdrop, i, got, want)
+			}
+		}
+	}
+
+	if _, ok := Flatten(image.NewNRGBA64(img.Bounds()), nil).(*image.RGBA64); !ok {
+		t.Errorf("16-bit input is not flattened into an *image.RGBA64")
+	}
+}
+
+// TestDecodeFlatten はbKGDがあればその色の上に、なければfallbackの上に合成することを確認する。
+func TestDecodeFlatten(t *testing.T) {
+	ihdr := testIHDR(1, 1)
+	ihdr.data[9] = 6 // トゥルーカラーとアルファ
+	idat := testChunk{"IDAT", zlibBytes([]byte{0, 0, 0, 0, 0})}
+	bkgd := testChunk{"bKGD", []byte{0, 0, 0, 0xff, 0, 0}}
+
+	withBKGD, err := DecodeFlatten(bytes.NewReader(testPNG(ihdr, bkgd, idat, testChunk{"IEND", nil})), color.White)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := withBKGD.At(0, 0), (color.RGBA{0, 255, 0, 255}); !near(got, want) {
+		t.Errorf("bKGD: got %v, want %v", got, want)
+	}
+
+	fallback, err := DecodeFlatten(bytes.NewReader(testPNG(ihdr, idat, testChunk{"IEND", nil})), color.Black)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fallback.At(0, 0), (color.RGBA{0, 0, 0, 255}); !near(got, want) {
+		t.Errorf("fallback: got %v, want %v", got, want)
+	}
+}
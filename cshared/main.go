@@ -0,0 +1,153 @@
+//go:build cgo
+// +build cgo
+
+// cshared はpngreaderをCから使うためのc-sharedライブラリ。
+// 関数の宣言はpngreader.hを参照。
+//
+//	go build -buildmode=c-shared -o libpngreader.so ./cshared
+package main
+
+/*
+#include <stdlib.h>
+#define PNGREADER_BUILD
+#include "pngreader.h"
+*/
+import "C"
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"unsafe"
+
+	pngreader "github.com/kouheiszk/png-reader"
+)
+
+// c-sharedとしてビルドするにはmainパッケージが必要だが、mainは呼ばれない
+func main() {}
+
+//export png_info
+func png_info(data *C.uint8_t, length C.size_t, info *C.png_info_t, err **C.char) C.int {
+	if info == nil {
+		return fail(err, fmt.Errorf("info is NULL"))
+	}
+	b, e := goBytes(unsafe.Pointer(data), uint64(length))
+	if e != nil {
+		return fail(err, e)
+	}
+	i, e := pngreader.DecodeInfo(bytes.NewReader(b))
+	if e != nil {
+		return fail(err, e)
+	}
+	info.width = C.int(i.Width)
+	info.height = C.int(i.Height)
+	info.color_type = C.int(i.ColorType)
+	info.bit_depth = C.int(i.BitDepth)
+	info.interlace = 0
+	if i.Interlace {
+		info.interlace = 1
+	}
+	info.palette_size = C.int(i.PaletteSize)
+	info.chunk_count = C.int(len(i.Chunks))
+	return 0
+}
+
+//export png_decode
+func png_decode(data *C.uint8_t, length C.size_t, pixels **C.uint8_t, width, height *C.int, err **C.char) C.int {
+	if pixels == nil || width == nil || height == nil {
+		return fail(err, fmt.Errorf("output pointer is NULL"))
+	}
+	b, e := goBytes(unsafe.Pointer(data), uint64(length))
+	if e != nil {
+		return fail(err, e)
+	}
+	img, e := pngreader.Decode(bytes.NewReader(b))
+	if e != nil {
+		return fail(err, e)
+	}
+	// デコード結果はNRGBAかNRGBA64で、原点は(0, 0)
+	var pix []byte
+	switch m := img.(type) {
+	case *image.NRGBA:
+		pix = m.Pix
+	case *image.NRGBA64:
+		pix = make([]byte, len(m.Pix)/2)
+		for i := range pix {
+			pix[i] = m.Pix[2*i]
+		}
+	default:
+		return fail(err, fmt.Errorf("unexpected image type %T", img))
+	}
+
+	bounds := img.Bounds()
+	*pixels = (*C.uint8_t)(C.CBytes(pix))
+	*width = C.int(bounds.Dx())
+	*height = C.int(bounds.Dy())
+	return 0
+}
+
+//export png_encode
+func png_encode(pixels *C.uint8_t, width, height C.int, out **C.uint8_t, outLength *C.size_t, err **C.char) C.int {
+	if out == nil || outLength == nil {
+		return fail(err, fmt.Errorf("output pointer is NULL"))
+	}
+	data, e := encode(unsafe.Pointer(pixels), int(width), int(height))
+	if e != nil {
+		return fail(err, e)
+	}
+	*out = (*C.uint8_t)(C.CBytes(data))
+	*outLength = C.size_t(len(data))
+	return 0
+}
+
+// encode はpixelsのwidth*height*4バイトのRGBAをPNGにエンコードする。
+func encode(pixels unsafe.Pointer, width, height int) ([]byte, error) {
+	if pixels == nil {
+		return nil, errors.New("pixels is NULL")
+	}
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid image dimensions %dx%d", width, height)
+	}
+	pix, err := goBytes(pixels, uint64(width)*uint64(height)*4)
+	if err != nil {
+		return nil, err
+	}
+	m := &image.NRGBA{
+		Pix:    pix,
+		Stride: width * 4,
+		Rect:   image.Rect(0, 0, width, height),
+	}
+
+	var buffer bytes.Buffer
+	if err := pngreader.Encode(&buffer, m); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+//export png_free
+func png_free(p unsafe.Pointer) {
+	C.free(p)
+}
+
+// goBytes はCのバッファをGoのスライスにコピーする。
+// C.GoBytesはC.intの長さまでしかコピーできないため、それを超える長さはエラーにする。
+func goBytes(data unsafe.Pointer, length uint64) ([]byte, error) {
+	if length > math.MaxInt32 {
+		return nil, fmt.Errorf("buffer of %d bytes is too large", length)
+	}
+	if data == nil || length == 0 {
+		return nil, nil
+	}
+	return C.GoBytes(data, C.int(length)), nil
+}
+
+// fail はerrがNULLでなければエラーメッセージを書き込み、-1を返す。
+func fail(err **C.char, e error) C.int {
+	if err != nil {
+		*err = C.CString(e.Error())
+	}
+	return -1
+}
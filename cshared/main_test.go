@@ -0,0 +1,65 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"bytes"
+	"image/color"
+	"testing"
+	"unsafe"
+
+	pngreader "github.com/kouheiszk/png-reader"
+)
+
+func TestEncode(t *testing.T) {
+	pix := []byte{0xff, 0, 0, 0xff, 0x10, 0x20, 0x30, 0x40}
+	data, err := encode(unsafe.Pointer(&pix[0]), 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := pngreader.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x, want := range []color.NRGBA{{0xff, 0, 0, 0xff}, {0x10, 0x20, 0x30, 0x40}} {
+		if got := color.NRGBAModel.Convert(img.At(x, 0)); got != want {
+			t.Errorf("(%d, 0) = %v, want %v", x, got, want)
+		}
+	}
+}
+
+// TestEncodeErrors はNULLのピクセルや大きすぎる画像を、バッファを読む前にエラーにすることを確認する。
+func TestEncodeErrors(t *testing.T) {
+	pix := make([]byte, 4)
+	tests := []struct {
+		name          string
+		pixels        unsafe.Pointer
+		width, height int
+	}{
+		{"NULL", nil, 1, 1},
+		{"zero width", unsafe.Pointer(&pix[0]), 0, 1},
+		{"negative height", unsafe.Pointer(&pix[0]), 1, -1},
+		// 4*46341*46341は2^31を超える
+		{"too large", unsafe.Pointer(&pix[0]), 46341, 46341},
+		{"huge", unsafe.Pointer(&pix[0]), 1 << 30, 1 << 30},
+	}
+	for _, tt := range tests {
+		if _, err := encode(tt.pixels, tt.width, tt.height); err == nil {
+			t.Errorf("%s: no error", tt.name)
+		}
+	}
+}
+
+func TestGoBytes(t *testing.T) {
+	data := []byte("png")
+	if b, err := goBytes(unsafe.Pointer(&data[0]), 3); err != nil || string(b) != "png" {
+		t.Errorf("got %q, %v", b, err)
+	}
+	if b, err := goBytes(nil, 0); err != nil || b != nil {
+		t.Errorf("NULL: got %q, %v", b, err)
+	}
+	if _, err := goBytes(unsafe.Pointer(&data[0]), 1<<31); err == nil {
+		t.Error("2^31 bytes accepted")
+	}
+}
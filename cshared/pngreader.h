@@ -0,0 +1,48 @@
+/*
+ * pngreader.h はpngreaderのc-sharedライブラリのC ABI。
+ *
+ *   go build -buildmode=c-shared -o libpngreader.so ./cshared
+ *
+ * 成功した場合は0、失敗した場合は-1を返す。失敗した場合、errがNULLでなければ
+ * エラーメッセージを*errに書き込む。関数が確保したメモリはpng_freeで解放する。
+ */
+#ifndef PNGREADER_H
+#define PNGREADER_H
+
+#include <stddef.h>
+#include <stdint.h>
+
+typedef struct {
+	int width;
+	int height;
+	int color_type;
+	int bit_depth;
+	int interlace;
+	int palette_size;
+	int chunk_count;
+} png_info_t;
+
+/* cgoが生成する宣言はconstを持たないため、ライブラリ自身のビルドでは宣言しない */
+#ifndef PNGREADER_BUILD
+
+/* png_info はdataのPNGのヘッダを読み込み、infoに書き込む。画像データは展開しない。 */
+int png_info(const uint8_t *data, size_t len, png_info_t *info, char **err);
+
+/*
+ * png_decode はdataのPNGをデコードし、非乗算済みのRGBA各8ビットのピクセルを
+ * *pixels(width*height*4バイト)に返す。
+ */
+int png_decode(const uint8_t *data, size_t len, uint8_t **pixels, int *width, int *height, char **err);
+
+/*
+ * png_encode は非乗算済みのRGBA各8ビットのピクセルをPNGにエンコードし、*outに返す。
+ * pixelsはNULLにできず、width*height*4は2^31未満でなければならない。
+ */
+int png_encode(const uint8_t *pixels, int width, int height, uint8_t **out, size_t *out_len, char **err);
+
+/* png_free はこのライブラリが確保したメモリを解放する。 */
+void png_free(void *p);
+
+#endif
+
+#endif
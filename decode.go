@@ -0,0 +1,669 @@
+package pngreader
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"io/ioutil"
+
+	"github.com/kouheiszk/png-reader/icc"
+)
+
+type interlaceScan struct {
+	xFactor, yFactor, xOffset, yOffset int
+}
+
+// size はパスの幅と高さを返す。
+func (s interlaceScan) size(width, height int) (int, int) {
+	return (width - s.xOffset + s.xFactor - 1) / s.xFactor, (height - s.yOffset + s.yFactor - 1) / s.yFactor
+}
+
+// noInterlacing はインターレースなしの画像を1つのパスとして扱うためのもの
+var noInterlacing = []interlaceScan{{1, 1, 0, 0}}
+
+var interlacing = []interlaceScan{
+	{8, 8, 0, 0},
+	{8, 8, 4, 0},
+	{4, 8, 0, 4},
+	{4, 4, 2, 0},
+	{2, 4, 0, 2},
+	{2, 2, 1, 0},
+	{1, 2, 0, 1},
+}
+
+// validDepths はカラータイプごとに許されるビット深度
+var validDepths = map[int][]int{
+	0: {1, 2, 4, 8, 16},
+	2: {8, 16},
+	3: {1, 2, 4, 8},
+	4: {8, 16},
+	6: {8, 16},
+}
+
+func bitsPerPixel(colorType int, depth int) (int, error) {
+	depths, ok := validDepths[colorType]
+	if !ok {
+		return 0, fmt.Errorf("unknown color type")
+	}
+	valid := false
+	for _, d := range depths {
+		valid = valid || d == depth
+	}
+	if !valid {
+		return 0, fmt.Errorf("invalid bit depth %d for color type %d", depth, colorType)
+	}
+
+	switch colorType {
+	case 0:
+		return depth, nil
+	case 2:
+		return depth * 3, nil
+	case 3:
+		return depth, nil
+	case 4:
+		return depth * 2, nil
+	case 6:
+		return depth * 4, nil
+	default:
+		return 0, fmt.Errorf("unknown color type")
+	}
+}
+
+// pngSignature はPNGファイルの先頭8バイト
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// Decoder はPNGのデコード方法を設定する。ゼロ値のまま使用できる。
+type Decoder struct {
+	// Strict が真の場合、CRC、チャンクの順序、予約ビット、チャンク名、
+	// 長さの制限など仕様上の検証をすべて行い、違反をエラーとして扱う。
+	// Warnに渡す仕様違反ではない問題もエラーにする。
+	Strict bool
+
+	// Warn が設定されている場合、デコードを中断しない問題が見つかるたびに呼ばれる。
+	// Strictでない場合の仕様違反のほか、未知のアンシラリーチャンクや長すぎるテキストなど
+	// 仕様違反ではない問題も渡される。errは*DecodeErrorで、発生位置を持つ。
+	Warn func(err error)
+
+	// Limits はデコード時に許す資源の上限。ゼロ値では制限しない。
+	Limits Limits
+
+	// ApplyICC が真の場合、iCCPのICCプロファイルで画素をsRGBに変換する。
+	// プロファイルを解釈できない場合はWarnに渡し、変換せずに返す。Strictの場合はエラーにする。
+	ApplyICC bool
+
+	// AutoOrient が真の場合、eXIfのOrientationタグに従って画素を回転・反転し、
+	// 正しい向きの画像を返す。
+	AutoOrient bool
+}
+
+// Decode はrからPNG画像を読み込み、image.Imageとして返す。
+func Decode(r io.Reader) (image.Image, error) {
+	return new(Decoder).Decode(r)
+}
+
+// Decode はrからPNG画像を読み込み、image.Imageとして返す。
+func (d *Decoder) Decode(r io.Reader) (image.Image, error) {
+	p := &decoder{Decoder: d, seen: make(map[string]int)}
+	return p.parse(r)
+}
+
+// DecodeContext はDecodeと同じだが、ctxが終了するとチャンクや走査線の区切りで
+// デコードを中断し、ctx.Err()を返す。サーバーでリクエストの期限を守るために使う。
+func (d *Decoder) DecodeContext(ctx context.Context, r io.Reader) (image.Image, error) {
+	p := &decoder{Decoder: d, seen: make(map[string]int), ctx: ctx}
+	return p.parse(r)
+}
+
+// decoder は1回のデコードの状態を保持する。
+type decoder struct {
+	*Decoder
+
+	width, height    int
+	depth, colorType int
+	interlace        bool
+	bitsPerPixel     int
+	format           *pixelFormat
+
+	// seen はチャンクタイプごとの出現回数、lastChunk は直前のチャンクタイプ
+	seen      map[string]int
+	lastChunk string
+
+	// chunks はこれまでのチャンク数、textBytes はテキストチャンクのデータの合計
+	chunks    int
+	textBytes int
+
+	// background はbKGDで指定された背景色
+	background *color.NRGBA64
+
+	// iccName、iccProfile はiCCPのプロファイル名と展開したプロファイル、
+	// profile はApplyICCの場合に解釈したプロファイル
+	iccName    string
+	iccProfile []byte
+	profile    *icc.Profile
+
+	// exif はeXIfのデータ、orientation はそのOrientationタグの値(eXIfがない場合は0)
+	exif        []byte
+	orientation int
+
+	// idat は読み込んだIDATチャンク、chunkInfos はすべてのチャンクの情報
+	idat       []*chunk
+	chunkInfos []ChunkInfo
+
+	// headerOnly が真の場合、画像データを展開せずに終了する
+	headerOnly bool
+	// region が空でなければ、画像データのうちこの範囲だけを展開する
+	region image.Rectangle
+	// filters がnilでなければ、各走査線のフィルタタイプを記録する
+	filters *FilterStats
+	// ctx がnilでなければ、終了したときにデコードを中断する
+	ctx context.Context
+
+	// findings は見つかった仕様違反、stage はデコードの進行段階、loc は現在位置
+	findings []finding
+	stage    int
+	loc      location
+}
+
+func (d *decoder) parse(r io.Reader) (image.Image, error) {
+	buffer := new(bytes.Buffer)
+	if _, err := buffer.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	reader := &chunkReader{buffer: buffer}
+
+	//　PNGシグネチャの読み込み
+	signature, err := reader.next(8)
+	if err != nil || string(signature) != pngSignature {
+		return nil, d.fail(checkSignature, "not a PNG")
+	}
+	d.stage = stageHeader
+
+	// IHDRチャンクの読み込み
+	c, err := reader.readChunk()
+	if err != nil {
+		d.loc = location{chunkType: "IHDR", chunkIndex: 1, offset: reader.offset}
+		return nil, d.fail(checkChunkLayout, "truncated IHDR chunk: %w", io.ErrUnexpectedEOF)
+	}
+	if err := d.checkChunk(c); err != nil {
+		return nil, err
+	}
+	if c.chunkType != "IHDR" {
+		return nil, d.fail(checkChunkOrdering, "invalid")
+	}
+	if err := d.parseIHDR(c); err != nil {
+		return nil, err
+	}
+	d.stage = stageChunks
+
+	// IDATチャンクの読み込み
+	idatLength := 0
+	for c.chunkType != "IEND" {
+		if err := d.canceled(); err != nil {
+			return nil, err
+		}
+		c, err = reader.readChunk()
+		if err == io.EOF {
+			d.loc = location{offset: reader.offset}
+			return nil, d.fail(checkIEND, "missing IEND chunk")
+		}
+		if err != nil {
+			d.loc = location{chunkType: c.chunkType, chunkIndex: d.seen[c.chunkType] + 1, offset: c.offset}
+			return nil, d.wrap(checkChunkLayout, err)
+		}
+		if err := d.checkChunk(c); err != nil {
+			return nil, err
+		}
+
+		switch c.chunkType {
+		case "PLTE":
+			err = d.parsePLTE(c)
+		case "tRNS":
+			err = d.parsetRNS(c)
+		case "tIME":
+			err = d.parsetIME(c)
+		case "bKGD":
+			err = d.parsebKGD(c)
+		case "iCCP":
+			err = d.parseiCCP(c)
+		case "eXIf":
+			err = d.parseeXIf(c)
+		case "IDAT":
+			d.idat = append(d.idat, c)
+			idatLength += len(c.data)
+		case "IEND":
+			if c.length != 0 {
+				err = d.problem(checkIEND, "bad IEND length")
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if buffer.Len() > 0 {
+		d.loc.offset = reader.offset
+		if err := d.problem(checkIEND, "%d bytes of data after IEND chunk", buffer.Len()); err != nil {
+			return nil, err
+		}
+	}
+	if d.colorType == 3 && len(d.format.palette) == 0 {
+		return nil, d.fail(checkPLTE, "missing PLTE chunk")
+	}
+	if d.headerOnly {
+		return nil, nil
+	}
+	d.stage = stageData
+	if idatLength == 0 {
+		return nil, d.fail(checkIDAT, "missing IDAT chunk")
+	}
+
+	// 画像データの展開
+	img, err := d.decodePixels()
+	if err != nil {
+		return nil, err
+	}
+	d.stage = stageDone
+
+	if d.profile != nil {
+		img = d.profile.Transform(img)
+	}
+	if d.AutoOrient {
+		img = Orient(img, d.orientation)
+	}
+
+	return img, nil
+}
+
+// checkChunk はチャンクの名前、長さ、CRC、出現順序を検証する。
+func (d *decoder) checkChunk(c *chunk) error {
+	c.index = d.seen[c.chunkType] + 1
+	d.loc = location{chunkType: c.chunkType, chunkIndex: c.index, offset: c.offset}
+	d.chunkInfos = append(d.chunkInfos, ChunkInfo{Type: c.chunkType, Offset: c.offset, Length: int(c.length)})
+	defer func() {
+		d.seen[c.chunkType]++
+		d.lastChunk = c.chunkType
+	}()
+
+	if err := d.checkLimits(c); err != nil {
+		return err
+	}
+
+	if !validChunkName(c.chunkType) {
+		return d.problem(checkChunkNaming, "invalid chunk name %q", c.chunkType)
+	}
+	if c.chunkType[2]&0x20 != 0 {
+		if err := d.problem(checkChunkNaming, "reserved bit set in chunk name %q", c.chunkType); err != nil {
+			return err
+		}
+	}
+	if c.length > maxDimension {
+		if err := d.problem(checkChunkLayout, "%s chunk length %d exceeds 2^31-1", c.chunkType, c.length); err != nil {
+			return err
+		}
+	}
+	if computed := c.computeCRC(); computed != c.crc {
+		d.loc.offset = c.crcOffset()
+		err := d.problemError(checkCRC, &CRCError{ChunkType: c.chunkType, Stored: c.crc, Computed: computed})
+		d.loc.offset = c.offset
+		if err != nil {
+			return err
+		}
+	}
+
+	rule, known := chunkRules[c.chunkType]
+	if !known {
+		if isCritical(c.chunkType) {
+			return d.problemError(checkChunkNaming, unsupportedf("unknown critical chunk %s", c.chunkType))
+		}
+		return d.warnError(unsupportedf("unknown ancillary chunk %s", c.chunkType))
+	}
+	if !rule.multiple && d.seen[c.chunkType] > 0 {
+		return d.problem(checkChunkOrdering, "duplicate %s chunk", c.chunkType)
+	}
+	if c.chunkType == "IDAT" && d.seen["IDAT"] > 0 && d.lastChunk != "IDAT" {
+		return d.problem(checkChunkOrdering, "non-consecutive IDAT chunks")
+	}
+	if rule.beforeIDAT && d.seen["IDAT"] > 0 {
+		return d.problem(checkChunkOrdering, "%s chunk after IDAT", c.chunkType)
+	}
+	if rule.beforePLTE && d.seen["PLTE"] > 0 {
+		return d.problem(checkChunkOrdering, "%s chunk after PLTE", c.chunkType)
+	}
+	if rule.afterPLTE && d.colorType == 3 && d.seen["PLTE"] == 0 {
+		return d.problem(checkChunkOrdering, "%s chunk before PLTE", c.chunkType)
+	}
+	if c.chunkType == "PLTE" {
+		for t, r := range chunkRules {
+			if r.afterPLTE && d.seen[t] > 0 {
+				return d.problem(checkChunkOrdering, "PLTE chunk after %s", t)
+			}
+		}
+	}
+	switch c.chunkType {
+	case "tEXt", "zTXt", "iTXt":
+		keyword := c.data
+		if i := bytes.IndexByte(keyword, 0); i >= 0 {
+			keyword = keyword[:i]
+		}
+		if !validKeyword(keyword) {
+			return d.problem(checkText, "invalid %s keyword %q", c.chunkType, keyword)
+		}
+		if c.length > longText {
+			if err := d.warn("%s chunk %q has %d bytes of text", c.chunkType, keyword, c.length); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// longText はこれを超える長さのテキストチャンクを警告の対象にする。
+// 仕様上の上限はないが、通常のメタデータとしては大きすぎる。
+const longText = 64 * 1024
+
+// validKeyword はテキストチャンクのキーワードが1-79文字のLatin-1で、
+// 先頭末尾や連続した空白を含まないかどうかを返す。
+func validKeyword(keyword []byte) bool {
+	if len(keyword) < 1 || len(keyword) > 79 {
+		return false
+	}
+	if keyword[0] == ' ' || keyword[len(keyword)-1] == ' ' || bytes.Contains(keyword, []byte("  ")) {
+		return false
+	}
+	for _, b := range keyword {
+		if (b < 32 || b > 126) && b < 161 {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *decoder) parseIHDR(c *chunk) error {
+	if c.length != 13 {
+		return d.fail(checkIHDR, "bad IHDR length")
+	}
+	header := c.data
+	rawWidth := binary.BigEndian.Uint32(header[0:4])
+	rawHeight := binary.BigEndian.Uint32(header[4:8])
+	if rawWidth == 0 || rawHeight == 0 || rawWidth > maxDimension || rawHeight > maxDimension {
+		return d.fail(checkIHDR, "invalid image dimensions %dx%d", rawWidth, rawHeight)
+	}
+	d.width, d.height = int(rawWidth), int(rawHeight)
+	if err := d.checkDimensions(); err != nil {
+		return err
+	}
+	if !d.region.Empty() && !d.region.Overlaps(image.Rect(0, 0, d.width, d.height)) {
+		return d.errorAt(fmt.Errorf("%w: %v is outside the %dx%d image", ErrRegion, d.region, d.width, d.height))
+	}
+	d.depth = int(header[8])
+	d.colorType = int(header[9])
+	if int(header[10]) != 0 {
+		return d.wrap(checkIHDR, unsupportedf("unknown compression method %d", header[10]))
+	}
+	if int(header[11]) != 0 {
+		return d.wrap(checkIHDR, unsupportedf("unknown filter method %d", header[11]))
+	}
+	if int(header[12]) > 1 {
+		if err := d.problemError(checkIHDR, unsupportedf("unknown interlace method %d", header[12])); err != nil {
+			return err
+		}
+	}
+	d.interlace = int(header[12]) == 1
+
+	// 展開後に必要なサイズがintに収まるかを確認する
+	var err error
+	d.bitsPerPixel, err = bitsPerPixel(d.colorType, d.depth)
+	if err != nil {
+		return d.wrap(checkIHDR, err)
+	}
+	if _, err := d.expectedBytes(); err != nil {
+		return d.wrap(checkIHDR, limitf("image too large: %v", err))
+	}
+	if _, err := pixelBytes(d.width, d.height, 8); err != nil {
+		return d.wrap(checkIHDR, limitf("image too large: %v", err))
+	}
+	d.format = &pixelFormat{colorType: d.colorType, depth: d.depth}
+
+	return nil
+}
+
+func (d *decoder) parsePLTE(c *chunk) error {
+	if c.length%3 != 0 || c.length == 0 || c.length/3 > 256 {
+		return d.fail(checkPLTE, "bad PLTE length")
+	}
+	if d.colorType == 0 || d.colorType == 4 {
+		if err := d.problem(checkPLTE, "PLTE chunk not allowed for color type %d", d.colorType); err != nil {
+			return err
+		}
+	}
+	if d.colorType == 3 && int(c.length/3) > 1<<uint(d.depth) {
+		if err := d.problem(checkPLTE, "PLTE has %d entries, more than bit depth %d allows", c.length/3, d.depth); err != nil {
+			return err
+		}
+	}
+	d.format.palette = make([]color.NRGBA, c.length/3)
+	for i := range d.format.palette {
+		d.format.palette[i] = color.NRGBA{c.data[3*i], c.data[3*i+1], c.data[3*i+2], 255}
+	}
+	return nil
+}
+
+func (d *decoder) parsetRNS(c *chunk) error {
+	format := d.format
+	switch d.colorType {
+	case 0:
+		if c.length != 2 {
+			return d.fail(checkTRNS, "bad tRNS length")
+		}
+		format.hasTransparent = true
+		format.transparent[0] = binary.BigEndian.Uint16(c.data)
+	case 2:
+		if c.length != 6 {
+			return d.fail(checkTRNS, "bad tRNS length")
+		}
+		format.hasTransparent = true
+		for i := range format.transparent {
+			format.transparent[i] = binary.BigEndian.Uint16(c.data[2*i:])
+		}
+	case 3:
+		if int(c.length) > len(format.palette) {
+			return d.fail(checkTRNS, "bad tRNS length")
+		}
+		for i, a := range c.data {
+			format.palette[i].A = a
+		}
+	default:
+		return d.fail(checkTRNS, "tRNS chunk not allowed for color type %d", d.colorType)
+	}
+	return nil
+}
+
+// parsebKGD は背景色を読み込み、16ビットの色としてd.backgroundに保持する。
+func (d *decoder) parsebKGD(c *chunk) error {
+	expand := func(v uint16) uint16 {
+		if d.depth == 16 {
+			return v
+		}
+		return uint16(uint32(v) * 0xffff / (1<<uint(d.depth) - 1))
+	}
+	switch d.colorType {
+	case 0, 4:
+		if c.length != 2 {
+			return d.problem(checkBKGD, "bad bKGD length")
+		}
+		gray := expand(binary.BigEndian.Uint16(c.data))
+		d.background = &color.NRGBA64{gray, gray, gray, 0xffff}
+	case 2, 6:
+		if c.length != 6 {
+			return d.problem(checkBKGD, "bad bKGD length")
+		}
+		d.background = &color.NRGBA64{
+			expand(binary.BigEndian.Uint16(c.data[0:])),
+			expand(binary.BigEndian.Uint16(c.data[2:])),
+			expand(binary.BigEndian.Uint16(c.data[4:])),
+			0xffff,
+		}
+	case 3:
+		if c.length != 1 {
+			return d.problem(checkBKGD, "bad bKGD length")
+		}
+		index := int(c.data[0])
+		if index >= len(d.format.palette) {
+			return d.problem(checkBKGD, "bKGD palette index %d out of range", index)
+		}
+		p := d.format.palette[index]
+		d.background = &color.NRGBA64{uint16(p.R) * 0x101, uint16(p.G) * 0x101, uint16(p.B) * 0x101, 0xffff}
+	}
+	return nil
+}
+
+func (d *decoder) parsetIME(c *chunk) error {
+	if c.length != 7 {
+		return d.problem(checkTIME, "bad tIME length")
+	}
+	month, day := c.data[2], c.data[3]
+	hour, minute, second := c.data[4], c.data[5], c.data[6]
+	if month < 1 || month > 12 || day < 1 || day > 31 || hour > 23 || minute > 59 || second > 60 {
+		return d.problem(checkTIME, "tIME value %d-%02d-%02d %02d:%02d:%02d out of range",
+			binary.BigEndian.Uint16(c.data), month, day, hour, minute, second)
+	}
+	return nil
+}
+
+// passes は画像のインターレースパスを返す。
+func (d *decoder) passes() []interlaceScan {
+	if d.interlace {
+		return interlacing
+	}
+	return noInterlacing
+}
+
+// expectedBytes は展開後の画像データのバイト数を計算する。
+func (d *decoder) expectedBytes() (int, error) {
+	expected := 0
+	for _, p := range d.passes() {
+		passWidth, passHeight := p.size(d.width, d.height)
+		// 幅か高さが0のパスはデータを持たない
+		if passWidth <= 0 || passHeight <= 0 {
+			continue
+		}
+		n, err := filteredBytes(passWidth, passHeight, d.bitsPerPixel)
+		if err == nil {
+			expected, err = add(expected, n)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return expected, nil
+}
+
+// decodePixels はIDATのデータを展開しながら1行ずつフィルタを適用し、色情報を抽出する。
+func (d *decoder) decodePixels() (image.Image, error) {
+	idat := &idatReader{chunks: d.idat}
+	zr, err := zlib.NewReader(idat)
+	if err != nil {
+		d.loc = idat.location()
+		return nil, d.wrap(checkCompression, err)
+	}
+	defer zr.Close()
+
+	expected, _ := d.expectedBytes()
+	bytesPerPixel := (d.bitsPerPixel + 7) / 8
+	read := 0
+
+	bounds := image.Rect(0, 0, d.width, d.height)
+	if !d.region.Empty() {
+		// 画像と重ならない範囲はparseIHDRで拒否している
+		bounds = d.region.Intersect(bounds)
+	}
+
+	// フィルタタイプの適用と色情報の抽出
+	img := d.format.newImage(bounds)
+	for _, p := range d.passes() {
+		passWidth, passHeight := p.size(d.width, d.height)
+		if passWidth <= 0 || passHeight <= 0 {
+			continue
+		}
+		stride, _ := rowBytes(passWidth, d.bitsPerPixel)
+		current := make([]byte, 1+stride)
+		prev := make([]byte, 1+stride)
+
+		for y := 0; y < passHeight; y++ {
+			if err := d.canceled(); err != nil {
+				return nil, err
+			}
+			n, err := readFull(zr, current)
+			read += n
+			d.loc = idat.location()
+			if err == io.EOF {
+				return nil, d.fail(checkIDAT, "not enough pixel data: expected %d bytes, got %d", expected, read)
+			}
+			if err != nil {
+				return nil, d.wrap(checkCompression, err)
+			}
+
+			if err := unfilterRow(int(current[0]), current[1:], prev[1:], bytesPerPixel); err != nil {
+				return nil, d.wrap(checkFiltering, err)
+			}
+			if d.filters != nil {
+				d.filters.add(int(current[0]))
+			}
+
+			// 対応したピクセルに再配置する。範囲外の行はフィルタの適用だけを行う
+			if dy := p.yOffset + y*p.yFactor; dy >= bounds.Min.Y && dy < bounds.Max.Y {
+				if err := d.format.convertRow(img, current[1:], dy, p.xOffset, p.xFactor, passWidth); err != nil {
+					return nil, d.wrap(checkPLTE, err)
+				}
+				// インターレースのない画像の範囲を展開し終えたら、残りのデータは読まない
+				if !d.region.Empty() && !d.interlace && dy == bounds.Max.Y-1 {
+					return img, nil
+				}
+			}
+			prev, current = current, prev
+		}
+	}
+
+	// 余分なデータがないことと、zlibのチェックサムを確認する
+	extra, err := io.Copy(ioutil.Discard, zr)
+	d.loc = idat.location()
+	if err != nil {
+		return nil, d.wrap(checkCompression, err)
+	}
+	if extra > 0 {
+		return nil, d.fail(checkIDAT, "too much pixel data: expected %d bytes, got %d", expected, read+int(extra))
+	}
+
+	return img, nil
+}
+
+// canceled はctxが終了していればそのエラーを返す。
+func (d *decoder) canceled() error {
+	if d.ctx == nil {
+		return nil
+	}
+	return d.ctx.Err()
+}
+
+// readFull はbufを満たすまでrから読み込む。
+// io.ReadFullと違い、途中でストリームが終わった場合もio.EOFを返すため、
+// データ不足とストリームの破損を区別できる。
+func readFull(r io.Reader, buf []byte) (int, error) {
+	n := 0
+	for n < len(buf) {
+		m, err := r.Read(buf[n:])
+		n += m
+		if err == io.EOF && n == len(buf) {
+			break
+		}
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
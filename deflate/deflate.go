@@ -0,0 +1,461 @@
+// Package deflate はzlibストリームを展開しながら、DEFLATEのブロックの構造と
+// リテラルと一致(長さと距離の組)の数を調べる。compress/flateはこれらを公開しないので、
+// RFC 1950とRFC 1951に従って自前で展開する。速さより分かりやすさを優先している。
+package deflate
+
+import (
+	"errors"
+	"fmt"
+	"hash/adler32"
+	"io"
+	"math"
+)
+
+// ErrChecksum はzlibのAdler-32チェックサムが一致しないことを表す。
+var ErrChecksum = errors.New("deflate: adler32 checksum mismatch")
+
+// BlockType はDEFLATEのブロックの種類
+type BlockType int
+
+const (
+	Stored BlockType = iota
+	Fixed
+	Dynamic
+)
+
+func (t BlockType) String() string {
+	switch t {
+	case Stored:
+		return "stored"
+	case Fixed:
+		return "fixed"
+	case Dynamic:
+		return "dynamic"
+	}
+	return fmt.Sprintf("BlockType(%d)", int(t))
+}
+
+// MarshalText はJSONでブロックの種類を名前で出力するために実装する。
+func (t BlockType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// Block は1つのブロックの集計
+type Block struct {
+	Type BlockType `json:"type"`
+	// Bits はヘッダとハフマン符号表を含めた、圧縮データでのブロックの長さ(ビット数)
+	Bits int64 `json:"bits"`
+	// Size はブロックを展開したバイト数
+	Size int `json:"size"`
+	// Literals はリテラルで表されたバイト数。格納ブロックのバイトもリテラルに数える
+	Literals int `json:"literals"`
+	// Matches は一致の数、MatchBytes は一致で表されたバイト数
+	Matches    int `json:"matches"`
+	MatchBytes int `json:"matchBytes"`
+}
+
+// Stats はzlibストリーム全体の集計
+type Stats struct {
+	// Level はzlibヘッダのFLEVEL。0が最速、1が高速、2が既定、3が最高圧縮で、圧縮した側の自己申告
+	Level int `json:"level"`
+	// WindowSize はzlibヘッダで宣言された窓の大きさ
+	WindowSize int `json:"windowSize"`
+	// CompressedSize はヘッダとチェックサムを含めたストリームのバイト数、Size は展開したバイト数
+	CompressedSize int `json:"compressedSize"`
+	Size           int `json:"size"`
+
+	Blocks     []Block `json:"blocks"`
+	Literals   int     `json:"literals"`
+	Matches    int     `json:"matches"`
+	MatchBytes int     `json:"matchBytes"`
+
+	// Entropy は展開したデータのバイトの出現頻度から求めた、1バイトあたりのエントロピー(ビット)
+	Entropy float64 `json:"entropy"`
+}
+
+// Analyze はrのzlibストリームを展開して集計する。wがnilでなければ展開したデータを書き込む。
+// 既定の辞書(FDICT)を使うストリームには対応しない。
+func Analyze(r io.ByteReader, w io.Writer) (*Stats, error) {
+	br := &bitReader{r: r}
+	cmf, err := br.byte()
+	if err != nil {
+		return nil, err
+	}
+	flg, err := br.byte()
+	if err != nil {
+		return nil, err
+	}
+	if cmf&0x0f != 8 || cmf>>4 > 7 || (uint16(cmf)<<8|uint16(flg))%31 != 0 {
+		return nil, fmt.Errorf("deflate: invalid zlib header")
+	}
+	if flg&0x20 != 0 {
+		return nil, fmt.Errorf("deflate: preset dictionary is not supported")
+	}
+	stats := &Stats{Level: int(flg >> 6), WindowSize: 1 << (cmf>>4 + 8)}
+
+	in := &inflater{br: br}
+	for final := false; !final; {
+		start := br.bitPos()
+		bits, err := br.bits(3)
+		if err != nil {
+			return nil, err
+		}
+		final = bits&1 != 0
+		block := Block{Type: BlockType(bits >> 1)}
+		switch block.Type {
+		case Stored:
+			err = in.stored(&block)
+		case Fixed:
+			err = in.huffmanBlock(&block, fixedLiteral, fixedDistance)
+		case Dynamic:
+			var lit, dist *huffman
+			if lit, dist, err = in.dynamicTables(); err == nil {
+				err = in.huffmanBlock(&block, lit, dist)
+			}
+		default:
+			err = fmt.Errorf("deflate: invalid block type 3")
+		}
+		if err != nil {
+			return nil, err
+		}
+		block.Bits = br.bitPos() - start
+		stats.Blocks = append(stats.Blocks, block)
+		stats.Literals += block.Literals
+		stats.Matches += block.Matches
+		stats.MatchBytes += block.MatchBytes
+	}
+
+	// 最後のブロックの後はバイト境界に揃えてAdler-32が続く
+	br.align()
+	var sum uint32
+	for i := 0; i < 4; i++ {
+		b, err := br.byte()
+		if err != nil {
+			return nil, err
+		}
+		sum = sum<<8 | uint32(b)
+	}
+	if sum != adler32.Checksum(in.out) {
+		return nil, ErrChecksum
+	}
+
+	stats.CompressedSize = int(br.read)
+	stats.Size = len(in.out)
+	stats.Entropy = entropy(in.out)
+	if w != nil {
+		if _, err := w.Write(in.out); err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
+
+// entropy はdataのバイトの出現頻度から1バイトあたりのエントロピーを求める。
+func entropy(data []byte) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+	var counts [256]int
+	for _, b := range data {
+		counts[b]++
+	}
+	var h float64
+	for _, n := range counts {
+		if n > 0 {
+			p := float64(n) / float64(len(data))
+			h -= p * math.Log2(p)
+		}
+	}
+	return h
+}
+
+// bitReader はDEFLATEのビット列を下位ビットから読む。
+type bitReader struct {
+	r    io.ByteReader
+	buf  uint32
+	n    uint
+	read int64 // 読み込んだバイト数
+}
+
+func (b *bitReader) need(n uint) error {
+	for b.n < n {
+		c, err := b.r.ReadByte()
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		b.buf |= uint32(c) << b.n
+		b.n += 8
+		b.read++
+	}
+	return nil
+}
+
+// bits はnビットを読み、最初に読んだビットを最下位にした値を返す。
+func (b *bitReader) bits(n uint) (uint32, error) {
+	if err := b.need(n); err != nil {
+		return 0, err
+	}
+	v := b.buf & (1<<n - 1)
+	b.buf >>= n
+	b.n -= n
+	return v, nil
+}
+
+// align は読みかけのバイトの残りのビットを捨てる。
+func (b *bitReader) align() {
+	b.buf >>= b.n % 8
+	b.n -= b.n % 8
+}
+
+func (b *bitReader) byte() (byte, error) {
+	v, err := b.bits(8)
+	return byte(v), err
+}
+
+// bitPos はこれまでに読んだビット数を返す。
+func (b *bitReader) bitPos() int64 {
+	return b.read*8 - int64(b.n)
+}
+
+// huffman は符号長ごとの符号の数と、符号順に並べたシンボルで表した標準ハフマン符号
+type huffman struct {
+	count  [16]int
+	symbol []int
+}
+
+// newHuffman はシンボルごとの符号長からハフマン符号を作る。符号長0のシンボルは使われない。
+func newHuffman(lengths []uint8) (*huffman, error) {
+	h := new(huffman)
+	for _, l := range lengths {
+		h.count[l]++
+	}
+	left := 1
+	for l := 1; l < len(h.count); l++ {
+		left <<= 1
+		if left -= h.count[l]; left < 0 {
+			return nil, fmt.Errorf("deflate: over-subscribed Huffman code")
+		}
+	}
+	var offsets [16]int
+	for l := 1; l < len(h.count)-1; l++ {
+		offsets[l+1] = offsets[l] + h.count[l]
+	}
+	h.symbol = make([]int, offsets[15]+h.count[15])
+	for s, l := range lengths {
+		if l != 0 {
+			h.symbol[offsets[l]] = s
+			offsets[l]++
+		}
+	}
+	return h, nil
+}
+
+// decode はhの符号を1つ読み、シンボルを返す。
+func (b *bitReader) decode(h *huffman) (int, error) {
+	code, first, index := 0, 0, 0
+	for l := 1; l < len(h.count); l++ {
+		v, err := b.bits(1)
+		if err != nil {
+			return 0, err
+		}
+		code |= int(v)
+		count := h.count[l]
+		if code-first < count {
+			return h.symbol[index+code-first], nil
+		}
+		index += count
+		first = (first + count) << 1
+		code <<= 1
+	}
+	return 0, fmt.Errorf("deflate: invalid Huffman code")
+}
+
+// 長さと距離のシンボルの基準値と拡張ビット数
+var (
+	lengthBase  = [29]int{3, 4, 5, 6, 7, 8, 9, 10, 11, 13, 15, 17, 19, 23, 27, 31, 35, 43, 51, 59, 67, 83, 99, 115, 131, 163, 195, 227, 258}
+	lengthExtra = [29]uint{0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 2, 2, 2, 2, 3, 3, 3, 3, 4, 4, 4, 4, 5, 5, 5, 5, 0}
+	distBase    = [30]int{1, 2, 3, 4, 5, 7, 9, 13, 17, 25, 33, 49, 65, 97, 129, 193, 257, 385, 513, 769, 1025, 1537, 2049, 3073, 4097, 6145, 8193, 12289, 16385, 24577}
+	distExtra   = [30]uint{0, 0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 6, 7, 7, 8, 8, 9, 9, 10, 10, 11, 11, 12, 12, 13, 13}
+)
+
+// codeLengthOrder は動的ブロックで符号長の符号長が並ぶ順番
+var codeLengthOrder = [19]int{16, 17, 18, 0, 8, 7, 9, 6, 10, 5, 11, 4, 12, 3, 13, 2, 14, 1, 15}
+
+// fixedLiteral、fixedDistance は固定ハフマンブロックの符号
+var fixedLiteral, fixedDistance = func() (*huffman, *huffman) {
+	lengths := make([]uint8, 288)
+	for i := range lengths {
+		switch {
+		case i < 144:
+			lengths[i] = 8
+		case i < 256:
+			lengths[i] = 9
+		case i < 280:
+			lengths[i] = 7
+		default:
+			lengths[i] = 8
+		}
+	}
+	lit, _ := newHuffman(lengths)
+	dist := make([]uint8, 30)
+	for i := range dist {
+		dist[i] = 5
+	}
+	d, _ := newHuffman(dist)
+	return lit, d
+}()
+
+// inflater は展開の状態。一致は過去の出力を参照するので、出力はすべて保持する
+type inflater struct {
+	br  *bitReader
+	out []byte
+}
+
+func (in *inflater) stored(block *Block) error {
+	in.br.align()
+	v, err := in.br.bits(32)
+	if err != nil {
+		return err
+	}
+	length, nlength := int(v&0xffff), int(v>>16)
+	if length != ^nlength&0xffff {
+		return fmt.Errorf("deflate: stored block length does not match its complement")
+	}
+	for i := 0; i < length; i++ {
+		c, err := in.br.byte()
+		if err != nil {
+			return err
+		}
+		in.out = append(in.out, c)
+	}
+	block.Size, block.Literals = length, length
+	return nil
+}
+
+// dynamicTables は動的ブロックのヘッダからリテラル・長さと距離のハフマン符号を読む。
+func (in *inflater) dynamicTables() (*huffman, *huffman, error) {
+	br := in.br
+	v, err := br.bits(14)
+	if err != nil {
+		return nil, nil, err
+	}
+	nlit, ndist, nclen := int(v&0x1f)+257, int(v>>5&0x1f)+1, int(v>>10)+4
+	if nlit > 286 || ndist > 30 {
+		return nil, nil, fmt.Errorf("deflate: too many length or distance codes")
+	}
+	var clen [19]uint8
+	for i := 0; i < nclen; i++ {
+		l, err := br.bits(3)
+		if err != nil {
+			return nil, nil, err
+		}
+		clen[codeLengthOrder[i]] = uint8(l)
+	}
+	ch, err := newHuffman(clen[:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lengths := make([]uint8, 0, nlit+ndist)
+	for len(lengths) < nlit+ndist {
+		sym, err := br.decode(ch)
+		if err != nil {
+			return nil, nil, err
+		}
+		if sym < 16 {
+			lengths = append(lengths, uint8(sym))
+			continue
+		}
+		var value uint8
+		var repeat uint32
+		switch sym {
+		case 16:
+			if len(lengths) == 0 {
+				return nil, nil, fmt.Errorf("deflate: repeated code length with no previous length")
+			}
+			value = lengths[len(lengths)-1]
+			repeat, err = br.bits(2)
+			repeat += 3
+		case 17:
+			repeat, err = br.bits(3)
+			repeat += 3
+		default:
+			repeat, err = br.bits(7)
+			repeat += 11
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(lengths)+int(repeat) > nlit+ndist {
+			return nil, nil, fmt.Errorf("deflate: too many code lengths")
+		}
+		for ; repeat > 0; repeat-- {
+			lengths = append(lengths, value)
+		}
+	}
+	if lengths[256] == 0 {
+		return nil, nil, fmt.Errorf("deflate: missing end-of-block code")
+	}
+	lit, err := newHuffman(lengths[:nlit])
+	if err != nil {
+		return nil, nil, err
+	}
+	dist, err := newHuffman(lengths[nlit:])
+	if err != nil {
+		return nil, nil, err
+	}
+	return lit, dist, nil
+}
+
+// huffmanBlock はハフマン符号で圧縮されたブロックを展開し、リテラルと一致を数える。
+func (in *inflater) huffmanBlock(block *Block, lit, dist *huffman) error {
+	br := in.br
+	start := len(in.out)
+	for {
+		sym, err := br.decode(lit)
+		if err != nil {
+			return err
+		}
+		switch {
+		case sym < 256:
+			in.out = append(in.out, byte(sym))
+			block.Literals++
+			continue
+		case sym == 256:
+			block.Size = len(in.out) - start
+			return nil
+		case sym > 285:
+			return fmt.Errorf("deflate: invalid length symbol %d", sym)
+		}
+
+		sym -= 257
+		extra, err := br.bits(lengthExtra[sym])
+		if err != nil {
+			return err
+		}
+		length := lengthBase[sym] + int(extra)
+		dsym, err := br.decode(dist)
+		if err != nil {
+			return err
+		}
+		if dsym >= len(distBase) {
+			return fmt.Errorf("deflate: invalid distance symbol %d", dsym)
+		}
+		if extra, err = br.bits(distExtra[dsym]); err != nil {
+			return err
+		}
+		distance := distBase[dsym] + int(extra)
+		if distance > len(in.out) {
+			return fmt.Errorf("deflate: distance %d is before the start of the stream", distance)
+		}
+		// 距離が長さより短い場合は自分自身の出力を繰り返すので、1バイトずつ写す
+		for i := 0; i < length; i++ {
+			in.out = append(in.out, in.out[len(in.out)-distance])
+		}
+		block.Matches++
+		block.MatchBytes += length
+	}
+}
@@ -0,0 +1,127 @@
+package deflate
+
+import (
+	"bytes"
+	"compress/zlib"
+	"math/rand"
+	"testing"
+)
+
+func compress(t *testing.T, data []byte, level int) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	w, err := zlib.NewWriterLevel(&b, level)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// TestAnalyze はcompress/zlibの各レベルで圧縮したデータを展開でき、
+// 集計が展開したデータとストリームの長さに合うことを確認する。
+func TestAnalyze(t *testing.T) {
+	noise := make([]byte, 200000)
+	rand.New(rand.NewSource(1)).Read(noise)
+	inputs := [][]byte{{}, []byte("hello hello hello hello"), bytes.Repeat([]byte("abcabcabd"), 10000), noise}
+	levels := []int{zlib.NoCompression, zlib.BestSpeed, zlib.DefaultCompression, zlib.BestCompression, zlib.HuffmanOnly}
+	for _, in := range inputs {
+		for _, level := range levels {
+			data := compress(t, in, level)
+			var out bytes.Buffer
+			s, err := Analyze(bytes.NewReader(data), &out)
+			if err != nil {
+				t.Fatalf("level %d, %d bytes: %v", level, len(in), err)
+			}
+			if !bytes.Equal(out.Bytes(), in) || s.Size != len(in) || s.CompressedSize != len(data) {
+				t.Fatalf("level %d, %d bytes: decompressed %d bytes, stats %+v", level, len(in), out.Len(), s)
+			}
+			if s.Literals+s.MatchBytes != len(in) || s.WindowSize != 1<<15 {
+				t.Errorf("level %d, %d bytes: stats %+v", level, len(in), s)
+			}
+			// ブロックのビット数の合計は、ヘッダとチェックサムを除いた長さから最後の端数を引いた範囲に入る
+			var bits int64
+			for _, b := range s.Blocks {
+				bits += b.Bits
+				// compress/flateは空の固定ブロックで終えることがあるので、データを持つブロックだけを見る
+				if level == zlib.NoCompression && b.Size > 0 && b.Type != Stored {
+					t.Errorf("level 0: %v block", b.Type)
+				}
+			}
+			if body := int64(len(data)-6) * 8; bits > body || bits <= body-8 {
+				t.Errorf("level %d, %d bytes: blocks have %d bits, stream has %d", level, len(in), bits, body)
+			}
+			if level == zlib.HuffmanOnly && s.Matches != 0 {
+				t.Errorf("Huffman only: %d matches", s.Matches)
+			}
+		}
+	}
+}
+
+func TestAnalyzeLevel(t *testing.T) {
+	for level, want := range map[int]int{zlib.BestSpeed: 0, zlib.DefaultCompression: 2, zlib.BestCompression: 3} {
+		s, err := Analyze(bytes.NewReader(compress(t, []byte("level"), level)), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Level != want {
+			t.Errorf("level %d: FLEVEL %d, want %d", level, s.Level, want)
+		}
+	}
+}
+
+func TestEntropy(t *testing.T) {
+	all := make([]byte, 512)
+	for i := range all {
+		all[i] = byte(i)
+	}
+	tests := []struct {
+		data []byte
+		want float64
+	}{
+		{nil, 0},
+		{bytes.Repeat([]byte{7}, 100), 0},
+		{[]byte("abab"), 1},
+		{all, 8},
+	}
+	for _, tt := range tests {
+		if got := entropy(tt.data); got != tt.want {
+			t.Errorf("entropy(%d bytes) = %v, want %v", len(tt.data), got, tt.want)
+		}
+	}
+}
+
+func TestAnalyzeErrors(t *testing.T) {
+	data := compress(t, []byte("checksum test data"), zlib.DefaultCompression)
+	corrupt := append([]byte(nil), data...)
+	corrupt[len(corrupt)-1] ^= 1
+	if _, err := Analyze(bytes.NewReader(corrupt), nil); err != ErrChecksum {
+		t.Errorf("got %v, want ErrChecksum", err)
+	}
+
+	var dict bytes.Buffer
+	w, err := zlib.NewWriterLevelDict(&dict, zlib.DefaultCompression, []byte("dictionary"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("dictionary"))
+	w.Close()
+
+	tests := map[string][]byte{
+		"header":     append([]byte{0x78, 0x9d}, data[2:]...),
+		"truncated":  data[:len(data)-5],
+		"dictionary": dict.Bytes(),
+		// BFINAL=1、BTYPE=3
+		"block type": {0x78, 0x9c, 0x07, 0, 0, 0, 0},
+	}
+	for name, input := range tests {
+		if _, err := Analyze(bytes.NewReader(input), nil); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package pngreader
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"io"
+
+	"github.com/kouheiszk/png-reader/internal/imageutil"
+)
+
+// ColorType はIHDRのカラータイプ
+type ColorType uint8
+
+const (
+	Grayscale      ColorType = 0
+	Truecolor      ColorType = 2
+	Indexed        ColorType = 3
+	GrayscaleAlpha ColorType = 4
+	TruecolorAlpha ColorType = 6
+)
+
+// CompressionLevel はzlibの圧縮レベル。image/pngと同じ値を使う。
+type CompressionLevel int
+
+const (
+	DefaultCompression CompressionLevel = 0
+	NoCompression      CompressionLevel = -1
+	BestSpeed          CompressionLevel = -2
+	BestCompression    CompressionLevel = -3
+)
+
+func (l CompressionLevel) zlibLevel() int {
+	switch l {
+	case NoCompression:
+		return zlib.NoCompression
+	case BestSpeed:
+		return zlib.BestSpeed
+	case BestCompression:
+		return zlib.BestCompression
+	default:
+		return zlib.DefaultCompression
+	}
+}
+
+// FilterStrategy は各行に適用するフィルタの選び方
+type FilterStrategy int
+
+const (
+	// FilterAdaptive は行ごとに差分の絶対値の和が最小になるフィルタを選ぶ。
+	FilterAdaptive FilterStrategy = iota
+	FilterNone
+	FilterSub
+	FilterUp
+	FilterAverage
+	FilterPaeth
+)
+
+// Encoder はPNGのエンコード方法を設定する。
+// BitDepthが0の場合、ビット深度は画像から選ぶ(16ビットの画像は16、それ以外は8。Indexedは8)。
+// ColorTypeもゼロ値のGrayscaleの場合は、image.Grayと*image.Gray16をGrayscale、
+// *image.PalettedをIndexed、それ以外をTruecolorAlphaにする。
+// 自動選択ではなくGrayscaleで書く場合はBitDepthも設定する。
+type Encoder struct {
+	ColorType ColorType
+	BitDepth  int
+	Interlace bool
+
+	// Palette はIndexedで使うパレット。nilで画像が*image.Palettedの場合はそのパレットを使う。
+	Palette color.Palette
+
+	CompressionLevel CompressionLevel
+	Filter           FilterStrategy
+
+	// ICCProfile が設定されている場合、iCCPチャンクとして埋め込む。画素は変換しない。
+	// ICCProfileName はiCCPのキーワードで、空の場合は"ICC profile"にする。
+	ICCProfile     []byte
+	ICCProfileName string
+
+	// EXIF が設定されている場合、eXIfチャンクとして埋め込む。
+	EXIF []byte
+}
+
+// Encode はmをPNGとしてwに書き込む。
+func Encode(w io.Writer, m image.Image) error {
+	return new(Encoder).Encode(w, m)
+}
+
+// Encode はmをPNGとしてwに書き込む。
+func (e *Encoder) Encode(w io.Writer, m image.Image) error {
+	colorType, depth := e.format(m)
+	bitsPerPixel, err := bitsPerPixel(int(colorType), depth)
+	if err != nil {
+		return err
+	}
+
+	b := m.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if width <= 0 || height <= 0 || width > maxDimension || height > maxDimension {
+		return fmt.Errorf("invalid image dimensions %dx%d", width, height)
+	}
+
+	enc := &encoder{
+		Encoder:      e,
+		m:            m,
+		colorType:    colorType,
+		depth:        depth,
+		bitsPerPixel: bitsPerPixel,
+	}
+	if colorType == Indexed {
+		if err := enc.preparePalette(); err != nil {
+			return err
+		}
+	}
+
+	enc.writeChunk("IHDR", enc.ihdr(width, height))
+	if len(e.ICCProfile) > 0 {
+		iccp, err := enc.iccp()
+		if err != nil {
+			return err
+		}
+		enc.writeChunk("iCCP", iccp)
+	}
+	if colorType == Indexed {
+		enc.writeChunk("PLTE", enc.plte())
+		if trns := enc.trns(); trns != nil {
+			enc.writeChunk("tRNS", trns)
+		}
+	}
+	if len(e.EXIF) > 0 {
+		if _, _, err := orientationOffset(e.EXIF); err != nil {
+			return err
+		}
+		enc.writeChunk("eXIf", e.EXIF)
+	}
+	data, err := enc.imageData(width, height)
+	if err != nil {
+		return err
+	}
+	enc.writeChunk("IDAT", data)
+	enc.writeChunk("IEND", nil)
+
+	if _, err := io.WriteString(w, pngSignature); err != nil {
+		return err
+	}
+	_, err = enc.out.WriteTo(w)
+	return err
+}
+
+// encoder は1回のエンコードの状態を保持する。
+type encoder struct {
+	*Encoder
+
+	m            image.Image
+	colorType    ColorType
+	depth        int
+	bitsPerPixel int
+	palette      color.Palette
+
+	out bytes.Buffer
+}
+
+func (e *encoder) writeChunk(chunkType string, data []byte) {
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[0:4], uint32(len(data)))
+	copy(header[4:8], chunkType)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:8])
+	crc.Write(data)
+
+	e.out.Write(header[:])
+	e.out.Write(data)
+	binary.Write(&e.out, binary.BigEndian, crc.Sum32())
+}
+
+func (e *encoder) ihdr(width, height int) []byte {
+	data := make([]byte, 13)
+	binary.BigEndian.PutUint32(data[0:4], uint32(width))
+	binary.BigEndian.PutUint32(data[4:8], uint32(height))
+	data[8] = uint8(e.depth)
+	data[9] = uint8(e.colorType)
+	if e.Interlace {
+		data[12] = 1
+	}
+	return data
+}
+
+// preparePalette はIndexedで使うパレットを決める。
+// format はmを書くカラータイプとビット深度を返す。
+func (e *Encoder) format(m image.Image) (ColorType, int) {
+	if e.BitDepth != 0 {
+		return e.ColorType, e.BitDepth
+	}
+	depth := 8
+	switch m.ColorModel() {
+	case color.NRGBA64Model, color.RGBA64Model, color.Gray16Model:
+		depth = 16
+	}
+	switch {
+	case e.ColorType == Indexed:
+		return Indexed, 8
+	case e.ColorType != Grayscale:
+		return e.ColorType, depth
+	}
+	switch m.ColorModel() {
+	case color.GrayModel, color.Gray16Model:
+		return Grayscale, depth
+	}
+	if _, ok := m.(*image.Paletted); ok {
+		return Indexed, 8
+	}
+	return TruecolorAlpha, depth
+}
+
+func (e *encoder) preparePalette() error {
+	e.palette = e.Palette
+	if e.palette == nil {
+		if p, ok := e.m.(*image.Paletted); ok {
+			e.palette = p.Palette
+		}
+	}
+	if len(e.palette) == 0 {
+		return fmt.Errorf("indexed encoding requires a palette")
+	}
+	if len(e.palette) > 1<<uint(e.depth) {
+		return fmt.Errorf("palette has %d entries, more than bit depth %d allows", len(e.palette), e.depth)
+	}
+	return nil
+}
+
+func (e *encoder) plte() []byte {
+	data := make([]byte, 0, 3*len(e.palette))
+	for _, c := range e.palette {
+		n := imageutil.NRGBA64(c)
+		data = append(data, uint8(n.R>>8), uint8(n.G>>8), uint8(n.B>>8))
+	}
+	return data
+}
+
+// trns はパレットの透明度を返す。すべて不透明の場合はnilを返す。
+func (e *encoder) trns() []byte {
+	data := make([]byte, len(e.palette))
+	last := -1
+	for i, c := range e.palette {
+		data[i] = uint8(imageutil.NRGBA64(c).A >> 8)
+		if data[i] != 0xff {
+			last = i
+		}
+	}
+	if last < 0 {
+		return nil
+	}
+	return data[:last+1]
+}
+
+// samples は(x, y)のピクセルをビット深度のサンプル値に変換してdstに追加する。
+func (e *encoder) samples(dst []uint16, x, y int) []uint16 {
+	if e.colorType == Indexed {
+		if p, ok := e.m.(*image.Paletted); ok && e.Palette == nil {
+			return append(dst, uint16(p.ColorIndexAt(x, y)))
+		}
+		return append(dst, uint16(e.palette.Index(e.m.At(x, y))))
+	}
+
+	c := imageutil.NRGBA64(e.m.At(x, y))
+	max := uint32(1<<uint(e.depth) - 1)
+	scale := func(v uint16) uint16 {
+		return uint16((uint32(v)*max + 0x7fff) / 0xffff)
+	}
+	gray := uint16((19595*uint32(c.R) + 38470*uint32(c.G) + 7471*uint32(c.B) + 1<<15) >> 16)
+
+	switch e.colorType {
+	case Grayscale:
+		return append(dst, scale(gray))
+	case Truecolor:
+		return append(dst, scale(c.R), scale(c.G), scale(c.B))
+	case GrayscaleAlpha:
+		return append(dst, scale(gray), scale(c.A))
+	default:
+		return append(dst, scale(c.R), scale(c.G), scale(c.B), scale(c.A))
+	}
+}
+
+// packRow はサンプル値をビット深度に合わせてrowに詰める。
+func (e *encoder) packRow(row []byte, samples []uint16) {
+	for i := range row {
+		row[i] = 0
+	}
+	for i, s := range samples {
+		switch e.depth {
+		case 16:
+			binary.BigEndian.PutUint16(row[2*i:], s)
+		case 8:
+			row[i] = uint8(s)
+		default:
+			bit := i * e.depth
+			row[bit/8] |= uint8(s) << uint(8-e.depth-bit%8)
+		}
+	}
+}
+
+// imageData はフィルタを適用して圧縮した画像データを返す。
+func (e *encoder) imageData(width, height int) ([]byte, error) {
+	var compressed bytes.Buffer
+	zw, err := zlib.NewWriterLevel(&compressed, e.CompressionLevel.zlibLevel())
+	if err != nil {
+		return nil, err
+	}
+
+	passes := noInterlacing
+	if e.Interlace {
+		passes = interlacing
+	}
+	bytesPerPixel := (e.bitsPerPixel + 7) / 8
+	min := e.m.Bounds().Min
+	var samples []uint16
+	for _, p := range passes {
+		passWidth, passHeight := p.size(width, height)
+		if passWidth <= 0 || passHeight <= 0 {
+			continue
+		}
+		stride, err := rowBytes(passWidth, e.bitsPerPixel)
+		if err != nil {
+			return nil, err
+		}
+		current := make([]byte, stride)
+		prev := make([]byte, stride)
+		filtered := make([]byte, 1+stride)
+		for y := 0; y < passHeight; y++ {
+			samples = samples[:0]
+			for x := 0; x < passWidth; x++ {
+				samples = e.samples(samples, min.X+p.xOffset+x*p.xFactor, min.Y+p.yOffset+y*p.yFactor)
+			}
+			e.packRow(current, samples)
+			chooseFilter(filtered, current, prev, bytesPerPixel, e.Filter)
+			if _, err := zw.Write(filtered); err != nil {
+				return nil, err
+			}
+			prev, current = current, prev
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return compressed.Bytes(), nil
+}
@@ -0,0 +1,165 @@
+package pngreader
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"github.com/kouheiszk/png-reader/internal/imageutil"
+)
+
+// randomNRGBA は半透明を含む乱数の画素を持つ画像を返す。
+func randomNRGBA(r *rand.Rand, rect image.Rectangle) *image.NRGBA {
+	m := image.NewNRGBA(rect)
+	r.Read(m.Pix)
+	return m
+}
+
+// assertSamePixels はgotとwantの画素を非乗算済みの16ビットで比べる。原点の位置は問わない。
+func assertSamePixels(t *testing.T, got, want image.Image) {
+	t.Helper()
+	gb, wb := got.Bounds(), want.Bounds()
+	if gb.Dx() != wb.Dx() || gb.Dy() != wb.Dy() {
+		t.Fatalf("size %v, want %v", gb.Size(), wb.Size())
+	}
+	for y := 0; y < wb.Dy(); y++ {
+		for x := 0; x < wb.Dx(); x++ {
+			g := imageutil.NRGBA64(got.At(gb.Min.X+x, gb.Min.Y+y))
+			w := imageutil.NRGBA64(want.At(wb.Min.X+x, wb.Min.Y+y))
+			if g != w {
+				t.Fatalf("pixel (%d, %d) is %v, want %v", x, y, g, w)
+			}
+		}
+	}
+}
+
+func encodeDecode(t *testing.T, e *Encoder, m image.Image) image.Image {
+	t.Helper()
+	var b bytes.Buffer
+	if err := e.Encode(&b, m); err != nil {
+		t.Fatal(err)
+	}
+	got, err := (&Decoder{Strict: true}).Decode(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+// TestEncodeRoundTrip はフィルタの選び方、インターレース、圧縮レベルのすべての組み合わせで、
+// エンコードした画像をデコードすると元の画素に戻ることを確認する。
+func TestEncodeRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	m := randomNRGBA(r, image.Rect(0, 0, 19, 11))
+	filters := []FilterStrategy{FilterAdaptive, FilterNone, FilterSub, FilterUp, FilterAverage, FilterPaeth}
+	levels := []CompressionLevel{DefaultCompression, NoCompression, BestSpeed, BestCompression}
+	for _, filter := range filters {
+		for _, level := range levels {
+			for _, interlace := range []bool{false, true} {
+				e := &Encoder{Filter: filter, CompressionLevel: level, Interlace: interlace}
+				assertSamePixels(t, encodeDecode(t, e, m), m)
+			}
+		}
+	}
+}
+
+// TestEncodeColorTypes は画像の種類ごとに、自動で選ばれる形式と明示した形式で画素が保たれることを確認する。
+func TestEncodeColorTypes(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	rect := image.Rect(0, 0, 7, 5)
+
+	deep := image.NewNRGBA64(rect)
+	r.Read(deep.Pix)
+	gray := image.NewGray(rect)
+	r.Read(gray.Pix)
+	palette := color.Palette{color.NRGBA{0, 0, 0, 0}, color.NRGBA{255, 0, 0, 128}, color.NRGBA{0, 255, 0, 255}}
+	paletted := image.NewPaletted(rect, palette)
+	for i := range paletted.Pix {
+		paletted.Pix[i] = uint8(r.Intn(len(palette)))
+	}
+
+	tests := []struct {
+		name    string
+		encoder *Encoder
+		m       image.Image
+	}{
+		{"auto 16-bit", &Encoder{}, deep},
+		{"auto gray", &Encoder{}, gray},
+		{"auto paletted", &Encoder{}, paletted},
+		{"truecolor alpha 16", &Encoder{ColorType: TruecolorAlpha, BitDepth: 16}, deep},
+		{"grayscale 8", &Encoder{ColorType: Grayscale, BitDepth: 8}, gray},
+		{"grayscale alpha 8", &Encoder{ColorType: GrayscaleAlpha, BitDepth: 8}, gray},
+		{"truecolor 8", &Encoder{ColorType: Truecolor, BitDepth: 8}, gray},
+		{"indexed 2", &Encoder{ColorType: Indexed, BitDepth: 2}, paletted},
+		{"indexed 8 interlaced", &Encoder{ColorType: Indexed, BitDepth: 8, Interlace: true}, paletted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertSamePixels(t, encodeDecode(t, tt.encoder, tt.m), tt.m)
+		})
+	}
+}
+
+// TestEncodeFormat はBitDepthが0の場合に選ばれるカラータイプとビット深度を確認する。
+func TestEncodeFormat(t *testing.T) {
+	rect := image.Rect(0, 0, 3, 2)
+	paletted := image.NewPaletted(rect, color.Palette{color.Black, color.White})
+	tests := []struct {
+		name      string
+		encoder   *Encoder
+		m         image.Image
+		colorType ColorType
+		depth     int
+	}{
+		{"gray", &Encoder{}, image.NewGray(rect), Grayscale, 8},
+		{"gray 16", &Encoder{}, image.NewGray16(rect), Grayscale, 16},
+		{"paletted", &Encoder{}, paletted, Indexed, 8},
+		{"nrgba", &Encoder{}, image.NewNRGBA(rect), TruecolorAlpha, 8},
+		{"rgba 64", &Encoder{}, image.NewRGBA64(rect), TruecolorAlpha, 16},
+		{"truecolor", &Encoder{ColorType: Truecolor}, image.NewNRGBA64(rect), Truecolor, 16},
+		{"gray alpha", &Encoder{ColorType: GrayscaleAlpha}, image.NewGray(rect), GrayscaleAlpha, 8},
+		{"indexed 16-bit image", &Encoder{ColorType: Indexed, Palette: paletted.Palette}, image.NewNRGBA64(rect), Indexed, 8},
+		{"explicit depth", &Encoder{ColorType: Grayscale, BitDepth: 4}, image.NewNRGBA(rect), Grayscale, 4},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		if err := tt.encoder.Encode(&b, tt.m); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		// IHDRのビット深度とカラータイプはファイルの先頭から24バイト目と25バイト目
+		depth, colorType := int(b.Bytes()[24]), ColorType(b.Bytes()[25])
+		if colorType != tt.colorType || depth != tt.depth {
+			t.Errorf("%s: color type %d, depth %d, want %d, %d", tt.name, colorType, depth, tt.colorType, tt.depth)
+		}
+	}
+}
+
+// TestEncodeSubImage は原点が(0, 0)でない画像も正しくエンコードすることを確認する。
+func TestEncodeSubImage(t *testing.T) {
+	m := randomNRGBA(rand.New(rand.NewSource(3)), image.Rect(0, 0, 16, 16))
+	sub := m.SubImage(image.Rect(3, 5, 14, 9))
+	assertSamePixels(t, encodeDecode(t, &Encoder{Interlace: true}, sub), sub)
+}
+
+// TestEncodeErrors は表せない設定をエラーにすることを確認する。
+func TestEncodeErrors(t *testing.T) {
+	m := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	tests := []struct {
+		name    string
+		encoder *Encoder
+		m       image.Image
+	}{
+		{"invalid depth", &Encoder{ColorType: Truecolor, BitDepth: 4}, m},
+		{"indexed without palette", &Encoder{ColorType: Indexed, BitDepth: 8}, m},
+		{"indexed without palette, auto depth", &Encoder{ColorType: Indexed}, m},
+		{"palette too large", &Encoder{ColorType: Indexed, BitDepth: 1, Palette: color.Palette{color.Black, color.White, color.Transparent}}, m},
+		{"empty image", &Encoder{}, image.NewNRGBA(image.Rectangle{})},
+	}
+	for _, tt := range tests {
+		if err := tt.encoder.Encode(new(bytes.Buffer), tt.m); err == nil {
+			t.Errorf("%s: no error", tt.name)
+		}
+	}
+}
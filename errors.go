@@ -0,0 +1,135 @@
+package pngreader
+
+import (
+	"compress/zlib"
+	"errors"
+	"fmt"
+)
+
+// エラーの分類。errors.Isで比較でき、対応する型はerrors.Asで取り出せる。
+var (
+	// ErrFormat はPNGの形式に違反した入力
+	ErrFormat = errors.New("invalid PNG format")
+	// ErrUnsupported は形式としては正しいが、このパッケージが扱えない入力
+	ErrUnsupported = errors.New("unsupported PNG feature")
+	// ErrIntegrity はCRCやzlibのチェックサムが一致しない、破損した入力
+	ErrIntegrity = errors.New("PNG integrity check failed")
+	// ErrLimit は大きさなどの制限を超えた入力
+	ErrLimit = errors.New("PNG exceeds limit")
+)
+
+// FormatError はPNGの形式に違反した入力を表す。
+type FormatError struct {
+	Err error
+}
+
+func (e *FormatError) Error() string        { return e.Err.Error() }
+func (e *FormatError) Unwrap() error        { return e.Err }
+func (e *FormatError) Is(target error) bool { return target == ErrFormat }
+
+// UnsupportedError は未知の圧縮方式やクリティカルチャンクなど、扱えない入力を表す。
+type UnsupportedError struct {
+	Err error
+}
+
+func (e *UnsupportedError) Error() string        { return e.Err.Error() }
+func (e *UnsupportedError) Unwrap() error        { return e.Err }
+func (e *UnsupportedError) Is(target error) bool { return target == ErrUnsupported }
+
+// IntegrityError はCRCやzlibのチェックサムの不一致を表す。
+type IntegrityError struct {
+	Err error
+}
+
+func (e *IntegrityError) Error() string        { return e.Err.Error() }
+func (e *IntegrityError) Unwrap() error        { return e.Err }
+func (e *IntegrityError) Is(target error) bool { return target == ErrIntegrity }
+
+// LimitError は画像の大きさなどが制限を超えたことを表す。
+type LimitError struct {
+	Err error
+}
+
+func (e *LimitError) Error() string        { return e.Err.Error() }
+func (e *LimitError) Unwrap() error        { return e.Err }
+func (e *LimitError) Is(target error) bool { return target == ErrLimit }
+
+// CRCError はチャンクに格納されたCRCと、データから計算したCRCの不一致を表す。
+// デコードエラーとしてはIntegrityErrorに包まれる。
+type CRCError struct {
+	ChunkType string
+	Stored    uint32
+	Computed  uint32
+}
+
+func (e *CRCError) Error() string {
+	return fmt.Sprintf("CRC mismatch: stored 0x%08X, computed 0x%08X", e.Stored, e.Computed)
+}
+
+// unsupportedf はUnsupportedErrorを作成する。
+func unsupportedf(format string, args ...interface{}) error {
+	return &UnsupportedError{fmt.Errorf(format, args...)}
+}
+
+// limitf はLimitErrorを作成する。
+func limitf(format string, args ...interface{}) error {
+	return &LimitError{fmt.Errorf(format, args...)}
+}
+
+// classify はまだ分類されていないerrを検証項目checkに応じて分類する。
+// CRCとzlibのチェックサムの不一致はIntegrityError、それ以外はFormatErrorになる。
+func classify(check string, err error) error {
+	var (
+		formatErr      *FormatError
+		unsupportedErr *UnsupportedError
+		integrityErr   *IntegrityError
+		limitErr       *LimitError
+	)
+	switch {
+	case errors.As(err, &formatErr), errors.As(err, &unsupportedErr),
+		errors.As(err, &integrityErr), errors.As(err, &limitErr):
+		return err
+	case check == checkCRC, errors.Is(err, zlib.ErrChecksum):
+		return &IntegrityError{err}
+	}
+	return &FormatError{err}
+}
+
+// DecodeError はデコード中のエラーに、発生したチャンクとファイル先頭からの位置を付加する。
+type DecodeError struct {
+	// ChunkType はエラーが発生したチャンクのタイプ。シグネチャなどチャンクの外では空になる。
+	ChunkType string
+	// ChunkIndex は同じタイプのチャンクの中で何番目か(1から数える)
+	ChunkIndex int
+	// Offset はファイル先頭からのバイト位置
+	Offset int64
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	if e.ChunkType == "" {
+		return fmt.Sprintf("at offset 0x%X: %v", e.Offset, e.Err)
+	}
+	return fmt.Sprintf("%s #%d at offset 0x%X: %v", e.ChunkType, e.ChunkIndex, e.Offset, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// location はデコード中の現在位置
+type location struct {
+	chunkType  string
+	chunkIndex int
+	offset     int64
+}
+
+// errorAt はerrにdの現在位置を付加する。
+func (d *decoder) errorAt(err error) error {
+	return &DecodeError{
+		ChunkType:  d.loc.chunkType,
+		ChunkIndex: d.loc.chunkIndex,
+		Offset:     d.loc.offset,
+		Err:        err,
+	}
+}
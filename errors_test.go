@@ -0,0 +1,44 @@
+package pngreader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"testing"
+)
+
+// TestCRCError はCRCの不一致を、格納された値と計算した値、CRCの位置とともに報告することを確認する。
+func TestCRCError(t *testing.T) {
+	text := testChunk{"tEXt", []byte("Title\x00crc")}
+	file := testPNG(testIHDR(1, 1), text, testChunk{"IDAT", zlibBytes([]byte{0, 0})}, testChunk{"IEND", nil})
+	// シグネチャ8バイトとIHDRの25バイトの後にtEXtが始まる
+	crcOffset := 8 + 25 + 8 + len(text.data)
+	binary.BigEndian.PutUint32(file[crcOffset:], 0xdeadbeef)
+
+	_, err := (&Decoder{Strict: true}).Decode(bytes.NewReader(file))
+	if !errors.Is(err, ErrIntegrity) {
+		t.Fatalf("got %v, want ErrIntegrity", err)
+	}
+	var crcErr *CRCError
+	if !errors.As(err, &crcErr) {
+		t.Fatalf("%v is not a CRCError", err)
+	}
+	computed := crc32.ChecksumIEEE(append([]byte(text.chunkType), text.data...))
+	if crcErr.ChunkType != "tEXt" || crcErr.Stored != 0xdeadbeef || crcErr.Computed != computed {
+		t.Errorf("got %+v, want stored 0xDEADBEEF and computed 0x%08X", crcErr, computed)
+	}
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) || decodeErr.Offset != int64(crcOffset) {
+		t.Errorf("got %v, want offset 0x%X", err, crcOffset)
+	}
+
+	var warnings []error
+	decoder := &Decoder{Warn: func(err error) { warnings = append(warnings, err) }}
+	if _, err := decoder.Decode(bytes.NewReader(file)); err != nil {
+		t.Fatalf("non-strict: %v", err)
+	}
+	if len(warnings) != 1 || !errors.As(warnings[0], &crcErr) {
+		t.Errorf("non-strict: got warnings %v, want one CRCError", warnings)
+	}
+}
@@ -0,0 +1,138 @@
+package pngreader
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+)
+
+// orientationTag はEXIFのOrientationタグの番号
+const orientationTag = 0x0112
+
+// orientationOffset はeXIfのデータからOrientationタグの値の位置を探し、
+// バイトオーダーとともに返す。タグがない場合は-1を返す。
+func orientationOffset(exif []byte) (int, binary.ByteOrder, error) {
+	if len(exif) < 8 {
+		return 0, nil, fmt.Errorf("truncated EXIF data")
+	}
+	var order binary.ByteOrder
+	switch string(exif[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0, nil, fmt.Errorf("invalid EXIF byte order %q", exif[:2])
+	}
+	if order.Uint16(exif[2:4]) != 42 {
+		return 0, nil, fmt.Errorf("invalid EXIF header")
+	}
+
+	// IFD0のエントリは12バイトで、タグ番号、型、個数、値(4バイト以下の場合)の順
+	ifd := int64(order.Uint32(exif[4:8]))
+	if ifd+2 > int64(len(exif)) {
+		return 0, nil, fmt.Errorf("EXIF IFD0 offset %d out of range", ifd)
+	}
+	count := int64(order.Uint16(exif[ifd:]))
+	if ifd+2+count*12 > int64(len(exif)) {
+		return 0, nil, fmt.Errorf("truncated EXIF IFD0")
+	}
+	for i := int64(0); i < count; i++ {
+		entry := exif[ifd+2+i*12:]
+		if order.Uint16(entry[0:2]) != orientationTag {
+			continue
+		}
+		// 型はSHORT(3)で個数は1
+		if order.Uint16(entry[2:4]) != 3 || order.Uint32(entry[4:8]) != 1 {
+			return 0, nil, fmt.Errorf("invalid EXIF Orientation tag")
+		}
+		return int(ifd + 2 + i*12 + 8), order, nil
+	}
+	return -1, order, nil
+}
+
+// exifOrientation はeXIfのデータからOrientationタグの値を返す。タグがない場合は1を返す。
+func exifOrientation(exif []byte) (int, error) {
+	offset, order, err := orientationOffset(exif)
+	if err != nil || offset < 0 {
+		return 1, err
+	}
+	orientation := int(order.Uint16(exif[offset:]))
+	if orientation < 1 || orientation > 8 {
+		return 1, fmt.Errorf("invalid EXIF orientation %d", orientation)
+	}
+	return orientation, nil
+}
+
+// ResetOrientation はeXIfのデータのOrientationタグを1(回転なし)にしたコピーを返す。
+// Decoder.AutoOrientで向きを直した画像にInfo.EXIFを埋め込み直すときに使う。
+// タグがない場合や、データを解釈できない場合はexifをそのまま返す。
+func ResetOrientation(exif []byte) []byte {
+	offset, order, err := orientationOffset(exif)
+	if err != nil || offset < 0 {
+		return exif
+	}
+	exif = append([]byte(nil), exif...)
+	order.PutUint16(exif[offset:], 1)
+	return exif
+}
+
+// Orient はEXIFのOrientationの値orientationに従ってimgを回転・反転し、正しい向きにした画像を返す。
+// orientationが5〜8の場合は幅と高さが入れ替わる。1や範囲外の値の場合はimgをそのまま返す。
+func Orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	size := image.Rect(0, 0, w, h)
+	if orientation >= 5 {
+		size = image.Rect(0, 0, h, w)
+	}
+
+	var dst draw.Image
+	switch img.(type) {
+	case *image.NRGBA64, *image.RGBA64:
+		dst = image.NewNRGBA64(size)
+	default:
+		dst = image.NewNRGBA(size)
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // 左右反転
+				dx, dy = w-1-x, y
+			case 3: // 180度回転
+				dx, dy = w-1-x, h-1-y
+			case 4: // 上下反転
+				dx, dy = x, h-1-y
+			case 5: // 左上から右下への対角線で反転
+				dx, dy = y, x
+			case 6: // 時計回りに90度回転
+				dx, dy = h-1-y, x
+			case 7: // 右上から左下への対角線で反転
+				dx, dy = h-1-y, w-1-x
+			case 8: // 反時計回りに90度回転
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
+
+// parseeXIf はeXIfのデータを保持し、Orientationタグを読み込む。
+// EXIFの形式の誤りは仕様違反として扱わず、警告して向きを変えない。
+func (d *decoder) parseeXIf(c *chunk) error {
+	d.exif = c.data
+	orientation, err := exifOrientation(c.data)
+	if err != nil {
+		if err := d.warnError(err); err != nil {
+			return err
+		}
+	}
+	d.orientation = orientation
+	return nil
+}
@@ -0,0 +1,91 @@
+package pngreader
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// exifWithOrientation はOrientationタグだけを持つEXIFを返す。littleEndianならII、そうでなければMMで書く。
+func exifWithOrientation(orientation byte, littleEndian bool) []byte {
+	if littleEndian {
+		return []byte{'I', 'I', 42, 0, 8, 0, 0, 0, 1, 0, 0x12, 0x01, 3, 0, 1, 0, 0, 0, orientation, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	}
+	return []byte{'M', 'M', 0, 42, 0, 0, 0, 8, 0, 1, 0x01, 0x12, 0, 3, 0, 0, 0, 1, 0, orientation, 0, 0, 0, 0, 0, 0, 0, 0}
+}
+
+// orientTestImage は各画素のRとGに自分の座標を持つ3x2の画像を返す。
+func orientTestImage() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 3; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{uint8(x), uint8(y), 0, 255})
+		}
+	}
+	return img
+}
+
+// TestAutoOrient はOrientationを読み、AutoOrientで時計回りに90度回転し、
+// ResetOrientationが元のEXIFを変えずにOrientationを1にすることを確認する。
+func TestAutoOrient(t *testing.T) {
+	img := orientTestImage()
+	for _, littleEndian := range []bool{false, true} {
+		var b bytes.Buffer
+		if err := (&Encoder{EXIF: exifWithOrientation(6, littleEndian)}).Encode(&b, img); err != nil {
+			t.Fatal(err)
+		}
+		info, err := DecodeInfo(bytes.NewReader(b.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Orientation != 6 {
+			t.Fatalf("orientation %d, want 6", info.Orientation)
+		}
+		if o, _ := exifOrientation(ResetOrientation(info.EXIF)); o != 1 {
+			t.Errorf("reset orientation is %d, want 1", o)
+		}
+		if o, _ := exifOrientation(info.EXIF); o != 6 {
+			t.Errorf("ResetOrientation modified its argument")
+		}
+
+		m, err := (&Decoder{Strict: true, AutoOrient: true}).Decode(bytes.NewReader(b.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Bounds() != image.Rect(0, 0, 2, 3) {
+			t.Fatalf("bounds %v, want 2x3", m.Bounds())
+		}
+		// 元の左上(0, 0)は右上(1, 0)に、左下(0, 1)は左上(0, 0)に、右下(2, 1)は左下(0, 2)に移る
+		for _, p := range []struct{ x, y, srcX, srcY int }{{1, 0, 0, 0}, {0, 0, 0, 1}, {0, 2, 2, 1}} {
+			if c := m.At(p.x, p.y).(color.NRGBA); int(c.R) != p.srcX || int(c.G) != p.srcY {
+				t.Errorf("(%d, %d) came from (%d, %d), want (%d, %d)", p.x, p.y, c.R, c.G, p.srcX, p.srcY)
+			}
+		}
+	}
+}
+
+// TestOrientInverse は各Orientationの変換に逆の変換を続けると元の画像に戻ることを確認する。
+func TestOrientInverse(t *testing.T) {
+	img := orientTestImage()
+	inverse := map[int]int{1: 1, 2: 2, 3: 3, 4: 4, 5: 5, 6: 8, 7: 7, 8: 6}
+	for o := 1; o <= 8; o++ {
+		out := Orient(Orient(img, o), inverse[o])
+		if out.Bounds() != img.Bounds() {
+			t.Fatalf("orientation %d: bounds %v", o, out.Bounds())
+		}
+		for y := 0; y < 2; y++ {
+			for x := 0; x < 3; x++ {
+				if out.At(x, y) != img.At(x, y) {
+					t.Fatalf("orientation %d is not inverted by %d", o, inverse[o])
+				}
+			}
+		}
+	}
+}
+
+func TestEncodeInvalidEXIF(t *testing.T) {
+	if err := (&Encoder{EXIF: []byte("junkjunk")}).Encode(new(bytes.Buffer), orientTestImage()); err == nil {
+		t.Error("invalid EXIF accepted")
+	}
+}
@@ -0,0 +1,123 @@
+// Package farbfeld はfarbfeld形式の画像を読み書きする。
+// farbfeldは"farbfeld"のシグネチャ、ビッグエンディアンの幅と高さ、
+// 非乗算済みRGBA各16ビットのピクセル列だけからなる。
+//
+// パッケージを読み込むとimage.Decodeでfarbfeldを扱えるようになる。
+package farbfeld
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+
+	"github.com/kouheiszk/png-reader/internal/imageutil"
+)
+
+const magic = "farbfeld"
+
+func init() {
+	image.RegisterFormat("farbfeld", magic, Decode, DecodeConfig)
+}
+
+// DefaultMaxPixels はDecoder.MaxPixelsが0の場合の最大ピクセル数
+const DefaultMaxPixels = 1 << 24
+
+// Decoder はfarbfeldの読み込みの設定
+type Decoder struct {
+	// MaxPixels はデコードする画像の最大ピクセル数(幅×高さ)。0の場合はDefaultMaxPixels
+	MaxPixels int
+}
+
+func (d *Decoder) maxPixels() uint64 {
+	if d.MaxPixels > 0 {
+		return uint64(d.MaxPixels)
+	}
+	return DefaultMaxPixels
+}
+
+func (d *Decoder) readHeader(r io.Reader) (width, height int, err error) {
+	var header [16]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, 0, fmt.Errorf("farbfeld: reading header: %w", err)
+	}
+	if string(header[:8]) != magic {
+		return 0, 0, fmt.Errorf("farbfeld: invalid signature")
+	}
+	w := binary.BigEndian.Uint32(header[8:12])
+	h := binary.BigEndian.Uint32(header[12:16])
+	if w == 0 || h == 0 {
+		return 0, 0, fmt.Errorf("farbfeld: invalid image dimensions %dx%d", w, h)
+	}
+	if max := d.maxPixels(); uint64(w)*uint64(h) > max {
+		return 0, 0, fmt.Errorf("farbfeld: image size %dx%d exceeds limit of %d pixels", w, h, max)
+	}
+	return int(w), int(h), nil
+}
+
+// DecodeConfig はfarbfeldのヘッダから画像の大きさを返す。
+func (d *Decoder) DecodeConfig(r io.Reader) (image.Config, error) {
+	width, height, err := d.readHeader(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{ColorModel: color.NRGBA64Model, Width: width, Height: height}, nil
+}
+
+// Decode はfarbfeldの画像を*image.NRGBA64として読み込む。
+func (d *Decoder) Decode(r io.Reader) (image.Image, error) {
+	width, height, err := d.readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	// farbfeldのピクセル列はNRGBA64のPixと同じ並び。ヘッダの大きさで先に確保せず、
+	// 届いたデータの分だけ広げる
+	var pix bytes.Buffer
+	if _, err := io.CopyN(&pix, r, int64(width)*int64(height)*8); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("farbfeld: reading pixels: %w", err)
+	}
+	return &image.NRGBA64{Pix: pix.Bytes(), Stride: width * 8, Rect: image.Rect(0, 0, width, height)}, nil
+}
+
+// DecodeConfig はDefaultMaxPixelsまでのfarbfeldのヘッダから画像の大きさを返す。
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	return new(Decoder).DecodeConfig(r)
+}
+
+// Decode はDefaultMaxPixelsまでのfarbfeldの画像を*image.NRGBA64として読み込む。
+func Decode(r io.Reader) (image.Image, error) {
+	return new(Decoder).Decode(r)
+}
+
+// Encode はimgをfarbfeldとしてwに書き込む。
+func Encode(w io.Writer, img image.Image) error {
+	b := img.Bounds()
+	if b.Dx() <= 0 || b.Dy() <= 0 {
+		return fmt.Errorf("farbfeld: invalid image dimensions %dx%d", b.Dx(), b.Dy())
+	}
+	bw := bufio.NewWriter(w)
+	var header [16]byte
+	copy(header[:8], magic)
+	binary.BigEndian.PutUint32(header[8:12], uint32(b.Dx()))
+	binary.BigEndian.PutUint32(header[12:16], uint32(b.Dy()))
+	bw.Write(header[:])
+
+	var pixel [8]byte
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := imageutil.NRGBA64(img.At(x, y))
+			binary.BigEndian.PutUint16(pixel[0:], c.R)
+			binary.BigEndian.PutUint16(pixel[2:], c.G)
+			binary.BigEndian.PutUint16(pixel[4:], c.B)
+			binary.BigEndian.PutUint16(pixel[6:], c.A)
+			bw.Write(pixel[:])
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,98 @@
+package farbfeld
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"math/rand"
+	"runtime"
+	"testing"
+
+	"github.com/kouheiszk/png-reader/internal/imageutil"
+)
+
+// TestRoundTrip は16ビットと8ビットの画像が、書き出して読み込むと同じ非乗算済みの色に戻ることを確認する。
+func TestRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	deep := image.NewNRGBA64(image.Rect(2, 1, 9, 6))
+	r.Read(deep.Pix)
+	shallow := image.NewNRGBA(image.Rect(0, 0, 4, 3))
+	r.Read(shallow.Pix)
+
+	for _, img := range []image.Image{deep, shallow} {
+		var b bytes.Buffer
+		if err := Encode(&b, img); err != nil {
+			t.Fatal(err)
+		}
+		config, err := DecodeConfig(bytes.NewReader(b.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		size := img.Bounds().Size()
+		if config.Width != size.X || config.Height != size.Y || config.ColorModel != color.NRGBA64Model {
+			t.Errorf("config %+v, want %v", config, size)
+		}
+		m, err := Decode(&b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bounds := img.Bounds()
+		for y := 0; y < size.Y; y++ {
+			for x := 0; x < size.X; x++ {
+				want := imageutil.NRGBA64(img.At(bounds.Min.X+x, bounds.Min.Y+y))
+				if got := m.At(x, y); got != want {
+					t.Fatalf("(%d, %d): got %v, want %v", x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+// TestDecodeErrors は壊れたヘッダと足りないピクセルをエラーにすることを確認する。
+func TestDecodeErrors(t *testing.T) {
+	var b bytes.Buffer
+	if err := Encode(&b, image.NewNRGBA64(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+	data := b.Bytes()
+	tests := map[string][]byte{
+		"bad magic": append([]byte("farbfelx"), data[8:]...),
+		"short":     data[:len(data)-1],
+		"header":    data[:10],
+	}
+	for name, input := range tests {
+		if _, err := Decode(bytes.NewReader(input)); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+// TestDecodeLimit はMaxPixelsを超える画像を拒否し、ヘッダが宣言しただけの大きさの
+// メモリを確保しないことを確認する。
+func TestDecodeLimit(t *testing.T) {
+	var b bytes.Buffer
+	if err := Encode(&b, image.NewNRGBA64(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&Decoder{MaxPixels: 15}).Decode(bytes.NewReader(b.Bytes())); err == nil {
+		t.Error("16 pixels accepted with a limit of 15")
+	}
+	if _, err := (&Decoder{MaxPixels: 16}).Decode(bytes.NewReader(b.Bytes())); err != nil {
+		t.Error(err)
+	}
+
+	// 4096x4096の画像を宣言する16バイトだけの入力
+	header := []byte("farbfeld\x00\x00\x10\x00\x00\x00\x10\x00")
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := Decode(bytes.NewReader(header))
+	runtime.ReadMemStats(&after)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("got %v, want io.ErrUnexpectedEOF", err)
+	}
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Errorf("allocated %d bytes for a 16-byte input", n)
+	}
+}
@@ -0,0 +1,175 @@
+package pngreader
+
+import (
+	"fmt"
+	"math"
+)
+
+// Unfilter はフィルタ適用済みのスキャンラインsrcを復元し、dstに書き込む。
+// srcの各行は先頭1バイトにフィルタタイプを持つ。srcは変更されないため、
+// 呼び出し元が所有するバッファをそのまま渡せる。
+// dstにはフィルタタイプを除いた行データが、行ごとに詰めて書き込まれる。
+func Unfilter(dst, src []byte, width, height, bitsPerPixel int) error {
+	rowSize, err := rowBytes(width, bitsPerPixel)
+	if err != nil {
+		return err
+	}
+	srcSize, err := filteredBytes(width, height, bitsPerPixel)
+	if err != nil {
+		return err
+	}
+	bytesPerPixel := (bitsPerPixel + 7) / 8
+	if len(src) < srcSize {
+		return fmt.Errorf("filtered data too short")
+	}
+	if len(dst) < height*rowSize {
+		return fmt.Errorf("destination buffer too short")
+	}
+
+	prevScanData := make([]byte, rowSize)
+	for y := 0; y < height; y++ {
+		offset := y * (1 + rowSize)
+		rowData := src[offset : offset+1+rowSize]
+		filterType := int(rowData[0])
+
+		currentScanData := dst[y*rowSize : (y+1)*rowSize]
+		copy(currentScanData, rowData[1:])
+
+		if err := unfilterRow(filterType, currentScanData, prevScanData, bytesPerPixel); err != nil {
+			return err
+		}
+
+		prevScanData = currentScanData
+	}
+
+	return nil
+}
+
+// unfilterRow は1行分のフィルタをcurrentScanData上で復元する。
+// prevScanDataは復元済みの前の行で、読み取りのみ行う。
+func unfilterRow(filterType int, currentScanData, prevScanData []byte, bytesPerPixel int) error {
+	switch filterType {
+	case 0:
+		// No-op.
+	case 1:
+		for i := bytesPerPixel; i < len(currentScanData); i++ {
+			currentScanData[i] += currentScanData[i-bytesPerPixel]
+		}
+	case 2:
+		for i, p := range prevScanData {
+			currentScanData[i] += p
+		}
+	case 3:
+		for i := 0; i < bytesPerPixel && i < len(currentScanData); i++ {
+			currentScanData[i] += prevScanData[i] / 2
+		}
+		for i := bytesPerPixel; i < len(currentScanData); i++ {
+			currentScanData[i] += uint8((int(currentScanData[i-bytesPerPixel]) + int(prevScanData[i])) / 2)
+		}
+	case 4:
+		var a, b, c, pa, pb, pc int
+		for i := 0; i < bytesPerPixel; i++ {
+			a, c = 0, 0
+			for j := i; j < len(currentScanData); j += bytesPerPixel {
+				b = int(prevScanData[j])
+				pa = b - c
+				pb = a - c
+				pc = int(math.Abs(float64(pa + pb)))
+				pa = int(math.Abs(float64(pa)))
+				pb = int(math.Abs(float64(pb)))
+				if pa <= pb && pa <= pc {
+					// No-op.
+				} else if pb <= pc {
+					a = b
+				} else {
+					a = c
+				}
+				a += int(currentScanData[j])
+				a &= 0xff
+				currentScanData[j] = uint8(a)
+				c = b
+			}
+		}
+	default:
+		return fmt.Errorf("bad filter type")
+	}
+
+	return nil
+}
+
+// paeth はPaethフィルタの予測値を返す。
+func paeth(a, b, c uint8) uint8 {
+	pa := int(b) - int(c)
+	pb := int(a) - int(c)
+	pc := pa + pb
+	if pa < 0 {
+		pa = -pa
+	}
+	if pb < 0 {
+		pb = -pb
+	}
+	if pc < 0 {
+		pc = -pc
+	}
+	if pa <= pb && pa <= pc {
+		return a
+	} else if pb <= pc {
+		return b
+	}
+	return c
+}
+
+// filterRow はcurrentScanDataにフィルタタイプfilterTypeを適用し、結果をdstに書き込む。
+func filterRow(filterType int, dst, currentScanData, prevScanData []byte, bytesPerPixel int) {
+	for i, x := range currentScanData {
+		var a, c uint8
+		if i >= bytesPerPixel {
+			a = currentScanData[i-bytesPerPixel]
+			c = prevScanData[i-bytesPerPixel]
+		}
+		b := prevScanData[i]
+		switch filterType {
+		case 0:
+			dst[i] = x
+		case 1:
+			dst[i] = x - a
+		case 2:
+			dst[i] = x - b
+		case 3:
+			dst[i] = x - uint8((int(a)+int(b))/2)
+		case 4:
+			dst[i] = x - paeth(a, b, c)
+		}
+	}
+}
+
+// chooseFilter はstrategyに従ってフィルタを選んで適用し、
+// 先頭にフィルタタイプを付けた行をdstに書き込む。
+func chooseFilter(dst, currentScanData, prevScanData []byte, bytesPerPixel int, strategy FilterStrategy) {
+	if strategy != FilterAdaptive {
+		filterType := int(strategy - FilterNone)
+		dst[0] = uint8(filterType)
+		filterRow(filterType, dst[1:], currentScanData, prevScanData, bytesPerPixel)
+		return
+	}
+
+	// 差分を符号付きとみなした絶対値の和が最小になるフィルタを選ぶ
+	best, bestSum := 0, -1
+	candidate := make([]byte, len(currentScanData))
+	for filterType := 0; filterType <= 4; filterType++ {
+		filterRow(filterType, candidate, currentScanData, prevScanData, bytesPerPixel)
+		sum := 0
+		for _, v := range candidate {
+			if v < 0x80 {
+				sum += int(v)
+			} else {
+				sum += 0x100 - int(v)
+			}
+		}
+		if bestSum < 0 || sum < bestSum {
+			best, bestSum = filterType, sum
+			copy(dst[1:], candidate)
+		}
+	}
+	dst[0] = uint8(best)
+}
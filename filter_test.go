@@ -0,0 +1,144 @@
+package pngreader
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+// referenceUnfilter はPNG仕様 9.2 の定義をそのまま書いた、遅いが明らかに正しい復元処理。
+// filteredは変更せず、復元した行を新しいスライスで返す。
+func referenceUnfilter(filterType int, filtered, prev []byte, bytesPerPixel int) []byte {
+	recon := make([]byte, len(filtered))
+	for i := range filtered {
+		var a, b, c int
+		if i >= bytesPerPixel {
+			a = int(recon[i-bytesPerPixel])
+			c = int(prev[i-bytesPerPixel])
+		}
+		b = int(prev[i])
+
+		var predictor int
+		switch filterType {
+		case 0:
+			predictor = 0
+		case 1:
+			predictor = a
+		case 2:
+			predictor = b
+		case 3:
+			predictor = (a + b) / 2
+		case 4:
+			p := a + b - c
+			pa, pb, pc := abs(p-a), abs(p-b), abs(p-c)
+			if pa <= pb && pa <= pc {
+				predictor = a
+			} else if pb <= pc {
+				predictor = b
+			} else {
+				predictor = c
+			}
+		}
+		recon[i] = byte((int(filtered[i]) + predictor) % 256)
+	}
+	return recon
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// testBytesPerPixel はビット深度とカラータイプの組み合わせで現れる1ピクセルのバイト数
+var testBytesPerPixel = []int{1, 2, 3, 4, 6, 8}
+
+func randomBytes(r *rand.Rand, n int) []byte {
+	b := make([]byte, n)
+	r.Read(b)
+	return b
+}
+
+func TestUnfilterRowMatchesReference(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, bytesPerPixel := range testBytesPerPixel {
+		for filterType := 0; filterType <= 4; filterType++ {
+			for iteration := 0; iteration < 200; iteration++ {
+				// 1ピクセルに満たない行や端数のある行も含める
+				length := r.Intn(8 * bytesPerPixel)
+				filtered := randomBytes(r, length)
+				prev := randomBytes(r, length)
+
+				want := referenceUnfilter(filterType, filtered, prev, bytesPerPixel)
+				got := append([]byte(nil), filtered...)
+				if err := unfilterRow(filterType, got, prev, bytesPerPixel); err != nil {
+					t.Fatalf("filter %d, bpp %d: %v", filterType, bytesPerPixel, err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Fatalf("filter %d, bpp %d, filtered %v, prev %v: got %v, want %v",
+						filterType, bytesPerPixel, filtered, prev, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestFilterRowRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	for _, bytesPerPixel := range testBytesPerPixel {
+		for filterType := 0; filterType <= 4; filterType++ {
+			for iteration := 0; iteration < 200; iteration++ {
+				length := r.Intn(8 * bytesPerPixel)
+				current := randomBytes(r, length)
+				prev := randomBytes(r, length)
+
+				filtered := make([]byte, length)
+				filterRow(filterType, filtered, current, prev, bytesPerPixel)
+				if got := referenceUnfilter(filterType, filtered, prev, bytesPerPixel); !bytes.Equal(got, current) {
+					t.Fatalf("filter %d, bpp %d, row %v, prev %v: reconstructed %v",
+						filterType, bytesPerPixel, current, prev, got)
+				}
+			}
+		}
+	}
+}
+
+func TestUnfilterMatchesReference(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	for _, bitsPerPixel := range []int{1, 2, 4, 8, 16, 24, 32, 48, 64} {
+		width, height := 1+r.Intn(20), 1+r.Intn(10)
+		rowSize, _ := rowBytes(width, bitsPerPixel)
+		bytesPerPixel := (bitsPerPixel + 7) / 8
+
+		src := randomBytes(r, height*(1+rowSize))
+		for y := 0; y < height; y++ {
+			src[y*(1+rowSize)] = byte(r.Intn(5))
+		}
+		original := append([]byte(nil), src...)
+
+		dst := make([]byte, height*rowSize)
+		if err := Unfilter(dst, src, width, height, bitsPerPixel); err != nil {
+			t.Fatalf("%d bpp: %v", bitsPerPixel, err)
+		}
+		if !bytes.Equal(src, original) {
+			t.Fatalf("%d bpp: Unfilter modified src", bitsPerPixel)
+		}
+
+		prev := make([]byte, rowSize)
+		for y := 0; y < height; y++ {
+			row := src[y*(1+rowSize) : (y+1)*(1+rowSize)]
+			want := referenceUnfilter(int(row[0]), row[1:], prev, bytesPerPixel)
+			if got := dst[y*rowSize : (y+1)*rowSize]; !bytes.Equal(got, want) {
+				t.Fatalf("%d bpp, row %d, filter %d: got %v, want %v", bitsPerPixel, y, row[0], got, want)
+			}
+			prev = want
+		}
+	}
+}
+
+func TestUnfilterRowBadFilterType(t *testing.T) {
+	if err := unfilterRow(5, make([]byte, 4), make([]byte, 4), 1); err == nil {
+		t.Fatal("filter type 5 was accepted")
+	}
+}
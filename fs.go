@@ -0,0 +1,99 @@
+package pngreader
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"io/fs"
+)
+
+// DecodeFS はfsysのnameのPNGファイルをデコードする。
+func DecodeFS(fsys fs.FS, name string) (image.Image, error) {
+	return new(Decoder).DecodeFS(fsys, name)
+}
+
+// DecodeFS はfsysのnameのPNGファイルをデコードする。
+func (d *Decoder) DecodeFS(fsys fs.FS, name string) (image.Image, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return d.Decode(f)
+}
+
+// DecodeInfoFS はfsysのnameのPNGファイルのヘッダとチャンクを読み込む。
+func DecodeInfoFS(fsys fs.FS, name string) (*Info, error) {
+	return new(Decoder).DecodeInfoFS(fsys, name)
+}
+
+// DecodeInfoFS はfsysのnameのPNGファイルのヘッダとチャンクを読み込む。
+func (d *Decoder) DecodeInfoFS(fsys fs.FS, name string) (*Info, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return d.DecodeInfo(f)
+}
+
+// ConformanceReportFS はfsysのnameのPNGファイルの仕様適合性を検証する。
+func ConformanceReportFS(fsys fs.FS, name string) (*Report, error) {
+	return new(Decoder).ConformanceReportFS(fsys, name)
+}
+
+// ConformanceReportFS はdのLimitsを使ってfsysのnameのPNGファイルの仕様適合性を検証する。
+func (d *Decoder) ConformanceReportFS(fsys fs.FS, name string) (*Report, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return d.ConformanceReportContext(context.Background(), f)
+}
+
+// DecodeFlattenFS はfsysのnameのPNGファイルを、bKGDの背景色かfallbackの上に合成して返す。
+func DecodeFlattenFS(fsys fs.FS, name string, fallback color.Color) (image.Image, error) {
+	return new(Decoder).DecodeFlattenFS(fsys, name, fallback)
+}
+
+// DecodeFlattenFS はfsysのnameのPNGファイルを、bKGDの背景色かfallbackの上に合成して返す。
+func (d *Decoder) DecodeFlattenFS(fsys fs.FS, name string, fallback color.Color) (image.Image, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return d.DecodeFlatten(f, fallback)
+}
+
+// DecodeRegionFS はfsysのnameのPNGファイルのうちrectの範囲だけを展開して返す。
+func DecodeRegionFS(fsys fs.FS, name string, rect image.Rectangle) (image.Image, error) {
+	return new(Decoder).DecodeRegionFS(fsys, name, rect)
+}
+
+// DecodeRegionFS はfsysのnameのPNGファイルのうちrectの範囲だけを展開して返す。
+func (d *Decoder) DecodeRegionFS(fsys fs.FS, name string, rect image.Rectangle) (image.Image, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return d.DecodeRegion(f, rect)
+}
+
+// DecodeStatsFS はfsysのnameのPNGファイルを展開し、Statsを返す。
+func DecodeStatsFS(fsys fs.FS, name string) (*Stats, error) {
+	return new(Decoder).DecodeStatsFS(fsys, name, nil)
+}
+
+// DecodeStatsFS はfsysのnameのPNGファイルを展開し、optsで選んだStatsを返す。
+// optsがnilの場合はすべて集める。
+func (d *Decoder) DecodeStatsFS(fsys fs.FS, name string, opts *StatsOptions) (*Stats, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return d.DecodeStatsOptions(f, opts)
+}
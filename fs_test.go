@@ -0,0 +1,107 @@
+package pngreader
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// TestDecodeFS はfs.FSの中のファイルを、パスを付けてデコード・検証できることを確認する。
+func TestDecodeFS(t *testing.T) {
+	m := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	m.SetNRGBA(1, 1, color.NRGBA{10, 20, 30, 40})
+	var b bytes.Buffer
+	if err := Encode(&b, m); err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{
+		"images/a.png":   {Data: b.Bytes()},
+		"images/bad.png": {Data: b.Bytes()[:b.Len()-20]},
+	}
+
+	img, err := DecodeFS(fsys, "images/a.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSamePixels(t, img, m)
+
+	info, err := DecodeInfoFS(fsys, "images/a.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Width != 3 || info.Height != 2 {
+		t.Errorf("info size %dx%d, want 3x2", info.Width, info.Height)
+	}
+
+	report, err := ConformanceReportFS(fsys, "images/bad.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Conformant || report.Error == "" {
+		t.Errorf("truncated file reported as conformant")
+	}
+
+	if _, err := DecodeFS(fsys, "images/missing.png"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing file: got %v, want fs.ErrNotExist", err)
+	}
+}
+
+// testFS は4x3の画像をa.pngに持つfs.FSと、その画像を返す。
+func testFS(t *testing.T) (fstest.MapFS, *image.NRGBA) {
+	t.Helper()
+	m := image.NewNRGBA(image.Rect(0, 0, 4, 3))
+	m.SetNRGBA(2, 1, color.NRGBA{10, 20, 30, 255})
+	var b bytes.Buffer
+	if err := Encode(&b, m); err != nil {
+		t.Fatal(err)
+	}
+	return fstest.MapFS{"a.png": {Data: b.Bytes()}}, m
+}
+
+// TestConformanceReportFSLimits はDecoderのConformanceReportFSがLimitsを使うことを確認する。
+func TestConformanceReportFSLimits(t *testing.T) {
+	fsys, _ := testFS(t)
+	report, err := (&Decoder{Limits: Limits{MaxPixels: 11}}).ConformanceReportFS(fsys, "a.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Conformant || !strings.Contains(report.Error, "limit") {
+		t.Errorf("12 pixels reported as %+v with a limit of 11", report)
+	}
+}
+
+func TestDecodeFlattenFS(t *testing.T) {
+	fsys, _ := testFS(t)
+	flat, err := DecodeFlattenFS(fsys, "a.png", color.White)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := color.NRGBAModel.Convert(flat.At(0, 0)); c != (color.NRGBA{255, 255, 255, 255}) {
+		t.Errorf("transparent pixel flattened to %v", c)
+	}
+}
+
+func TestDecodeRegionFS(t *testing.T) {
+	fsys, m := testFS(t)
+	region, err := DecodeRegionFS(fsys, "a.png", image.Rect(1, 1, 3, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSamePixels(t, region, m.SubImage(image.Rect(1, 1, 3, 2)))
+}
+
+func TestDecodeStatsFS(t *testing.T) {
+	fsys, _ := testFS(t)
+	stats, err := DecodeStatsFS(fsys, "a.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Filters.Total() != 3 {
+		t.Errorf("%d filtered rows, want 3", stats.Filters.Total())
+	}
+}
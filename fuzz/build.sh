@@ -0,0 +1,13 @@
+#!/bin/bash -eu
+# OSS-Fuzzのビルドスクリプト。oss-fuzzのprojects/png-reader/build.shから呼び出す。
+cd "$(dirname "$0")"
+
+for target in FuzzDecode FuzzStrict FuzzEncode; do
+	name=$(echo "$target" | sed 's/^Fuzz//' | tr '[:upper:]' '[:lower:]')
+	compile_go_fuzzer github.com/kouheiszk/png-reader/fuzz "$target" "png_$name"
+	cp png.dict "$OUT/png_$name.dict"
+done
+
+# デコーダのシードコーパスには同梱のPNGを使う
+zip -j "$OUT/png_decode_seed_corpus.zip" ../images/*.png ../testdata/regressions/*.png
+cp "$OUT/png_decode_seed_corpus.zip" "$OUT/png_strict_seed_corpus.zip"
@@ -0,0 +1,142 @@
+// Package fuzz はOSS-Fuzzとgo-fuzzから呼び出すエントリポイントを提供する。
+// 各関数は入力が有効なPNGとして扱われた場合に1、そうでなければ0を返し、
+// デコーダとエンコーダの不変条件が破れた場合はpanicする。
+package fuzz
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+
+	pngreader "github.com/kouheiszk/png-reader"
+	"github.com/kouheiszk/png-reader/gen"
+)
+
+// FuzzDecode はdataをデコードし、成功した場合はエンコードし直したものが
+// 同じ画像にデコードされることを確認する。
+func FuzzDecode(data []byte) int {
+	if tooLarge(data) {
+		return 0
+	}
+	img, err := pngreader.Decode(bytes.NewReader(data))
+	if err != nil {
+		return 0
+	}
+	roundTrip(img, new(pngreader.Encoder))
+	return 1
+}
+
+// FuzzStrict はdataをStrictでデコードし、適合性レポートの結果と一致することを確認する。
+func FuzzStrict(data []byte) int {
+	if tooLarge(data) {
+		return 0
+	}
+	decoder := &pngreader.Decoder{Strict: true}
+	_, err := decoder.Decode(bytes.NewReader(data))
+	report, reportErr := pngreader.ConformanceReport(bytes.NewReader(data))
+	if reportErr != nil {
+		panic(reportErr)
+	}
+	if err == nil && !report.Conformant {
+		panic("strict decode succeeded but the report is not conformant")
+	}
+	if err != nil {
+		return 0
+	}
+	return 1
+}
+
+// FuzzEncode はdataの先頭バイトから形式、大きさ、フィルタを選び、残りをピクセルとして
+// エンコードし、デコード結果をエンコードし直しても変わらないことを確認する。
+func FuzzEncode(data []byte) int {
+	if len(data) < 4 {
+		return 0
+	}
+	formats := gen.Formats()
+	f := formats[int(data[0])%len(formats)]
+	width, height := int(data[1]%32)+1, int(data[2]%32)+1
+	encoder := &pngreader.Encoder{
+		ColorType: f.ColorType,
+		BitDepth:  f.BitDepth,
+		Interlace: f.Interlace,
+		Filter:    pngreader.FilterStrategy(int(data[3]) % 6),
+	}
+	pixels := data[4:]
+	if len(pixels) == 0 {
+		return 0
+	}
+
+	var m image.Image
+	r := image.Rect(0, 0, width, height)
+	if f.ColorType == pngreader.Indexed {
+		encoder.Palette = gen.Palette(f.BitDepth)
+		p := image.NewPaletted(r, encoder.Palette)
+		for i := range p.Pix {
+			p.Pix[i] = uint8(int(pixels[i%len(pixels)]) % len(p.Palette))
+		}
+		m = p
+	} else {
+		n := image.NewNRGBA64(r)
+		for i := range n.Pix {
+			n.Pix[i] = pixels[i%len(pixels)]
+		}
+		m = n
+	}
+
+	var b bytes.Buffer
+	if err := encoder.Encode(&b, m); err != nil {
+		panic(err)
+	}
+	img, err := pngreader.Decode(&b)
+	if err != nil {
+		panic(fmt.Sprintf("%s: decoding encoder output: %v", f.Name(), err))
+	}
+	roundTrip(img, encoder)
+	return 1
+}
+
+// maxPixels はデコードを試す画像の最大ピクセル数。
+// これより大きい画像は出力先の確保だけでメモリを使い果たすため対象外にする。
+const maxPixels = 1 << 20
+
+// tooLarge はdataのIHDRが示す画像がmaxPixelsより大きいかどうかを返す。
+func tooLarge(data []byte) bool {
+	if len(data) < 24 {
+		return false
+	}
+	width := uint64(binary.BigEndian.Uint32(data[16:20]))
+	height := uint64(binary.BigEndian.Uint32(data[20:24]))
+	return width*height > maxPixels
+}
+
+// roundTrip はimgをencoderでエンコードしてデコードし、imgと同じになることを確認する。
+func roundTrip(img image.Image, encoder *pngreader.Encoder) {
+	var b bytes.Buffer
+	if err := encoder.Encode(&b, img); err != nil {
+		panic(err)
+	}
+	again, err := pngreader.Decode(&b)
+	if err != nil {
+		panic(fmt.Sprintf("decoding re-encoded image: %v", err))
+	}
+	if !again.Bounds().Eq(img.Bounds()) {
+		panic(fmt.Sprintf("bounds changed from %v to %v", img.Bounds(), again.Bounds()))
+	}
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if !sameColor(img.At(x, y), again.At(x, y)) {
+				panic(fmt.Sprintf("pixel (%d, %d) changed from %v to %v", x, y, img.At(x, y), again.At(x, y)))
+			}
+		}
+	}
+}
+
+// sameColor はaとbが乗算済みの16ビットの色として等しいかどうかを返す。
+func sameColor(a, b color.Color) bool {
+	ar, ag, ab, aa := a.RGBA()
+	br, bg, bb, ba := b.RGBA()
+	return ar == br && ag == bg && ab == bb && aa == ba
+}
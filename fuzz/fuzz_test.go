@@ -0,0 +1,57 @@
+package fuzz_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/kouheiszk/png-reader/fuzz"
+	"github.com/kouheiszk/png-reader/gen"
+)
+
+// addSeeds は回帰テストのファイルと、genで作ったすべての形式の小さなPNGをシードにする。
+// go testではシードだけを実行し、go test -fuzzで新しい入力を探す。
+func addSeeds(f *testing.F) {
+	files, err := filepath.Glob(filepath.Join("..", "testdata", "regressions", "*.png"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+	for _, format := range gen.Formats() {
+		var b bytes.Buffer
+		if err := gen.Generate(&b, format, 5, 3); err != nil {
+			f.Fatal(err)
+		}
+		f.Add(b.Bytes())
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fuzz.FuzzDecode(data)
+	})
+}
+
+func FuzzStrict(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fuzz.FuzzStrict(data)
+	})
+}
+
+func FuzzEncode(f *testing.F) {
+	f.Add([]byte{0, 1, 1, 0, 0xff})
+	f.Add([]byte{7, 31, 2, 3, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	f.Add(bytes.Repeat([]byte{0x5a}, 200))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fuzz.FuzzEncode(data)
+	})
+}
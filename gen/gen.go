@@ -0,0 +1,181 @@
+// Package gen は、合法なカラータイプとビット深度のすべての組み合わせについて、
+// 既知のピクセルパターンを持つPNGを生成する。
+package gen
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+
+	pngreader "github.com/kouheiszk/png-reader"
+	"github.com/kouheiszk/png-reader/internal/imageutil"
+)
+
+// Format は生成するPNGのカラータイプ、ビット深度、インターレースの組み合わせ
+type Format struct {
+	ColorType pngreader.ColorType
+	BitDepth  int
+	Interlace bool
+}
+
+// combinations はPNG仕様で許されるカラータイプとビット深度の15通りの組み合わせ
+var combinations = []struct {
+	colorType pngreader.ColorType
+	depths    []int
+}{
+	{pngreader.Grayscale, []int{1, 2, 4, 8, 16}},
+	{pngreader.Truecolor, []int{8, 16}},
+	{pngreader.Indexed, []int{1, 2, 4, 8}},
+	{pngreader.GrayscaleAlpha, []int{8, 16}},
+	{pngreader.TruecolorAlpha, []int{8, 16}},
+}
+
+// Formats はすべての組み合わせを、インターレースなし・ありの両方について返す。
+func Formats() []Format {
+	var formats []Format
+	for _, c := range combinations {
+		for _, depth := range c.depths {
+			for _, interlace := range []bool{false, true} {
+				formats = append(formats, Format{c.colorType, depth, interlace})
+			}
+		}
+	}
+	return formats
+}
+
+// Name はファイル名に使える形式名を返す。例: "ct2-d16-interlaced"
+func (f Format) Name() string {
+	name := fmt.Sprintf("ct%d-d%d", f.ColorType, f.BitDepth)
+	if f.Interlace {
+		return name + "-interlaced"
+	}
+	return name
+}
+
+// level はビット深度で表せるサンプル値のうちk番目(0からmaxまで巡回)を返す。
+func level(k, depth int) int {
+	return k % (1 << uint(depth))
+}
+
+// expand はビット深度のサンプル値を16ビットに拡大する。
+func expand(v, depth int) uint16 {
+	return uint16(v * 0xffff / (1<<uint(depth) - 1))
+}
+
+// Palette はIndexedの形式で使うパレットを返す。エントリはビット深度で表せる最大数で、
+// 半分のエントリは半透明になる。
+func Palette(depth int) color.Palette {
+	n := 1 << uint(depth)
+	palette := make(color.Palette, n)
+	for i := range palette {
+		a := uint8(0xff)
+		if i%2 == 1 {
+			a = uint8(i * 0xff / n)
+		}
+		palette[i] = color.NRGBA{uint8(i * 37), uint8(i * 101), uint8(255 - i*13), a}
+	}
+	return palette
+}
+
+// Pattern は形式fで正確に表せる既知のピクセルパターンを返す。
+// 各サンプルは座標とチャンネルから決まる値で、ビット深度で表せるすべての値を巡回する。
+// デコード結果が正しいかどうかはVerifyで確認できる。
+func Pattern(f Format, width, height int) image.Image {
+	r := image.Rect(0, 0, width, height)
+	if f.ColorType == pngreader.Indexed {
+		m := image.NewPaletted(r, Palette(f.BitDepth))
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				m.SetColorIndex(x, y, uint8(level(x+2*y, f.BitDepth)))
+			}
+		}
+		return m
+	}
+
+	m := image.NewNRGBA64(r)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			sample := func(channel int) uint16 {
+				return expand(level(x+3*y+5*channel, f.BitDepth), f.BitDepth)
+			}
+			c := color.NRGBA64{A: 0xffff}
+			switch f.ColorType {
+			case pngreader.Grayscale:
+				c.R = sample(0)
+				c.G, c.B = c.R, c.R
+			case pngreader.Truecolor:
+				c.R, c.G, c.B = sample(0), sample(1), sample(2)
+			case pngreader.GrayscaleAlpha:
+				c.R, c.A = sample(0), sample(3)
+				c.G, c.B = c.R, c.R
+			case pngreader.TruecolorAlpha:
+				c.R, c.G, c.B, c.A = sample(0), sample(1), sample(2), sample(3)
+			}
+			m.SetNRGBA64(x, y, c)
+		}
+	}
+	return m
+}
+
+// Verify はデコードされた画像mが形式fのPatternと一致するかを確認し、
+// 最初に見つかった不一致をエラーとして返す。比較はビット深度で表せる精度で行う。
+func Verify(f Format, m image.Image) error {
+	b := m.Bounds()
+	want := Pattern(f, b.Dx(), b.Dy())
+	depth := f.BitDepth
+	if f.ColorType == pngreader.Indexed {
+		// パレットの色は常に8ビット
+		depth = 8
+	}
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			w := reduce(imageutil.NRGBA64(want.At(x, y)), depth)
+			g := reduce(imageutil.NRGBA64(m.At(b.Min.X+x, b.Min.Y+y)), depth)
+			if w != g {
+				return fmt.Errorf("%s: pixel (%d, %d) is %v, want %v", f.Name(), x, y, g, w)
+			}
+		}
+	}
+	return nil
+}
+
+// reduce はcの各サンプルをビット深度の値に丸める。
+func reduce(c color.NRGBA64, depth int) [4]uint32 {
+	max := uint32(1<<uint(depth) - 1)
+	var v [4]uint32
+	for i, s := range []uint16{c.R, c.G, c.B, c.A} {
+		v[i] = (uint32(s)*max + 0x7fff) / 0xffff
+	}
+	return v
+}
+
+// Generate は形式fでwidth×heightのPatternをPNGとしてwに書き込む。
+func Generate(w io.Writer, f Format, width, height int) error {
+	encoder := &pngreader.Encoder{
+		ColorType: f.ColorType,
+		BitDepth:  f.BitDepth,
+		Interlace: f.Interlace,
+	}
+	return encoder.Encode(w, Pattern(f, width, height))
+}
+
+// WriteAll はすべての形式のPNGをdirに<形式名>.pngとして書き込む。
+func WriteAll(dir string, width, height int) error {
+	for _, f := range Formats() {
+		file, err := os.Create(filepath.Join(dir, f.Name()+".png"))
+		if err != nil {
+			return err
+		}
+		err = Generate(file, f, width, height)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", f.Name(), err)
+		}
+	}
+	return nil
+}
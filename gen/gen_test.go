@@ -0,0 +1,53 @@
+package gen
+
+import (
+	"bytes"
+	"testing"
+
+	pngreader "github.com/kouheiszk/png-reader"
+)
+
+// TestRoundTrip はすべての形式について、Generateで書いたPNGをStrictでデコードし、
+// Patternと一致することを確認する。大きさはインターレースのパスが空になる場合と、
+// 行の終わりで1バイトに満たないビットが余る場合を含める。
+func TestRoundTrip(t *testing.T) {
+	sizes := []struct{ width, height int }{{1, 1}, {3, 2}, {33, 17}}
+	for _, f := range Formats() {
+		for _, size := range sizes {
+			t.Run(f.Name(), func(t *testing.T) {
+				var b bytes.Buffer
+				if err := Generate(&b, f, size.width, size.height); err != nil {
+					t.Fatal(err)
+				}
+				decoder := &pngreader.Decoder{Strict: true}
+				m, err := decoder.Decode(&b)
+				if err != nil {
+					t.Fatalf("%dx%d: %v", size.width, size.height, err)
+				}
+				if got := m.Bounds().Size(); got.X != size.width || got.Y != size.height {
+					t.Fatalf("decoded size %v, want %dx%d", got, size.width, size.height)
+				}
+				if err := Verify(f, m); err != nil {
+					t.Errorf("%dx%d: %v", size.width, size.height, err)
+				}
+			})
+		}
+	}
+}
+
+// TestFormats はPNG仕様で許される15通りの組み合わせを、インターレースなし・ありの両方で返すことを確認する。
+func TestFormats(t *testing.T) {
+	formats := Formats()
+	if len(formats) != 30 {
+		t.Fatalf("got %d formats, want 30", len(formats))
+	}
+	interlaced := 0
+	for _, f := range formats {
+		if f.Interlace {
+			interlaced++
+		}
+	}
+	if interlaced != 15 {
+		t.Errorf("got %d interlaced formats, want 15", interlaced)
+	}
+}
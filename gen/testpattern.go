@@ -0,0 +1,134 @@
+package gen
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math/rand"
+
+	pngreader "github.com/kouheiszk/png-reader"
+	"github.com/kouheiszk/png-reader/colors"
+)
+
+// Kind はテストパターンの種類
+type Kind string
+
+const (
+	// Gradient は上から灰色、赤、緑、青の4本の帯で、それぞれ左端の0から右端の最大値まで変化する
+	Gradient Kind = "gradient"
+	// Bars は白、黄、シアン、緑、マゼンタ、赤、青、黒の8本の縦のカラーバー
+	Bars Kind = "bars"
+	// Checkerboard は白と黒の市松模様
+	Checkerboard Kind = "checkerboard"
+	// Noise はチャンネルごとに独立した一様乱数
+	Noise Kind = "noise"
+)
+
+// Kinds はすべてのテストパターンの種類
+var Kinds = []Kind{Gradient, Bars, Checkerboard, Noise}
+
+// TestOptions はテストパターンの設定
+type TestOptions struct {
+	Kind          Kind
+	Width, Height int
+	// Levels はサンプル値の段階数。0の場合は16ビットの精度で作る。
+	// 低いビット深度で書き込む場合に2^ビット深度を指定すると、丸めによる偏りのない値になる
+	Levels int
+	// Alpha が真の場合、アルファを上端の不透明から下端の透明まで変化させる
+	Alpha bool
+	// Cell はCheckerboardのマスの大きさ。0の場合は8
+	Cell int
+	// Seed はNoiseの乱数の種
+	Seed int64
+}
+
+// barColors はBarsの色を左から並べたもの
+var barColors = [][3]float64{
+	{1, 1, 1}, {1, 1, 0}, {0, 1, 1}, {0, 1, 0},
+	{1, 0, 1}, {1, 0, 0}, {0, 0, 1}, {0, 0, 0},
+}
+
+// TestPattern は表示の確認やデコーダのテスト用のパターンを作る。
+func TestPattern(opts TestOptions) (*image.NRGBA64, error) {
+	if opts.Width <= 0 || opts.Height <= 0 {
+		return nil, fmt.Errorf("invalid size %dx%d", opts.Width, opts.Height)
+	}
+	if opts.Levels == 1 || opts.Levels < 0 || opts.Levels > 1<<16 {
+		return nil, fmt.Errorf("invalid number of levels %d", opts.Levels)
+	}
+	cell := opts.Cell
+	if cell == 0 {
+		cell = 8
+	}
+	if cell < 0 {
+		return nil, fmt.Errorf("invalid cell size %d", cell)
+	}
+	q := func(v float64) uint16 {
+		if opts.Levels == 0 {
+			return uint16(v*0xffff + 0.5)
+		}
+		k := int(v*float64(opts.Levels-1) + 0.5)
+		return uint16(k * 0xffff / (opts.Levels - 1))
+	}
+	// ramp はn個のうちi番目の位置を0から1で返す
+	ramp := func(i, n int) float64 {
+		if n < 2 {
+			return 0
+		}
+		return float64(i) / float64(n-1)
+	}
+	rng := rand.New(rand.NewSource(opts.Seed))
+
+	w, h := opts.Width, opts.Height
+	m := image.NewNRGBA64(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		a := uint16(0xffff)
+		if opts.Alpha {
+			a = q(1 - ramp(y, h))
+		}
+		for x := 0; x < w; x++ {
+			var r, g, b float64
+			switch opts.Kind {
+			case Gradient:
+				v := ramp(x, w)
+				switch y * 4 / h {
+				case 0:
+					r, g, b = v, v, v
+				case 1:
+					r = v
+				case 2:
+					g = v
+				case 3:
+					b = v
+				}
+			case Bars:
+				c := barColors[x*len(barColors)/w]
+				r, g, b = c[0], c[1], c[2]
+			case Checkerboard:
+				if (x/cell+y/cell)%2 == 0 {
+					r, g, b = 1, 1, 1
+				}
+			case Noise:
+				r, g, b = rng.Float64(), rng.Float64(), rng.Float64()
+			default:
+				return nil, fmt.Errorf("unknown test pattern %q", opts.Kind)
+			}
+			m.SetNRGBA64(x, y, color.NRGBA64{q(r), q(g), q(b), a})
+		}
+	}
+	return m, nil
+}
+
+// TestEncoder はmを形式fのPNGとして書き込むEncoderを返す。
+// Indexedの場合はmの色からパレットを作り、ビット深度に収まらない場合はエラーを返す。
+func TestEncoder(f Format, m image.Image) (*pngreader.Encoder, error) {
+	encoder := &pngreader.Encoder{ColorType: f.ColorType, BitDepth: f.BitDepth, Interlace: f.Interlace}
+	if f.ColorType == pngreader.Indexed {
+		analysis := colors.Analyze(m)
+		if !analysis.FitsPalette || analysis.Unique > 1<<uint(f.BitDepth) {
+			return nil, fmt.Errorf("%d colors do not fit a palette of bit depth %d", analysis.Unique, f.BitDepth)
+		}
+		encoder.Palette = analysis.Palette()
+	}
+	return encoder, nil
+}
@@ -0,0 +1,102 @@
+package gen
+
+import (
+	"bytes"
+	"image/color"
+	"testing"
+
+	pngreader "github.com/kouheiszk/png-reader"
+)
+
+func TestTestPattern(t *testing.T) {
+	white, black := color.NRGBA64{0xffff, 0xffff, 0xffff, 0xffff}, color.NRGBA64{A: 0xffff}
+	tests := []struct {
+		opts TestOptions
+		x, y int
+		want color.NRGBA64
+	}{
+		{TestOptions{Kind: Gradient, Width: 5, Height: 4}, 4, 0, white},
+		{TestOptions{Kind: Gradient, Width: 5, Height: 4}, 0, 0, black},
+		{TestOptions{Kind: Gradient, Width: 5, Height: 4}, 4, 1, color.NRGBA64{R: 0xffff, A: 0xffff}},
+		{TestOptions{Kind: Gradient, Width: 5, Height: 4}, 2, 3, color.NRGBA64{B: 0x8000, A: 0xffff}},
+		{TestOptions{Kind: Bars, Width: 16, Height: 1}, 0, 0, white},
+		{TestOptions{Kind: Bars, Width: 16, Height: 1}, 2, 0, color.NRGBA64{0xffff, 0xffff, 0, 0xffff}},
+		{TestOptions{Kind: Bars, Width: 16, Height: 1}, 15, 0, black},
+		{TestOptions{Kind: Checkerboard, Width: 4, Height: 4, Cell: 2}, 1, 1, white},
+		{TestOptions{Kind: Checkerboard, Width: 4, Height: 4, Cell: 2}, 2, 1, black},
+		{TestOptions{Kind: Checkerboard, Width: 4, Height: 4, Cell: 2}, 3, 3, white},
+		// 4段階では0x5555刻みに丸める
+		{TestOptions{Kind: Gradient, Width: 5, Height: 4, Levels: 4}, 1, 0, color.NRGBA64{0x5555, 0x5555, 0x5555, 0xffff}},
+		{TestOptions{Kind: Bars, Width: 8, Height: 3, Alpha: true}, 0, 1, color.NRGBA64{0xffff, 0xffff, 0xffff, 0x8000}},
+		{TestOptions{Kind: Bars, Width: 8, Height: 3, Alpha: true}, 7, 2, color.NRGBA64{}},
+	}
+	for _, tt := range tests {
+		m, err := TestPattern(tt.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := m.NRGBA64At(tt.x, tt.y); got != tt.want {
+			t.Errorf("%+v: (%d, %d) = %v, want %v", tt.opts, tt.x, tt.y, got, tt.want)
+		}
+	}
+}
+
+func TestNoise(t *testing.T) {
+	noise := func(seed int64) []byte {
+		m, err := TestPattern(TestOptions{Kind: Noise, Width: 8, Height: 8, Seed: seed})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m.Pix
+	}
+	if !bytes.Equal(noise(1), noise(1)) {
+		t.Error("the same seed produced different noise")
+	}
+	if bytes.Equal(noise(1), noise(2)) {
+		t.Error("different seeds produced the same noise")
+	}
+}
+
+func TestTestPatternErrors(t *testing.T) {
+	for _, opts := range []TestOptions{
+		{Kind: Bars, Width: 0, Height: 1},
+		{Kind: Bars, Width: 1, Height: 1, Levels: 1},
+		{Kind: Bars, Width: 1, Height: 1, Levels: 1<<16 + 1},
+		{Kind: Checkerboard, Width: 1, Height: 1, Cell: -1},
+		{Kind: "stripes", Width: 1, Height: 1},
+	} {
+		if _, err := TestPattern(opts); err == nil {
+			t.Errorf("%+v: no error", opts)
+		}
+	}
+}
+
+// TestTestEncoder はパレットに収まるパターンをIndexedで書き出して同じ色に戻り、
+// ビット深度に収まらない場合はエラーになることを確認する。
+func TestTestEncoder(t *testing.T) {
+	m, err := TestPattern(TestOptions{Kind: Bars, Width: 16, Height: 2, Levels: 256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := TestEncoder(Format{ColorType: pngreader.Indexed, BitDepth: 2}, m); err == nil {
+		t.Error("8 colors accepted for a 2-bit palette")
+	}
+	encoder, err := TestEncoder(Format{ColorType: pngreader.Indexed, BitDepth: 4, Interlace: true}, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := encoder.Encode(&b, m); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := (&pngreader.Decoder{Strict: true}).Decode(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x := 0; x < 16; x++ {
+		got := color.NRGBA64Model.Convert(decoded.At(x, 1))
+		if want := m.NRGBA64At(x, 1); got != want {
+			t.Errorf("(%d, 1) = %v, want %v", x, got, want)
+		}
+	}
+}
@@ -0,0 +1,5 @@
+module github.com/kouheiszk/png-reader
+
+go 1.21
+
+require golang.org/x/crypto v0.23.0
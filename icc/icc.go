@@ -0,0 +1,264 @@
+// Package icc はICCプロファイルを読み込み、画素をプロファイルの色空間からsRGBに変換する。
+// cgoやカラーマネジメントモジュールを使わず、マトリクス/TRC形式とLUT形式
+// (lut8Type、lut16Type、lutAtoBType)のRGBとグレーのプロファイルを扱う。
+// レンダリングインテントは知覚的(A2B0)を優先し、なければマトリクス/TRCを使う。
+package icc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// プロファイルのクラス
+const (
+	ClassInput      = "scnr"
+	ClassDisplay    = "mntr"
+	ClassOutput     = "prtr"
+	ClassColorSpace = "spac"
+)
+
+// 色空間とPCSのシグネチャ
+const (
+	SpaceRGB  = "RGB "
+	SpaceGray = "GRAY"
+	SpaceXYZ  = "XYZ "
+	SpaceLab  = "Lab "
+)
+
+// headerSize はプロファイルヘッダのバイト数
+const headerSize = 128
+
+// Profile は読み込んだICCプロファイル
+type Profile struct {
+	// Version はヘッダのバージョン。上位8ビットがメジャーバージョン
+	Version uint32
+
+	// Class、ColorSpace、PCS はヘッダのシグネチャ
+	Class      string
+	ColorSpace string
+	PCS        string
+
+	// Description はdescタグの説明。タグがない場合は空
+	Description string
+
+	// toPCS はデバイスの値(0〜1)をD50のXYZに変換する。
+	toPCS transform
+}
+
+// transform はデバイスの値をD50のXYZに変換する。
+type transform interface {
+	xyz(in []float64) [3]float64
+}
+
+// tag はタグテーブルの1項目が指すデータ
+type tag []byte
+
+// typeSignature はタグのデータの型のシグネチャを返す。
+func (t tag) typeSignature() string {
+	if len(t) < 8 {
+		return ""
+	}
+	return string(t[:4])
+}
+
+var errTruncated = errors.New("icc: truncated profile")
+
+// Parse はICCプロファイルを読み込む。RGBとグレー以外の色空間や、
+// 変換に必要なタグがないプロファイルはエラーにする。
+func Parse(data []byte) (*Profile, error) {
+	if len(data) < headerSize+4 {
+		return nil, errTruncated
+	}
+	if string(data[36:40]) != "acsp" {
+		return nil, fmt.Errorf("icc: invalid profile signature")
+	}
+	if size := binary.BigEndian.Uint32(data); size > uint32(len(data)) {
+		return nil, errTruncated
+	}
+
+	p := &Profile{
+		Version:    binary.BigEndian.Uint32(data[8:12]),
+		Class:      string(data[12:16]),
+		ColorSpace: string(data[16:20]),
+		PCS:        string(data[20:24]),
+	}
+	switch p.Class {
+	case ClassInput, ClassDisplay, ClassOutput, ClassColorSpace:
+	default:
+		return nil, fmt.Errorf("icc: unsupported profile class %q", p.Class)
+	}
+	if p.ColorSpace != SpaceRGB && p.ColorSpace != SpaceGray {
+		return nil, fmt.Errorf("icc: unsupported color space %q", strings.TrimSpace(p.ColorSpace))
+	}
+	if p.PCS != SpaceXYZ && p.PCS != SpaceLab {
+		return nil, fmt.Errorf("icc: unsupported PCS %q", strings.TrimSpace(p.PCS))
+	}
+
+	tags, err := readTags(data)
+	if err != nil {
+		return nil, err
+	}
+	if desc, ok := tags["desc"]; ok {
+		p.Description = readText(desc)
+	}
+
+	if a2b0, ok := tags["A2B0"]; ok {
+		p.toPCS, err = readLUT(a2b0, p.PCS)
+		if err != nil {
+			return nil, err
+		}
+		if n := len(p.toPCS.(*lut).a); (p.ColorSpace == SpaceRGB && n != 3) || (p.ColorSpace == SpaceGray && n != 1) {
+			return nil, fmt.Errorf("icc: A2B0 has %d input channels", n)
+		}
+		return p, nil
+	}
+	if p.ColorSpace == SpaceGray {
+		p.toPCS, err = readGrayTRC(tags, p.PCS)
+	} else {
+		p.toPCS, err = readMatrixTRC(tags)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// readTags はタグテーブルを読み込み、シグネチャごとのデータを返す。
+func readTags(data []byte) (map[string]tag, error) {
+	count := binary.BigEndian.Uint32(data[headerSize:])
+	if uint64(count)*12 > uint64(len(data)-headerSize-4) {
+		return nil, errTruncated
+	}
+	tags := make(map[string]tag, count)
+	for i := 0; i < int(count); i++ {
+		entry := data[headerSize+4+i*12:]
+		offset := binary.BigEndian.Uint32(entry[4:8])
+		size := binary.BigEndian.Uint32(entry[8:12])
+		if uint64(offset)+uint64(size) > uint64(len(data)) {
+			return nil, fmt.Errorf("icc: tag %q out of range", entry[:4])
+		}
+		tags[string(entry[:4])] = data[offset : offset+size]
+	}
+	return tags, nil
+}
+
+// readText はtextDescriptionType、multiLocalizedUnicodeType、textTypeのタグから文字列を読み込む。
+// 読み込めない場合は空文字列を返す。
+func readText(t tag) string {
+	switch t.typeSignature() {
+	case "desc":
+		if len(t) < 12 {
+			return ""
+		}
+		n := binary.BigEndian.Uint32(t[8:12])
+		if uint64(n) > uint64(len(t)-12) {
+			return ""
+		}
+		return strings.TrimRight(string(t[12:12+n]), "\x00")
+	case "mluc":
+		// 最初の言語の文字列を使う
+		if len(t) < 28 || binary.BigEndian.Uint32(t[8:12]) == 0 {
+			return ""
+		}
+		length := binary.BigEndian.Uint32(t[20:24])
+		offset := binary.BigEndian.Uint32(t[24:28])
+		if uint64(offset)+uint64(length) > uint64(len(t)) {
+			return ""
+		}
+		s := t[offset : offset+length]
+		units := make([]uint16, len(s)/2)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(s[i*2:])
+		}
+		return strings.TrimRight(string(utf16.Decode(units)), "\x00")
+	case "text":
+		return strings.TrimRight(string(t[8:]), "\x00")
+	}
+	return ""
+}
+
+// readXYZ はXYZTypeのタグから最初のXYZの値を読み込む。
+func readXYZ(t tag) ([3]float64, error) {
+	if t.typeSignature() != "XYZ " || len(t) < 20 {
+		return [3]float64{}, fmt.Errorf("icc: invalid XYZ tag")
+	}
+	return [3]float64{s15Fixed16(t[8:]), s15Fixed16(t[12:]), s15Fixed16(t[16:])}, nil
+}
+
+func s15Fixed16(b []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(b))) / 65536
+}
+
+// matrixTRC はRGBの各チャネルをトーンカーブで線形化し、マトリクスでXYZに変換する。
+type matrixTRC struct {
+	trc    [3]curve
+	matrix [3][3]float64
+}
+
+func readMatrixTRC(tags map[string]tag) (*matrixTRC, error) {
+	m := new(matrixTRC)
+	for i, channel := range []string{"r", "g", "b"} {
+		xyzTag, ok := tags[channel+"XYZ"]
+		trcTag, ok2 := tags[channel+"TRC"]
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("icc: missing %sXYZ or %sTRC tag", channel, channel)
+		}
+		xyz, err := readXYZ(xyzTag)
+		if err != nil {
+			return nil, err
+		}
+		for row := range xyz {
+			m.matrix[row][i] = xyz[row]
+		}
+		if m.trc[i], _, err = readCurve(trcTag); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (m *matrixTRC) xyz(in []float64) [3]float64 {
+	var linear [3]float64
+	for i := range linear {
+		linear[i] = m.trc[i](in[i])
+	}
+	return multiply(&m.matrix, linear)
+}
+
+// grayTRC はグレーの値をトーンカーブで輝度にし、D50の白色点の色度でXYZにする。
+type grayTRC struct {
+	trc curve
+	pcs string
+}
+
+func readGrayTRC(tags map[string]tag, pcs string) (*grayTRC, error) {
+	t, ok := tags["kTRC"]
+	if !ok {
+		return nil, fmt.Errorf("icc: missing kTRC tag")
+	}
+	trc, _, err := readCurve(t)
+	if err != nil {
+		return nil, err
+	}
+	return &grayTRC{trc: trc, pcs: pcs}, nil
+}
+
+func (g *grayTRC) xyz(in []float64) [3]float64 {
+	y := g.trc(in[0])
+	if g.pcs == SpaceLab {
+		// PCSがLabの場合、カーブの出力はL*を表す
+		return labToXYZ([3]float64{y * 100, 0, 0})
+	}
+	return [3]float64{d50[0] * y, d50[1] * y, d50[2] * y}
+}
+
+func multiply(m *[3][3]float64, v [3]float64) [3]float64 {
+	return [3]float64{
+		m[0][0]*v[0] + m[0][1]*v[1] + m[0][2]*v[2],
+		m[1][0]*v[0] + m[1][1]*v[1] + m[1][2]*v[2],
+		m[2][0]*v[0] + m[2][1]*v[1] + m[2][2]*v[2],
+	}
+}
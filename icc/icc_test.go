@@ -0,0 +1,296 @@
+package icc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// tagData はテスト用のプロファイルに入れるタグ
+type tagData struct {
+	sig  string
+	data []byte
+}
+
+// fixed はvをs15Fixed16Numberにする。
+func fixed(v float64) []byte {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(int32(math.Round(v*65536))))
+	return b[:]
+}
+
+// xyzTag はXYZTypeのタグを返す。
+func xyzTag(v [3]float64) []byte {
+	b := []byte("XYZ \x00\x00\x00\x00")
+	for _, x := range v {
+		b = append(b, fixed(x)...)
+	}
+	return b
+}
+
+// srgbPara はsRGBのトーンカーブを表すparametricCurveTypeのタグを返す。
+func srgbPara() []byte {
+	b := []byte("para\x00\x00\x00\x00\x00\x03\x00\x00")
+	for _, v := range []float64{2.4, 1 / 1.055, 0.055 / 1.055, 1 / 12.92, 0.04045} {
+		b = append(b, fixed(v)...)
+	}
+	return b
+}
+
+// build はヘッダ、タグテーブル、タグのデータを並べたプロファイルを返す。
+func build(class, space, pcs string, version uint32, tags []tagData) []byte {
+	var body bytes.Buffer
+	offset := 128 + 4 + 12*len(tags)
+	var table bytes.Buffer
+	binary.Write(&table, binary.BigEndian, uint32(len(tags)))
+	for _, t := range tags {
+		for len(t.data)%4 != 0 {
+			t.data = append(t.data, 0)
+		}
+		table.WriteString(t.sig)
+		binary.Write(&table, binary.BigEndian, uint32(offset+body.Len()))
+		binary.Write(&table, binary.BigEndian, uint32(len(t.data)))
+		body.Write(t.data)
+	}
+	header := make([]byte, 128)
+	binary.BigEndian.PutUint32(header[0:], uint32(128+table.Len()+body.Len()))
+	binary.BigEndian.PutUint32(header[8:], version)
+	copy(header[12:], class)
+	copy(header[16:], space)
+	copy(header[20:], pcs)
+	copy(header[36:], "acsp")
+	return append(append(header, table.Bytes()...), body.Bytes()...)
+}
+
+var srgbColorants = [3][3]float64{
+	{0.4360747, 0.2225045, 0.0139322},
+	{0.3850649, 0.7168786, 0.0971045},
+	{0.1430804, 0.0606169, 0.7141733},
+}
+
+// descTag はtextDescriptionTypeのタグを返す。
+func descTag(s string) []byte {
+	b := []byte("desc\x00\x00\x00\x00")
+	var n [4]byte
+	binary.BigEndian.PutUint32(n[:], uint32(len(s)+1))
+	b = append(b, n[:]...)
+	return append(append(b, s...), 0)
+}
+
+// srgbMatrix はsRGBと同じ原色とトーンカーブを持つ、マトリクスとTRCのプロファイルを返す。
+func srgbMatrix() []byte {
+	return build("mntr", "RGB ", "XYZ ", 0x02100000, []tagData{
+		{"desc", descTag("sRGB test")},
+		{"rXYZ", xyzTag(srgbColorants[0])}, {"gXYZ", xyzTag(srgbColorants[1])}, {"bXYZ", xyzTag(srgbColorants[2])},
+		{"rTRC", srgbPara()}, {"gTRC", srgbPara()}, {"bTRC", srgbPara()},
+	})
+}
+
+// decodeSRGB はsRGBの値を線形の値にする。
+func decodeSRGB(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// xyzToLab はD50のXYZをCIELABにする。
+func xyzToLab(x [3]float64) [3]float64 {
+	f := func(t float64) float64 {
+		if t > math.Pow(6.0/29, 3) {
+			return math.Cbrt(t)
+		}
+		return t/(3*(6.0/29)*(6.0/29)) + 4.0/29
+	}
+	fx, fy, fz := f(x[0]/d50[0]), f(x[1]/d50[1]), f(x[2]/d50[2])
+	return [3]float64{116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)}
+}
+
+// srgbLUT16 はsRGBのRGBからLab(旧エンコーディング)へのlut16Typeを作る。
+func srgbLUT16(grid int) []byte {
+	b := []byte("mft2\x00\x00\x00\x00")
+	b = append(b, 3, 3, byte(grid), 0)
+	for _, v := range []float64{1, 0, 0, 0, 1, 0, 0, 0, 1} {
+		b = append(b, fixed(v)...)
+	}
+	b = append(b, 0, 2, 0, 2)
+	u16 := func(v float64) {
+		x := uint16(math.Round(math.Max(0, math.Min(1, v)) * 0xffff))
+		b = append(b, byte(x>>8), byte(x))
+	}
+	for i := 0; i < 3; i++ {
+		u16(0)
+		u16(1)
+	}
+	for r := 0; r < grid; r++ {
+		for g := 0; g < grid; g++ {
+			for bl := 0; bl < grid; bl++ {
+				lin := [3]float64{decodeSRGB(float64(r) / float64(grid-1)), decodeSRGB(float64(g) / float64(grid-1)), decodeSRGB(float64(bl) / float64(grid-1))}
+				var xyz [3]float64
+				for i := 0; i < 3; i++ {
+					for j := 0; j < 3; j++ {
+						xyz[i] += srgbColorants[j][i] * lin[j]
+					}
+				}
+				lab := xyzToLab(xyz)
+				s := 65280.0 / 65535
+				u16(lab[0] / 100 * s)
+				u16((lab[1] + 128) / 255 * s)
+				u16((lab[2] + 128) / 255 * s)
+			}
+		}
+	}
+	for i := 0; i < 3; i++ {
+		u16(0)
+		u16(1)
+	}
+	return b
+}
+
+// TestMatrixTRC はsRGBと同じマトリクスとTRCのプロファイルで変換しても、色がほとんど変わらないことを確認する。
+func TestMatrixTRC(t *testing.T) {
+	p, err := Parse(srgbMatrix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Description != "sRGB test" {
+		t.Errorf("description %q, want %q", p.Description, "sRGB test")
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, 256, 1))
+	for x := 0; x < 256; x++ {
+		img.SetNRGBA(x, 0, color.NRGBA{uint8(x), uint8(255 - x), uint8(x * 7), uint8(x)})
+	}
+	out := p.Transform(img).(*image.NRGBA)
+	for x := 0; x < 256; x++ {
+		a, b := img.NRGBAAt(x, 0), out.NRGBAAt(x, 0)
+		if diff(a.R, b.R) > 1 || diff(a.G, b.G) > 1 || diff(a.B, b.B) > 1 || a.A != b.A {
+			t.Errorf("%v -> %v", a, b)
+		}
+	}
+}
+
+func diff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+// TestLUT16 はsRGBからLabへのlut16TypeのA2B0で、色がほとんど変わらないことを確認する。
+func TestLUT16(t *testing.T) {
+	prof := build("mntr", "RGB ", "Lab ", 0x02100000, []tagData{{"A2B0", srgbLUT16(33)}})
+	p, err := Parse(prof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewNRGBA64(image.Rect(0, 0, 64, 1))
+	for x := 0; x < 64; x++ {
+		img.SetNRGBA64(x, 0, color.NRGBA64{uint16(x * 1000), uint16(65535 - x*900), uint16(x * 555), 0xffff})
+	}
+	out := p.Transform(img).(*image.NRGBA64)
+	for x := 0; x < 64; x++ {
+		a, b := img.NRGBA64At(x, 0), out.NRGBA64At(x, 0)
+		for _, pair := range [][2]uint16{{a.R, b.R}, {a.G, b.G}, {a.B, b.B}} {
+			if math.Abs(float64(pair[0])-float64(pair[1])) > 0x200 {
+				t.Errorf("%v -> %v", a, b)
+				break
+			}
+		}
+	}
+}
+
+// TestGrayTRC はグレースケールのプロファイルのkTRCを適用し、sRGBのグレーにすることを確認する。
+func TestGrayTRC(t *testing.T) {
+	curv := []byte("curv\x00\x00\x00\x00\x00\x00\x00\x01\x01\xcd") // ガンマ1.8
+	p, err := Parse(build("mntr", "GRAY", "XYZ ", 0x04000000, []tagData{{"kTRC", curv}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{128, 128, 128, 255})
+	c := p.Transform(img).(*image.NRGBA).NRGBAAt(0, 0)
+	y := math.Pow(128.0/255, 461.0/256)
+	want := uint8(math.Round(encodeSRGB(y) * 255))
+	if c.R != want || c.G != want || c.B != want {
+		t.Errorf("got %v, want gray %d", c, want)
+	}
+}
+
+// TestParseInvalid は解釈できないプロファイルをエラーにし、途中で切れたプロファイルでpanicしないことを確認する。
+func TestParseInvalid(t *testing.T) {
+	for _, data := range [][]byte{nil, make([]byte, 200), build("mntr", "CMYK", "Lab ", 0, nil), build("mntr", "RGB ", "XYZ ", 0, nil)} {
+		if _, err := Parse(data); err == nil {
+			t.Errorf("accepted %d bytes", len(data))
+		}
+	}
+	good := srgbMatrix()
+	for n := 0; n < len(good); n++ {
+		Parse(good[:n])
+	}
+	lut := build("mntr", "RGB ", "Lab ", 0x02100000, []tagData{{"A2B0", srgbLUT16(5)}})
+	for n := 0; n < len(lut); n++ {
+		Parse(lut[:n])
+	}
+}
+
+// TestLUTAtoB はsRGBからLabへのlutAtoBTypeのA2B0で、色がほとんど変わらないことを確認する。
+func TestLUTAtoB(t *testing.T) {
+	grid := 17
+	identityCurves := bytes.Repeat([]byte("curv\x00\x00\x00\x00\x00\x00\x00\x00"), 3)
+	var clutData []byte
+	clutData = append(clutData, bytes.Repeat([]byte{byte(grid)}, 3)...)
+	clutData = append(clutData, make([]byte, 13)...)
+	clutData = append(clutData, 2, 0, 0, 0)
+	u16 := func(v float64) {
+		x := uint16(math.Round(math.Max(0, math.Min(1, v)) * 0xffff))
+		clutData = append(clutData, byte(x>>8), byte(x))
+	}
+	for r := 0; r < grid; r++ {
+		for g := 0; g < grid; g++ {
+			for bl := 0; bl < grid; bl++ {
+				lin := [3]float64{decodeSRGB(float64(r) / float64(grid-1)), decodeSRGB(float64(g) / float64(grid-1)), decodeSRGB(float64(bl) / float64(grid-1))}
+				var xyz [3]float64
+				for i := 0; i < 3; i++ {
+					for j := 0; j < 3; j++ {
+						xyz[i] += srgbColorants[j][i] * lin[j]
+					}
+				}
+				lab := xyzToLab(xyz)
+				u16(lab[0] / 100)
+				u16((lab[1] + 128) / 255)
+				u16((lab[2] + 128) / 255)
+			}
+		}
+	}
+	for len(clutData)%4 != 0 {
+		clutData = append(clutData, 0)
+	}
+	head := []byte("mAB \x00\x00\x00\x00\x03\x03\x00\x00")
+	bOff := 32
+	clutOff := bOff + len(identityCurves)
+	aOff := clutOff + len(clutData)
+	for _, o := range []int{bOff, 0, 0, clutOff, aOff} {
+		var x [4]byte
+		binary.BigEndian.PutUint32(x[:], uint32(o))
+		head = append(head, x[:]...)
+	}
+	tag := append(append(append(head, identityCurves...), clutData...), identityCurves...)
+	p, err := Parse(build("mntr", "RGB ", "Lab ", 0x04200000, []tagData{{"A2B0", tag}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, 256, 1))
+	for x := 0; x < 256; x++ {
+		img.SetNRGBA(x, 0, color.NRGBA{uint8(x), uint8(255 - x), uint8(x * 7), 255})
+	}
+	out := p.Transform(img).(*image.NRGBA)
+	for x := 0; x < 256; x++ {
+		a, b := img.NRGBAAt(x, 0), out.NRGBAAt(x, 0)
+		if diff(a.R, b.R) > 2 || diff(a.G, b.G) > 2 || diff(a.B, b.B) > 2 {
+			t.Errorf("%v -> %v", a, b)
+		}
+	}
+}
@@ -0,0 +1,411 @@
+package icc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// curve は0〜1の値を0〜1の値に写す1次元の変換
+type curve func(x float64) float64
+
+func identity(x float64) float64 { return x }
+
+func clamp(x float64) float64 {
+	return math.Max(0, math.Min(1, x))
+}
+
+// readCurve はcurveTypeかparametricCurveTypeのデータからカーブを読み込み、
+// 4バイト境界に揃えたデータのバイト数とともに返す。
+func readCurve(t tag) (curve, int, error) {
+	switch t.typeSignature() {
+	case "curv":
+		if len(t) < 12 {
+			return nil, 0, errTruncated
+		}
+		n := int(binary.BigEndian.Uint32(t[8:12]))
+		if n > (len(t)-12)/2 {
+			return nil, 0, errTruncated
+		}
+		size := align4(12 + 2*n)
+		switch n {
+		case 0:
+			return identity, size, nil
+		case 1:
+			// u8Fixed8Numberのガンマ値
+			gamma := float64(binary.BigEndian.Uint16(t[12:])) / 256
+			return func(x float64) float64 { return math.Pow(clamp(x), gamma) }, size, nil
+		}
+		table := make([]float64, n)
+		for i := range table {
+			table[i] = float64(binary.BigEndian.Uint16(t[12+2*i:])) / 0xffff
+		}
+		return tableCurve(table), size, nil
+
+	case "para":
+		if len(t) < 12 {
+			return nil, 0, errTruncated
+		}
+		function := binary.BigEndian.Uint16(t[8:10])
+		counts := []int{1, 3, 4, 5, 7}
+		if int(function) >= len(counts) {
+			return nil, 0, fmt.Errorf("icc: unknown parametric curve type %d", function)
+		}
+		n := counts[function]
+		if len(t) < 12+4*n {
+			return nil, 0, errTruncated
+		}
+		// g, a, b, c, d, e, f の順。使わない値は0のまま
+		var v [7]float64
+		for i := 0; i < n; i++ {
+			v[i] = s15Fixed16(t[12+4*i:])
+		}
+		g, a, b, c, d, e, f := v[0], v[1], v[2], v[3], v[4], v[5], v[6]
+		var fn curve
+		switch function {
+		case 0:
+			fn = func(x float64) float64 { return math.Pow(x, g) }
+		case 1:
+			fn = func(x float64) float64 {
+				if a*x+b < 0 {
+					return 0
+				}
+				return math.Pow(a*x+b, g)
+			}
+		case 2:
+			fn = func(x float64) float64 {
+				if a*x+b < 0 {
+					return c
+				}
+				return math.Pow(a*x+b, g) + c
+			}
+		case 3:
+			fn = func(x float64) float64 {
+				if x < d || a*x+b < 0 {
+					return c * x
+				}
+				return math.Pow(a*x+b, g)
+			}
+		case 4:
+			fn = func(x float64) float64 {
+				if x < d || a*x+b < 0 {
+					return c*x + f
+				}
+				return math.Pow(a*x+b, g) + e
+			}
+		}
+		return func(x float64) float64 { return clamp(fn(clamp(x))) }, align4(12 + 4*n), nil
+	}
+	return nil, 0, fmt.Errorf("icc: unsupported curve type %q", t.typeSignature())
+}
+
+// tableCurve は等間隔に並んだ値の表を線形補間するカーブを返す。
+func tableCurve(table []float64) curve {
+	last := len(table) - 1
+	return func(x float64) float64 {
+		pos := clamp(x) * float64(last)
+		i := int(pos)
+		if i >= last {
+			return table[last]
+		}
+		frac := pos - float64(i)
+		return table[i] + (table[i+1]-table[i])*frac
+	}
+}
+
+func align4(n int) int {
+	return (n + 3) &^ 3
+}
+
+// clut は多次元の色変換テーブル
+type clut struct {
+	grid    []int
+	outputs int
+	// data は最後の入力チャネルが最も速く変わる順に並んだ出力値(0〜1)
+	data []float64
+}
+
+// lookup はinの位置の値を周囲の格子点から多重線形補間して返す。
+func (c *clut) lookup(in []float64) []float64 {
+	n := len(c.grid)
+	base := 0
+	stride := c.outputs
+	strides := make([]int, n)
+	fracs := make([]float64, n)
+	for i := n - 1; i >= 0; i-- {
+		last := c.grid[i] - 1
+		pos := clamp(in[i]) * float64(last)
+		index := int(pos)
+		if index >= last {
+			index = last
+		}
+		fracs[i] = pos - float64(index)
+		strides[i] = stride
+		base += index * stride
+		stride *= c.grid[i]
+	}
+
+	out := make([]float64, c.outputs)
+	for corner := 0; corner < 1<<uint(n); corner++ {
+		weight := 1.0
+		offset := base
+		for i := 0; i < n; i++ {
+			if corner&(1<<uint(i)) == 0 {
+				weight *= 1 - fracs[i]
+			} else if fracs[i] > 0 {
+				weight *= fracs[i]
+				offset += strides[i]
+			} else {
+				weight = 0
+			}
+		}
+		if weight == 0 {
+			continue
+		}
+		for j := range out {
+			out[j] += weight * c.data[offset+j]
+		}
+	}
+	return out
+}
+
+// lut はLUT形式のタグの変換。Aカーブ、CLUT、Mカーブ、マトリクス、Bカーブの順に適用し、
+// 省略された要素はnilにする。
+// lut8Typeとlut16Typeは入力テーブルをA、出力テーブルをBとして扱う。
+type lut struct {
+	a      []curve
+	clut   *clut
+	m      []curve
+	matrix *[12]float64
+	b      []curve
+
+	// legacyLab が真の場合、PCSのLabを16ビットの旧エンコーディング(0xff00がL*=100)で解釈する
+	legacyLab bool
+	pcs       string
+}
+
+// readLUT はA2B0タグのlut8Type、lut16Type、lutAtoBTypeのデータを読み込む。
+func readLUT(t tag, pcs string) (*lut, error) {
+	if len(t) < 12 {
+		return nil, errTruncated
+	}
+	inputs, outputs := int(t[8]), int(t[9])
+	if inputs < 1 || inputs > 4 || outputs != 3 {
+		return nil, fmt.Errorf("icc: unsupported LUT with %d inputs and %d outputs", inputs, outputs)
+	}
+	l := &lut{pcs: pcs}
+
+	switch t.typeSignature() {
+	case "mft1", "mft2":
+		if len(t) < 48 {
+			return nil, errTruncated
+		}
+		grid := int(t[10])
+		if grid < 2 {
+			return nil, fmt.Errorf("icc: invalid CLUT grid size %d", grid)
+		}
+		// 3x3のマトリクスは入力がXYZの場合にだけ使うため無視する
+		offset, inEntries, outEntries, size := 48, 256, 256, 1
+		if t.typeSignature() == "mft2" {
+			if len(t) < 52 {
+				return nil, errTruncated
+			}
+			inEntries = int(binary.BigEndian.Uint16(t[48:]))
+			outEntries = int(binary.BigEndian.Uint16(t[50:]))
+			offset, size = 52, 2
+			l.legacyLab = true
+			if inEntries < 2 || outEntries < 2 {
+				return nil, fmt.Errorf("icc: invalid lut16 table size")
+			}
+		}
+		read := func(n int) ([]float64, error) {
+			if n > (len(t)-offset)/size {
+				return nil, errTruncated
+			}
+			values := make([]float64, n)
+			for i := range values {
+				if size == 1 {
+					values[i] = float64(t[offset+i]) / 0xff
+				} else {
+					values[i] = float64(binary.BigEndian.Uint16(t[offset+2*i:])) / 0xffff
+				}
+			}
+			offset += n * size
+			return values, nil
+		}
+		readTables := func(channels, entries int) ([]curve, error) {
+			curves := make([]curve, channels)
+			for i := range curves {
+				table, err := read(entries)
+				if err != nil {
+					return nil, err
+				}
+				curves[i] = tableCurve(table)
+			}
+			return curves, nil
+		}
+
+		var err error
+		if l.a, err = readTables(inputs, inEntries); err != nil {
+			return nil, err
+		}
+		c := &clut{grid: make([]int, inputs), outputs: outputs}
+		points := outputs
+		for i := range c.grid {
+			c.grid[i] = grid
+			points *= grid
+		}
+		if c.data, err = read(points); err != nil {
+			return nil, err
+		}
+		l.clut = c
+		if l.b, err = readTables(outputs, outEntries); err != nil {
+			return nil, err
+		}
+		return l, nil
+
+	case "mAB ":
+		if len(t) < 32 {
+			return nil, errTruncated
+		}
+		offsets := [5]uint32{}
+		for i := range offsets {
+			offsets[i] = binary.BigEndian.Uint32(t[12+4*i:])
+		}
+		for _, o := range offsets {
+			if o >= uint32(len(t)) {
+				return nil, errTruncated
+			}
+		}
+		bOffset, matrixOffset, mOffset, clutOffset, aOffset := offsets[0], offsets[1], offsets[2], offsets[3], offsets[4]
+
+		var err error
+		if bOffset == 0 {
+			return nil, fmt.Errorf("icc: lutAtoBType without B curves")
+		}
+		if l.b, err = readCurves(t[bOffset:], outputs); err != nil {
+			return nil, err
+		}
+		if mOffset != 0 && matrixOffset != 0 {
+			if l.m, err = readCurves(t[mOffset:], outputs); err != nil {
+				return nil, err
+			}
+			if len(t)-int(matrixOffset) < 48 {
+				return nil, errTruncated
+			}
+			l.matrix = new([12]float64)
+			for i := range l.matrix {
+				l.matrix[i] = s15Fixed16(t[int(matrixOffset)+4*i:])
+			}
+		}
+		if aOffset != 0 && clutOffset != 0 {
+			if l.a, err = readCurves(t[aOffset:], inputs); err != nil {
+				return nil, err
+			}
+			if l.clut, err = readCLUT(t[clutOffset:], inputs, outputs); err != nil {
+				return nil, err
+			}
+		} else if inputs != outputs {
+			return nil, fmt.Errorf("icc: lutAtoBType without CLUT has %d inputs", inputs)
+		}
+		if l.a == nil {
+			// Aカーブがない場合も入力チャネル数はlen(l.a)で判定する
+			l.a = make([]curve, inputs)
+			for i := range l.a {
+				l.a[i] = identity
+			}
+		}
+		return l, nil
+	}
+	return nil, fmt.Errorf("icc: unsupported A2B0 type %q", t.typeSignature())
+}
+
+// readCurves はlutAtoBTypeの中に連続して並んだn個のカーブを読み込む。
+func readCurves(t tag, n int) ([]curve, error) {
+	curves := make([]curve, n)
+	for i := range curves {
+		c, size, err := readCurve(t)
+		if err != nil {
+			return nil, err
+		}
+		if size > len(t) {
+			size = len(t)
+		}
+		curves[i] = c
+		t = t[size:]
+	}
+	return curves, nil
+}
+
+// readCLUT はlutAtoBTypeのCLUTを読み込む。
+func readCLUT(t tag, inputs, outputs int) (*clut, error) {
+	if len(t) < 20 {
+		return nil, errTruncated
+	}
+	c := &clut{grid: make([]int, inputs), outputs: outputs}
+	points := outputs
+	for i := range c.grid {
+		c.grid[i] = int(t[i])
+		if c.grid[i] < 2 {
+			return nil, fmt.Errorf("icc: invalid CLUT grid size %d", c.grid[i])
+		}
+		points *= c.grid[i]
+	}
+	precision := int(t[16])
+	if precision != 1 && precision != 2 {
+		return nil, fmt.Errorf("icc: invalid CLUT precision %d", precision)
+	}
+	if points > (len(t)-20)/precision {
+		return nil, errTruncated
+	}
+	c.data = make([]float64, points)
+	for i := range c.data {
+		if precision == 1 {
+			c.data[i] = float64(t[20+i]) / 0xff
+		} else {
+			c.data[i] = float64(binary.BigEndian.Uint16(t[20+2*i:])) / 0xffff
+		}
+	}
+	return c, nil
+}
+
+func (l *lut) xyz(in []float64) [3]float64 {
+	values := make([]float64, len(in))
+	for i, v := range in {
+		values[i] = l.a[i](v)
+	}
+	if l.clut != nil {
+		values = l.clut.lookup(values)
+	}
+	var v [3]float64
+	copy(v[:], values)
+	if l.matrix != nil {
+		for i := range v {
+			v[i] = l.m[i](v[i])
+		}
+		m := l.matrix
+		v = [3]float64{
+			clamp(m[0]*v[0] + m[1]*v[1] + m[2]*v[2] + m[9]),
+			clamp(m[3]*v[0] + m[4]*v[1] + m[5]*v[2] + m[10]),
+			clamp(m[6]*v[0] + m[7]*v[1] + m[8]*v[2] + m[11]),
+		}
+	}
+	for i := range v {
+		v[i] = l.b[i](v[i])
+	}
+	return l.decodePCS(v)
+}
+
+// decodePCS は0〜1に正規化されたPCSの値をXYZにする。
+func (l *lut) decodePCS(v [3]float64) [3]float64 {
+	if l.pcs == SpaceXYZ {
+		// u1Fixed15Number: 0xffffが1+32767/32768
+		scale := 65535.0 / 32768
+		return [3]float64{v[0] * scale, v[1] * scale, v[2] * scale}
+	}
+	scale := 1.0
+	if l.legacyLab {
+		scale = 65535.0 / 65280
+	}
+	return labToXYZ([3]float64{v[0] * scale * 100, v[1]*scale*255 - 128, v[2]*scale*255 - 128})
+}
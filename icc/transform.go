@@ -0,0 +1,97 @@
+package icc
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/kouheiszk/png-reader/internal/imageutil"
+)
+
+// d50 はPCSの白色点
+var d50 = [3]float64{0.9642, 1.0, 0.8249}
+
+// xyzToSRGB はD50のXYZをBradford変換で順応させた線形sRGBに変換するマトリクス
+var xyzToSRGB = [3][3]float64{
+	{3.1338561, -1.6168667, -0.4906146},
+	{-0.9787684, 1.9161415, 0.0334540},
+	{0.0719453, -0.2289914, 1.4052427},
+}
+
+// labToXYZ はD50のCIELABをXYZに変換する。
+func labToXYZ(lab [3]float64) [3]float64 {
+	fy := (lab[0] + 16) / 116
+	fx := fy + lab[1]/500
+	fz := fy - lab[2]/200
+	inverse := func(t float64) float64 {
+		if t > 6.0/29 {
+			return t * t * t
+		}
+		return 3 * (6.0 / 29) * (6.0 / 29) * (t - 4.0/29)
+	}
+	return [3]float64{d50[0] * inverse(fx), d50[1] * inverse(fy), d50[2] * inverse(fz)}
+}
+
+// encodeSRGB は線形の値にsRGBのトーンカーブを適用する。
+func encodeSRGB(v float64) float64 {
+	v = clamp(v)
+	if v <= 0.0031308 {
+		return 12.92 * v
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+// sRGB はデバイスの値(0〜1)をsRGBの値(0〜1)に変換する。
+func (p *Profile) sRGB(in []float64) [3]float64 {
+	linear := multiply(&xyzToSRGB, p.toPCS.xyz(in))
+	return [3]float64{encodeSRGB(linear[0]), encodeSRGB(linear[1]), encodeSRGB(linear[2])}
+}
+
+// Transform はimgの各ピクセルをプロファイルの色空間からsRGBに変換した画像を返す。
+// 透明度は変えない。グレーのプロファイルではRの値をグレーの値として扱う。
+// imgが16ビットの場合は*image.NRGBA64を、それ以外の場合は*image.NRGBAを返す。
+func (p *Profile) Transform(img image.Image) image.Image {
+	in := make([]float64, 3)
+	if p.ColorSpace == SpaceGray {
+		in = in[:1]
+	}
+	convert := func(r, g, b, max float64) [3]float64 {
+		copy(in, []float64{r / max, g / max, b / max})
+		v := p.sRGB(in)
+		for i := range v {
+			v[i] = math.Round(v[i] * max)
+		}
+		return v
+	}
+
+	b := img.Bounds()
+	if imageutil.Is16Bit(img) {
+		dst := image.NewNRGBA64(b)
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				c := imageutil.NRGBA64(img.At(x, y))
+				v := convert(float64(c.R), float64(c.G), float64(c.B), 0xffff)
+				dst.SetNRGBA64(x, y, color.NRGBA64{uint16(v[0]), uint16(v[1]), uint16(v[2]), c.A})
+			}
+		}
+		return dst
+	}
+
+	// 8ビットの画像は同じ色が繰り返し現れることが多いので、変換結果を覚えておく
+	dst := image.NewNRGBA(b)
+	cache := make(map[[3]uint8][3]uint8)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			key := [3]uint8{c.R, c.G, c.B}
+			out, ok := cache[key]
+			if !ok {
+				v := convert(float64(c.R), float64(c.G), float64(c.B), 0xff)
+				out = [3]uint8{uint8(v[0]), uint8(v[1]), uint8(v[2])}
+				cache[key] = out
+			}
+			dst.SetNRGBA(x, y, color.NRGBA{out[0], out[1], out[2], c.A})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,115 @@
+package pngreader
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"io/ioutil"
+
+	"github.com/kouheiszk/png-reader/icc"
+	"github.com/kouheiszk/png-reader/internal/imageutil"
+)
+
+// defaultICCProfileName はEncoder.ICCProfileNameが空の場合のiCCPのキーワード
+const defaultICCProfileName = "ICC profile"
+
+// iccColorSpace はカラータイプに対応するICCプロファイルの色空間を返す。
+// グレースケールの画像にはグレー、それ以外にはRGBのプロファイルしか使えない(PNG仕様 11.3.3.3)。
+func iccColorSpace(colorType int) string {
+	if colorType == 0 || colorType == 4 {
+		return icc.SpaceGray
+	}
+	return icc.SpaceRGB
+}
+
+// parseiCCP はプロファイル名と展開したプロファイルを保持する。
+// ApplyICCの場合はプロファイルを解釈し、解釈できなければ警告して変換しない。
+func (d *decoder) parseiCCP(c *chunk) error {
+	i := bytes.IndexByte(c.data, 0)
+	if i < 0 || i+2 > len(c.data) {
+		return d.problem(checkICCP, "bad iCCP chunk")
+	}
+	name := c.data[:i]
+	if !validKeyword(name) {
+		return d.problem(checkICCP, "invalid iCCP profile name %q", name)
+	}
+	if method := c.data[i+1]; method != 0 {
+		return d.problem(checkICCP, "unknown iCCP compression method %d", method)
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(c.data[i+2:]))
+	if err != nil {
+		return d.problemError(checkICCP, fmt.Errorf("bad iCCP profile: %w", err))
+	}
+	defer zr.Close()
+	max := d.Limits.iccProfileLimit()
+	profile, err := ioutil.ReadAll(io.LimitReader(zr, int64(max)+1))
+	if err != nil {
+		return d.problemError(checkICCP, fmt.Errorf("bad iCCP profile: %w", err))
+	}
+	if len(profile) > max {
+		return d.limit("ICC profile exceeds limit of %d bytes", max)
+	}
+	if len(profile) < 20 {
+		return d.problem(checkICCP, "ICC profile too short")
+	}
+	if space, want := string(profile[16:20]), iccColorSpace(d.colorType); space != want {
+		return d.problem(checkICCP, "ICC profile color space %q not allowed for color type %d", space, d.colorType)
+	}
+	d.iccName, d.iccProfile = string(name), profile
+
+	if d.ApplyICC {
+		p, err := icc.Parse(profile)
+		if err != nil {
+			return d.warn("cannot apply ICC profile %q: %v", name, err)
+		}
+		d.profile = p
+	}
+	return nil
+}
+
+// toSRGB はApplyICCでプロファイルを解釈できた場合に、cをsRGBの色に変換する。
+func (d *decoder) toSRGB(c color.NRGBA64) color.NRGBA64 {
+	if d.profile == nil {
+		return c
+	}
+	m := image.NewNRGBA64(image.Rect(0, 0, 1, 1))
+	m.SetNRGBA64(0, 0, c)
+	return imageutil.NRGBA64(d.profile.Transform(m).At(0, 0))
+}
+
+// iccp はEncoder.ICCProfileを埋め込むiCCPチャンクのデータを返す。
+func (e *encoder) iccp() ([]byte, error) {
+	name := e.ICCProfileName
+	if name == "" {
+		name = defaultICCProfileName
+	}
+	if !validKeyword([]byte(name)) {
+		return nil, fmt.Errorf("invalid ICC profile name %q", name)
+	}
+	profile := e.ICCProfile
+	if len(profile) < 128 || string(profile[36:40]) != "acsp" {
+		return nil, fmt.Errorf("invalid ICC profile")
+	}
+	if space, want := string(profile[16:20]), iccColorSpace(int(e.colorType)); space != want {
+		return nil, fmt.Errorf("ICC profile color space %q not allowed for color type %d", space, e.colorType)
+	}
+
+	var data bytes.Buffer
+	data.WriteString(name)
+	data.Write([]byte{0, 0})
+	zw, err := zlib.NewWriterLevel(&data, e.CompressionLevel.zlibLevel())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(profile); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return data.Bytes(), nil
+}
@@ -0,0 +1,55 @@
+package pngreader
+
+import "io"
+
+// idatReader は複数のIDATチャンクのデータを1つのストリームとして読み込み、
+// 最後に読んだバイトがどのチャンクのどの位置かを追跡する。
+// ReadByteを実装しているため、compress/flateは先読みをせず、位置は正確になる。
+type idatReader struct {
+	chunks []*chunk
+	i      int // 読み込み中のチャンク
+	pos    int // チャンク内の位置
+}
+
+func (r *idatReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		b, err := r.ReadByte()
+		if err != nil {
+			if n > 0 {
+				return n, nil
+			}
+			return 0, err
+		}
+		p[n] = b
+		n++
+	}
+	return n, nil
+}
+
+func (r *idatReader) ReadByte() (byte, error) {
+	for r.i < len(r.chunks) && r.pos >= len(r.chunks[r.i].data) {
+		r.i++
+		r.pos = 0
+	}
+	if r.i >= len(r.chunks) {
+		return 0, io.EOF
+	}
+	b := r.chunks[r.i].data[r.pos]
+	r.pos++
+	return b, nil
+}
+
+// location は最後に読んだバイトの位置を返す。
+func (r *idatReader) location() location {
+	i, pos := r.i, r.pos
+	if i >= len(r.chunks) {
+		i = len(r.chunks) - 1
+		pos = len(r.chunks[i].data)
+	}
+	c := r.chunks[i]
+	if pos > 0 {
+		pos--
+	}
+	return location{chunkType: c.chunkType, chunkIndex: c.index, offset: c.offset + 8 + int64(pos)}
+}
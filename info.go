@@ -0,0 +1,76 @@
+package pngreader
+
+import (
+	"image/color"
+	"io"
+)
+
+// Info はPNGファイルのヘッダとチャンク構成
+type Info struct {
+	Width       int         `json:"width"`
+	Height      int         `json:"height"`
+	ColorType   ColorType   `json:"colorType"`
+	BitDepth    int         `json:"bitDepth"`
+	Interlace   bool        `json:"interlace"`
+	PaletteSize int         `json:"paletteSize,omitempty"`
+	Chunks      []ChunkInfo `json:"chunks"`
+
+	// Background はbKGDで指定された背景色。bKGDがない場合はnil
+	Background *color.NRGBA64 `json:"background,omitempty"`
+
+	// ICCProfileName はiCCPのプロファイル名、ICCProfile は展開したプロファイル。
+	// iCCPがない場合は空。ICCProfileはEncoder.ICCProfileに渡して埋め込み直せる。
+	ICCProfileName string `json:"iccProfileName,omitempty"`
+	ICCProfile     []byte `json:"-"`
+
+	// Orientation はeXIfのOrientationタグの値、EXIF はeXIfのデータ。eXIfがない場合は空。
+	// Decoder.AutoOrientで向きを直した画像には、ResetOrientation(EXIF)を埋め込む。
+	Orientation int    `json:"orientation,omitempty"`
+	EXIF        []byte `json:"-"`
+}
+
+// ChunkInfo はファイル中のチャンク1つの情報
+type ChunkInfo struct {
+	Type   string `json:"type"`
+	Offset int64  `json:"offset"`
+	Length int    `json:"length"`
+}
+
+// DecodeInfo はrのPNGファイルのヘッダとすべてのチャンクを読み込み、Infoとして返す。
+// 画像データは展開しない。
+func DecodeInfo(r io.Reader) (*Info, error) {
+	return new(Decoder).DecodeInfo(r)
+}
+
+// DecodeInfo はrのPNGファイルのヘッダとすべてのチャンクを読み込み、Infoとして返す。
+// 画像データは展開しない。
+func (d *Decoder) DecodeInfo(r io.Reader) (*Info, error) {
+	p := &decoder{Decoder: d, seen: make(map[string]int), headerOnly: true}
+	if _, err := p.parse(r); err != nil {
+		return nil, err
+	}
+	return p.info(), nil
+}
+
+// info はこれまでに読み込んだヘッダとチャンクからInfoを作成する。
+func (d *decoder) info() *Info {
+	info := &Info{
+		Width:      d.width,
+		Height:     d.height,
+		ColorType:  ColorType(d.colorType),
+		BitDepth:   d.depth,
+		Interlace:  d.interlace,
+		Chunks:     d.chunkInfos,
+		Background: d.background,
+
+		ICCProfileName: d.iccName,
+		ICCProfile:     d.iccProfile,
+
+		Orientation: d.orientation,
+		EXIF:        d.exif,
+	}
+	if d.format != nil {
+		info.PaletteSize = len(d.format.palette)
+	}
+	return info
+}
@@ -0,0 +1,63 @@
+// Package imageutil はpngreaderと出力形式のパッケージが共通で使う画像の操作をまとめる。
+package imageutil
+
+import (
+	"image"
+	"image/color"
+)
+
+// NRGBA64 はcを非乗算済みの16ビットの色に変換する。
+// color.NRGBA64Modelと違い、非乗算済みの色は乗算済みを経由せずに変換するため、
+// 半透明のピクセルでも値が丸められない。
+func NRGBA64(c color.Color) color.NRGBA64 {
+	switch c := c.(type) {
+	case color.NRGBA64:
+		return c
+	case color.NRGBA:
+		return color.NRGBA64{uint16(c.R) * 0x101, uint16(c.G) * 0x101, uint16(c.B) * 0x101, uint16(c.A) * 0x101}
+	}
+	return color.NRGBA64Model.Convert(c).(color.NRGBA64)
+}
+
+// Is16Bit はimgが1サンプル16ビットの精度を持つかどうかを返す。
+func Is16Bit(img image.Image) bool {
+	switch img.ColorModel() {
+	case color.NRGBA64Model, color.RGBA64Model, color.Gray16Model:
+		return true
+	}
+	return false
+}
+
+// Opaque はimgのすべてのピクセルが不透明かどうかを返す。
+func Opaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0xffff {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Gray はimgのすべてのピクセルのR、G、Bが等しいかどうかを返す。
+func Gray(img image.Image) bool {
+	switch img.ColorModel() {
+	case color.GrayModel, color.Gray16Model:
+		return true
+	}
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := NRGBA64(img.At(x, y))
+			if c.R != c.G || c.G != c.B {
+				return false
+			}
+		}
+	}
+	return true
+}
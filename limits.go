@@ -0,0 +1,96 @@
+package pngreader
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+)
+
+// DefaultMaxICCProfileSize はLimits.MaxICCProfileSizeが0の場合のICCプロファイルの上限
+const DefaultMaxICCProfileSize = 16 << 20
+
+// Limits はデコード時に許す資源の上限。仕様上は正しくても、サーバーで扱うには
+// 大きすぎるファイルを拒否するために使う。MaxICCProfileSize以外の0の項目は制限しない。
+type Limits struct {
+	// MaxWidth、MaxHeight は画像の最大の幅と高さ、MaxPixels は最大の画素数(幅×高さ)。
+	// 画素のメモリはIHDRの大きさで確保するので、信頼できない入力では必ず設定する
+	MaxWidth  int
+	MaxHeight int
+	MaxPixels int
+
+	// MaxChunks はファイルに含まれるチャンクの最大数
+	MaxChunks int
+	// MaxChunkSize は1つのチャンクのデータの最大バイト数
+	MaxChunkSize int
+	// MaxTextBytes はtEXt、zTXt、iTXtチャンクのデータの合計の最大バイト数
+	MaxTextBytes int
+	// MaxICCProfileSize はiCCPチャンクの展開後のICCプロファイルの最大バイト数。
+	// 0の場合はDefaultMaxICCProfileSize
+	MaxICCProfileSize int
+}
+
+// iccProfileLimit はICCプロファイルの実際の上限を返す。
+func (l Limits) iccProfileLimit() int {
+	if l.MaxICCProfileSize > 0 {
+		return l.MaxICCProfileSize
+	}
+	return DefaultMaxICCProfileSize
+}
+
+// checkDimensions はIHDRの幅と高さがLimitsを超えていないかを確認する。
+func (d *decoder) checkDimensions() error {
+	limits := d.Limits
+	if limits.MaxWidth > 0 && d.width > limits.MaxWidth {
+		return d.limit("image width %d exceeds limit %d", d.width, limits.MaxWidth)
+	}
+	if limits.MaxHeight > 0 && d.height > limits.MaxHeight {
+		return d.limit("image height %d exceeds limit %d", d.height, limits.MaxHeight)
+	}
+	if limits.MaxPixels > 0 && uint64(d.width)*uint64(d.height) > uint64(limits.MaxPixels) {
+		return d.limit("image size %dx%d exceeds limit of %d pixels", d.width, d.height, limits.MaxPixels)
+	}
+	return nil
+}
+
+// checkLimits はチャンクcがLimitsを超えていないかを確認する。
+func (d *decoder) checkLimits(c *chunk) error {
+	limits := d.Limits
+	d.chunks++
+	if limits.MaxChunks > 0 && d.chunks > limits.MaxChunks {
+		return d.limit("more than %d chunks", limits.MaxChunks)
+	}
+	if limits.MaxChunkSize > 0 && uint64(c.length) > uint64(limits.MaxChunkSize) {
+		return d.limit("%s chunk length %d exceeds limit %d", c.chunkType, c.length, limits.MaxChunkSize)
+	}
+
+	switch c.chunkType {
+	case "tEXt", "zTXt", "iTXt":
+		d.textBytes += int(c.length)
+		if limits.MaxTextBytes > 0 && d.textBytes > limits.MaxTextBytes {
+			return d.limit("text chunks exceed limit of %d bytes", limits.MaxTextBytes)
+		}
+	case "iCCP":
+		if max := limits.iccProfileLimit(); iccProfileSize(c.data, max) > max {
+			return d.limit("ICC profile exceeds limit of %d bytes", max)
+		}
+	}
+	return nil
+}
+
+// iccProfileSize はiCCPチャンクのデータからプロファイルを展開し、そのバイト数を返す。
+// 展開はmax+1バイトで打ち切る。形式が正しくない場合は展開できた分のバイト数を返す。
+func iccProfileSize(data []byte, max int) int {
+	// キーワード、ヌル文字、圧縮方式の後に圧縮されたプロファイルが続く
+	i := bytes.IndexByte(data, 0)
+	if i < 0 || i+2 > len(data) {
+		return 0
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(data[i+2:]))
+	if err != nil {
+		return 0
+	}
+	defer zr.Close()
+	n, _ := io.Copy(ioutil.Discard, io.LimitReader(zr, int64(max)+1))
+	return int(n)
+}
@@ -0,0 +1,47 @@
+package pngreader
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// TestLimitsDimensions は、IHDRの大きさが制限を超える場合に画素のメモリを確保する前に
+// ErrLimitで失敗することを確認する。
+func TestLimitsDimensions(t *testing.T) {
+	huge := testPNG(testIHDR(805306373, 5), testChunk{"IDAT", zlibBytes(nil)}, testChunk{"IEND", nil})
+	tests := []struct {
+		name   string
+		limits Limits
+	}{
+		{"MaxPixels", Limits{MaxPixels: 1 << 24}},
+		{"MaxWidth", Limits{MaxWidth: 1 << 16}},
+		{"MaxHeight", Limits{MaxHeight: 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := (&Decoder{Limits: tt.limits}).Decode(bytes.NewReader(huge))
+			if !errors.Is(err, ErrLimit) {
+				t.Fatalf("got %v, want ErrLimit", err)
+			}
+		})
+	}
+
+	small := testPNG(testIHDR(4, 4), testChunk{"IDAT", zlibBytes(make([]byte, 4*5))}, testChunk{"IEND", nil})
+	if _, err := (&Decoder{Limits: Limits{MaxPixels: 16, MaxWidth: 4, MaxHeight: 4}}).Decode(bytes.NewReader(small)); err != nil {
+		t.Fatalf("image at the limits: %v", err)
+	}
+}
+
+// TestLimitsICCProfile は、展開したICCプロファイルが上限を超える場合にErrLimitで失敗することを確認する。
+func TestLimitsICCProfile(t *testing.T) {
+	iccp := append([]byte("big\x00\x00"), zlibBytes(make([]byte, 4096))...)
+	data := testPNG(testIHDR(1, 1), testChunk{"iCCP", iccp}, testChunk{"IDAT", zlibBytes(make([]byte, 2))}, testChunk{"IEND", nil})
+	_, err := (&Decoder{Limits: Limits{MaxICCProfileSize: 1024}}).Decode(bytes.NewReader(data))
+	if !errors.Is(err, ErrLimit) {
+		t.Fatalf("got %v, want ErrLimit", err)
+	}
+	if got := (Limits{}).iccProfileLimit(); got != DefaultMaxICCProfileSize {
+		t.Errorf("default ICC profile limit is %d, want %d", got, DefaultMaxICCProfileSize)
+	}
+}
@@ -0,0 +1,224 @@
+package mng
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+
+	pngreader "github.com/kouheiszk/png-reader"
+)
+
+// JNGSignature はJNGファイルの先頭8バイト
+const JNGSignature = "\x8bJNG\r\n\x1a\n"
+
+func init() {
+	image.RegisterFormat("jng", JNGSignature, func(r io.Reader) (image.Image, error) { return DecodeJNG(r) }, DecodeJNGConfig)
+}
+
+// JHDRのカラータイプ
+const (
+	jngGray       = 8
+	jngColor      = 10
+	jngGrayAlpha  = 12
+	jngColorAlpha = 14
+)
+
+// JHDRのアルファの圧縮方式
+const (
+	alphaDeflate = 0
+	alphaJPEG    = 8
+)
+
+// jhdr はJHDRチャンクの内容
+type jhdr struct {
+	width, height  int
+	colorType      byte
+	sampleDepth    byte
+	alphaDepth     byte
+	alphaCompress  byte
+	alphaFilter    byte
+	alphaInterlace byte
+}
+
+func (h *jhdr) hasAlpha() bool {
+	return h.colorType == jngGrayAlpha || h.colorType == jngColorAlpha
+}
+
+func readJHDR(next func() (*chunk, error)) (*jhdr, error) {
+	c, err := next()
+	if err != nil {
+		return nil, err
+	}
+	if c.chunkType != "JHDR" || len(c.data) != 16 {
+		return nil, errors.New("mng: missing JHDR chunk")
+	}
+	h := &jhdr{
+		width:          int(binary.BigEndian.Uint32(c.data[0:])),
+		height:         int(binary.BigEndian.Uint32(c.data[4:])),
+		colorType:      c.data[8],
+		sampleDepth:    c.data[9],
+		alphaDepth:     c.data[12],
+		alphaCompress:  c.data[13],
+		alphaFilter:    c.data[14],
+		alphaInterlace: c.data[15],
+	}
+	if h.width <= 0 || h.height <= 0 || uint64(h.width)*uint64(h.height) > maxPixels {
+		return nil, fmt.Errorf("mng: invalid JNG dimensions %dx%d", h.width, h.height)
+	}
+	switch h.colorType {
+	case jngGray, jngColor, jngGrayAlpha, jngColorAlpha:
+	default:
+		return nil, fmt.Errorf("mng: invalid JNG color type %d", h.colorType)
+	}
+	switch h.sampleDepth {
+	case 8, 20:
+		// 20は8ビットと12ビットの両方を持つ。8ビットの方を使う
+	case 12:
+		// 12ビットのJPEGはimage/jpegで読めない
+		return nil, errors.New("mng: 12-bit JNG is not supported")
+	default:
+		return nil, fmt.Errorf("mng: invalid JNG sample depth %d", h.sampleDepth)
+	}
+	if h.hasAlpha() {
+		switch {
+		case h.alphaCompress == alphaJPEG:
+		case h.alphaCompress == alphaDeflate && h.alphaFilter == 0:
+			switch h.alphaDepth {
+			case 1, 2, 4, 8, 16:
+			default:
+				return nil, fmt.Errorf("mng: invalid JNG alpha sample depth %d", h.alphaDepth)
+			}
+		default:
+			return nil, fmt.Errorf("mng: unsupported JNG alpha compression %d with filter %d", h.alphaCompress, h.alphaFilter)
+		}
+	}
+	return h, nil
+}
+
+// DecodeJNGConfig はJHDRから画像の大きさを返す。
+func DecodeJNGConfig(r io.Reader) (image.Config, error) {
+	next, err := chunks(r, JNGSignature)
+	if err != nil {
+		return image.Config{}, err
+	}
+	h, err := readJHDR(next)
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{ColorModel: color.NRGBAModel, Width: h.width, Height: h.height}, nil
+}
+
+// DecodeJNG はrのJNGファイルを読み込む。JDATをJPEGとしてデコードし、
+// アルファチャンネルがあればIDAT(PNGのグレースケールと同じ形式)かJDAA(JPEG)から読み込んで組み合わせる。
+func DecodeJNG(r io.Reader) (*image.NRGBA, error) {
+	next, err := chunks(r, JNGSignature)
+	if err != nil {
+		return nil, err
+	}
+	h, err := readJHDR(next)
+	if err != nil {
+		return nil, err
+	}
+
+	var jdat, idat, jdaa bytes.Buffer
+	separated := false
+	for {
+		c, err := next()
+		if err != nil {
+			return nil, err
+		}
+		if c.chunkType == "IEND" {
+			break
+		}
+		switch c.chunkType {
+		case "JDAT":
+			// JSEPの後は12ビットのJPEGが続くので読まない
+			if !separated {
+				jdat.Write(c.data)
+			}
+		case "JSEP":
+			separated = true
+		case "IDAT":
+			idat.Write(c.data)
+		case "JDAA":
+			jdaa.Write(c.data)
+		default:
+			if c.chunkType[0]&0x20 == 0 {
+				return nil, fmt.Errorf("mng: unsupported critical JNG chunk %s", c.chunkType)
+			}
+		}
+	}
+
+	if jdat.Len() == 0 {
+		return nil, errors.New("mng: JNG has no JDAT chunk")
+	}
+	colorImg, err := jpeg.Decode(&jdat)
+	if err != nil {
+		return nil, fmt.Errorf("mng: decoding JDAT: %w", err)
+	}
+	if b := colorImg.Bounds(); b.Dx() != h.width || b.Dy() != h.height {
+		return nil, fmt.Errorf("mng: JDAT is %dx%d, JHDR says %dx%d", b.Dx(), b.Dy(), h.width, h.height)
+	}
+
+	var alpha image.Image
+	if h.hasAlpha() {
+		if alpha, err = h.decodeAlpha(idat.Bytes(), jdaa.Bytes()); err != nil {
+			return nil, err
+		}
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, h.width, h.height))
+	cb := colorImg.Bounds()
+	for y := 0; y < h.height; y++ {
+		for x := 0; x < h.width; x++ {
+			c := color.NRGBAModel.Convert(colorImg.At(cb.Min.X+x, cb.Min.Y+y)).(color.NRGBA)
+			if alpha != nil {
+				ab := alpha.Bounds()
+				c.A = uint8(color.Gray16Model.Convert(alpha.At(ab.Min.X+x, ab.Min.Y+y)).(color.Gray16).Y >> 8)
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img, nil
+}
+
+// decodeAlpha はアルファチャンネルをグレースケールの画像としてデコードする。
+// IDATの場合は同じ大きさのグレースケールのPNGを組み立ててpngreaderで読み込む。
+func (h *jhdr) decodeAlpha(idat, jdaa []byte) (image.Image, error) {
+	var alpha image.Image
+	var err error
+	if h.alphaCompress == alphaJPEG {
+		if len(jdaa) == 0 {
+			return nil, errors.New("mng: JNG has no JDAA chunk")
+		}
+		if alpha, err = jpeg.Decode(bytes.NewReader(jdaa)); err != nil {
+			return nil, fmt.Errorf("mng: decoding JDAA: %w", err)
+		}
+	} else {
+		if len(idat) == 0 {
+			return nil, errors.New("mng: JNG has no IDAT chunk")
+		}
+		var data bytes.Buffer
+		data.WriteString(pngSignature)
+		ihdr := make([]byte, 13)
+		binary.BigEndian.PutUint32(ihdr[0:], uint32(h.width))
+		binary.BigEndian.PutUint32(ihdr[4:], uint32(h.height))
+		ihdr[8] = h.alphaDepth
+		ihdr[12] = h.alphaInterlace
+		writeChunk(&data, "IHDR", ihdr)
+		writeChunk(&data, "IDAT", idat)
+		writeChunk(&data, "IEND", nil)
+		if alpha, err = pngreader.Decode(&data); err != nil {
+			return nil, fmt.Errorf("mng: decoding JNG alpha: %w", err)
+		}
+	}
+	if b := alpha.Bounds(); b.Dx() != h.width || b.Dy() != h.height {
+		return nil, fmt.Errorf("mng: JNG alpha is %dx%d, JHDR says %dx%d", b.Dx(), b.Dy(), h.width, h.height)
+	}
+	return alpha, nil
+}
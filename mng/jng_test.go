@@ -0,0 +1,150 @@
+package mng
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"testing"
+
+	pngreader "github.com/kouheiszk/png-reader"
+)
+
+// testJNG は8x8の赤のJPEGに、左上から順に0、4、8…と増えるアルファを組み合わせたJNGを返す。
+// alphaCompressがalphaDeflateならアルファをIDAT、alphaJPEGならJDAAに入れる。
+// colorTypeがjngColorならアルファを付けない。
+func testJNG(t *testing.T, colorType, alphaCompress byte) []byte {
+	t.Helper()
+	red := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for i := 0; i < len(red.Pix); i += 4 {
+		red.Pix[i], red.Pix[i+3] = 200, 0xff
+	}
+	var jdat bytes.Buffer
+	if err := jpeg.Encode(&jdat, red, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	alpha := image.NewGray(image.Rect(0, 0, 8, 8))
+	for i := range alpha.Pix {
+		alpha.Pix[i] = uint8(i * 4)
+	}
+
+	var b bytes.Buffer
+	b.WriteString(JNGSignature)
+	jhdr := make([]byte, 16)
+	binary.BigEndian.PutUint32(jhdr[0:], 8)
+	binary.BigEndian.PutUint32(jhdr[4:], 8)
+	jhdr[8], jhdr[9], jhdr[10], jhdr[12], jhdr[13] = colorType, 8, 8, 8, alphaCompress
+	writeChunk(&b, "JHDR", jhdr)
+	writeChunk(&b, "JDAT", jdat.Bytes())
+	switch {
+	case colorType == jngColor:
+	case alphaCompress == alphaJPEG:
+		var jdaa bytes.Buffer
+		if err := jpeg.Encode(&jdaa, alpha, &jpeg.Options{Quality: 100}); err != nil {
+			t.Fatal(err)
+		}
+		writeChunk(&b, "JDAA", jdaa.Bytes())
+	default:
+		var p bytes.Buffer
+		if err := (&pngreader.Encoder{ColorType: pngreader.Grayscale, BitDepth: 8}).Encode(&p, alpha); err != nil {
+			t.Fatal(err)
+		}
+		next, err := chunks(&p, pngSignature)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for {
+			c, err := next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.chunkType == "IEND" {
+				break
+			}
+			if c.chunkType == "IDAT" {
+				b.Write(c.raw)
+			}
+		}
+	}
+	writeChunk(&b, "IEND", nil)
+	return b.Bytes()
+}
+
+func TestDecodeJNG(t *testing.T) {
+	tests := []struct {
+		name                     string
+		colorType, alphaCompress byte
+		wantAlpha                uint8
+	}{
+		{"IDAT alpha", jngColorAlpha, alphaDeflate, 44},
+		{"JDAA alpha", jngColorAlpha, alphaJPEG, 44},
+		{"opaque", jngColor, 0, 0xff},
+	}
+	for _, tt := range tests {
+		img, format, err := image.Decode(bytes.NewReader(testJNG(t, tt.colorType, tt.alphaCompress)))
+		if err != nil || format != "jng" {
+			t.Fatalf("%s: %q, %v", tt.name, format, err)
+		}
+		// (3, 1)は11番目の画素でアルファは44。JPEGの誤差を見込んで比べる
+		c := img.(*image.NRGBA).NRGBAAt(3, 1)
+		if c.R < 190 || c.G > 10 || c.B > 10 || absDiff(c.A, tt.wantAlpha) > 2 {
+			t.Errorf("%s: (3, 1) = %v, want red with alpha %d", tt.name, c, tt.wantAlpha)
+		}
+	}
+}
+
+// TestJNGInMNG はMNGに埋め込まれたJNGもフレームになることを確認する。
+func TestJNGInMNG(t *testing.T) {
+	var b bytes.Buffer
+	b.WriteString(Signature)
+	writeMHDR(&b, 8, 8, 0)
+	b.Write(testJNG(t, jngColorAlpha, alphaDeflate)[len(JNGSignature):])
+	writeChunk(&b, "MEND", nil)
+	m, err := Decode(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Frames) != 1 || !bytes.HasPrefix(m.Images[0].Data, []byte(JNGSignature)) {
+		t.Fatalf("%d frames", len(m.Frames))
+	}
+	// 背景は透明なので、アルファが0の左上は透明のまま
+	if c := m.Frames[0].Image.NRGBAAt(0, 0); c.A != 0 {
+		t.Errorf("(0, 0) = %v, want transparent", c)
+	}
+}
+
+func TestDecodeJNGErrors(t *testing.T) {
+	data := testJNG(t, jngColorAlpha, alphaDeflate)
+	var noJDAT bytes.Buffer
+	noJDAT.Write(data[:8+8+16+4])
+	writeChunk(&noJDAT, "IEND", nil)
+
+	tests := map[string][]byte{
+		"signature": append([]byte(Signature), data[8:]...),
+		"12-bit":    withJHDR(data, 9, 12),
+		"no JDAT":   noJDAT.Bytes(),
+		"no alpha":  withJHDR(testJNG(t, jngColor, 0), 8, jngColorAlpha),
+	}
+	for name, input := range tests {
+		if _, err := DecodeJNG(bytes.NewReader(input)); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+// withJHDR はdataのJHDRのi番目のバイトをvに変え、CRCを計算し直したコピーを返す。
+func withJHDR(data []byte, i int, v byte) []byte {
+	out := append([]byte(nil), data...)
+	jhdr := out[len(JNGSignature):]
+	jhdr[8+i] = v
+	binary.BigEndian.PutUint32(jhdr[8+16:], crc32.ChecksumIEEE(jhdr[4:8+16]))
+	return out
+}
+
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
@@ -0,0 +1,362 @@
+// Package mng はMNG(Multiple-image Network Graphics)を読み込む。
+// MHDRからMENDまでのチャンク列を解析し、埋め込まれたPNGとJNGのデータストリームを
+// デコードして、DEFIの位置、BACKの背景色、FRAMの表示方法と
+// フレーム間の遅延に従ってフレームを合成する。MNG-LC程度の単純なファイルを対象とし、
+// LOOPなどの繰り返しやオブジェクトの操作、デルタPNGには対応しない。
+//
+// JNG(JPEG Network Graphics)は単独のファイルも読み込める。
+//
+// パッケージを読み込むとimage.DecodeでMNGの最初のフレームとJNGを扱えるようになる。
+package mng
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"time"
+
+	pngreader "github.com/kouheiszk/png-reader"
+)
+
+// Signature はMNGファイルの先頭8バイト
+const Signature = "\x8aMNG\r\n\x1a\n"
+
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+var signatureNames = map[string]string{Signature: "an MNG", JNGSignature: "a JNG"}
+
+func init() {
+	image.RegisterFormat("mng", Signature, decodeFirst, DecodeConfig)
+}
+
+// maxPixels はフレームの最大ピクセル数。MHDRだけで巨大なキャンバスを確保させないようにする。
+const maxPixels = 1 << 28
+
+// Image は埋め込まれたPNGかJNGのデータストリーム1つ
+type Image struct {
+	// Data はシグネチャを付けた単独のPNGまたはJNGファイルとしてのデータ
+	Data []byte
+	// X、Y はDEFIで指定されたフレーム上の位置
+	X, Y int
+	// Image はDataをデコードした画像
+	Image image.Image
+}
+
+// Frame は合成した1枚のフレーム
+type Frame struct {
+	Image *image.NRGBA
+	// Delay は次のフレームまでの時間
+	Delay time.Duration
+}
+
+// MNG は読み込んだMNGファイル
+type MNG struct {
+	Width, Height  int
+	TicksPerSecond int
+	// Background はBACKの背景色。BACKがない場合は透明
+	Background color.NRGBA64
+
+	Images []*Image
+	Frames []*Frame
+}
+
+type chunk struct {
+	chunkType string
+	data      []byte
+	raw       []byte // 長さ、タイプ、データ、CRCを含むチャンク全体
+}
+
+// chunks はrの先頭がsignatureであることを確認し、チャンクを1つずつ返す関数を返す。
+func chunks(r io.Reader, signature string) (func() (*chunk, error), error) {
+	header := make([]byte, len(signature))
+	if _, err := io.ReadFull(r, header); err != nil || string(header) != signature {
+		return nil, fmt.Errorf("mng: not %s file", signatureNames[signature])
+	}
+	return func() (*chunk, error) {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, fmt.Errorf("mng: reading chunk: %w", err)
+		}
+		length := binary.BigEndian.Uint32(header[:4])
+		if length > 0x7fffffff {
+			return nil, fmt.Errorf("mng: invalid chunk length %d", length)
+		}
+		// 宣言された長さの分を先に確保せず、データが届いた分だけ広げる
+		var buf bytes.Buffer
+		buf.Write(header[:])
+		if _, err := io.CopyN(&buf, r, int64(length)+4); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, fmt.Errorf("mng: reading %s chunk: %w", header[4:8], err)
+		}
+		raw := buf.Bytes()
+		c := &chunk{chunkType: string(header[4:8]), data: raw[8 : 8+length], raw: raw}
+		if crc32.ChecksumIEEE(raw[4:8+length]) != binary.BigEndian.Uint32(raw[8+length:]) {
+			return nil, fmt.Errorf("mng: %s chunk CRC mismatch", c.chunkType)
+		}
+		return c, nil
+	}, nil
+}
+
+// readMHDR はMHDRを読み込む。
+func readMHDR(next func() (*chunk, error)) (*MNG, error) {
+	c, err := next()
+	if err != nil {
+		return nil, err
+	}
+	if c.chunkType != "MHDR" || len(c.data) < 12 {
+		return nil, errors.New("mng: missing MHDR chunk")
+	}
+	width := binary.BigEndian.Uint32(c.data[0:])
+	height := binary.BigEndian.Uint32(c.data[4:])
+	if width == 0 || height == 0 || uint64(width)*uint64(height) > maxPixels {
+		return nil, fmt.Errorf("mng: invalid frame dimensions %dx%d", width, height)
+	}
+	return &MNG{
+		Width:          int(width),
+		Height:         int(height),
+		TicksPerSecond: int(binary.BigEndian.Uint32(c.data[8:])),
+	}, nil
+}
+
+// DecodeConfig はMHDRからフレームの大きさを返す。
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	next, err := chunks(r, Signature)
+	if err != nil {
+		return image.Config{}, err
+	}
+	m, err := readMHDR(next)
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{ColorModel: color.NRGBAModel, Width: m.Width, Height: m.Height}, nil
+}
+
+func decodeFirst(r io.Reader) (image.Image, error) {
+	m, err := Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(m.Frames) == 0 {
+		return nil, errors.New("mng: no frames")
+	}
+	return m.Frames[0].Image, nil
+}
+
+// Decode はrのMNGファイルを読み込み、埋め込まれた画像と合成したフレームを返す。
+func Decode(r io.Reader) (*MNG, error) {
+	return new(decoder).decode(r)
+}
+
+// decoder は1回のデコードの状態を保持する。
+type decoder struct {
+	m *MNG
+
+	// plte、trns はグローバルなPLTEとtRNSのデータ
+	plte, trns []byte
+	// x、y は次の画像の位置
+	x, y int
+	// mode はFRAMの表示方法、delay はフレーム間の遅延(ティック)
+	mode  byte
+	delay uint32
+
+	canvas *image.NRGBA
+	// pending は表示方法2と4で、まだフレームにしていない画像があるかどうか
+	pending bool
+}
+
+func (d *decoder) decode(r io.Reader) (*MNG, error) {
+	next, err := chunks(r, Signature)
+	if err != nil {
+		return nil, err
+	}
+	if d.m, err = readMHDR(next); err != nil {
+		return nil, err
+	}
+	d.mode, d.delay = 1, 1
+	d.canvas = image.NewNRGBA(image.Rect(0, 0, d.m.Width, d.m.Height))
+
+	for {
+		c, err := next()
+		if err != nil {
+			return nil, err
+		}
+		switch c.chunkType {
+		case "MEND":
+			d.flush()
+			return d.m, nil
+		case "IHDR":
+			if err := d.readPNG(c, next); err != nil {
+				return nil, err
+			}
+		case "JHDR":
+			if err := d.readJNG(c, next); err != nil {
+				return nil, err
+			}
+		case "PLTE":
+			d.plte = c.data
+		case "tRNS":
+			d.trns = c.data
+		case "BACK":
+			if len(c.data) >= 6 {
+				d.m.Background = color.NRGBA64{
+					binary.BigEndian.Uint16(c.data[0:]),
+					binary.BigEndian.Uint16(c.data[2:]),
+					binary.BigEndian.Uint16(c.data[4:]),
+					0xffff,
+				}
+			}
+		case "DEFI":
+			d.x, d.y = 0, 0
+			if len(c.data) >= 12 {
+				d.x = int(int32(binary.BigEndian.Uint32(c.data[4:])))
+				d.y = int(int32(binary.BigEndian.Uint32(c.data[8:])))
+			}
+		case "FRAM":
+			d.readFRAM(c.data)
+		case "TERM", "LOOP", "ENDL", "SAVE", "SEEK", "nEED", "eXPI", "pHYg":
+			// 繰り返しや再生の制御は扱わない
+		default:
+			if c.chunkType[0]&0x20 == 0 {
+				return nil, fmt.Errorf("mng: unsupported critical chunk %s", c.chunkType)
+			}
+		}
+	}
+}
+
+// readFRAM はFRAMの表示方法とフレーム間の遅延を読み込む。
+// 表示方法2と4では、FRAMまでの画像をまとめて1フレームにする。
+func (d *decoder) readFRAM(data []byte) {
+	d.flush()
+	if len(data) == 0 {
+		return
+	}
+	if data[0] != 0 {
+		d.mode = data[0]
+	}
+	// 表示方法の後にヌル文字で終わる名前と、各値を変更するかどうかの4バイトが続く
+	i := bytes.IndexByte(data[1:], 0)
+	if i < 0 {
+		return
+	}
+	rest := data[1+i+1:]
+	if len(rest) >= 8 && rest[0] != 0 {
+		d.delay = binary.BigEndian.Uint32(rest[4:])
+	}
+}
+
+// readPNG はIHDRからIENDまでのチャンクを単独のPNGとしてデコードし、キャンバスに描く。
+func (d *decoder) readPNG(ihdr *chunk, next func() (*chunk, error)) error {
+	if len(ihdr.data) != 13 {
+		return fmt.Errorf("mng: embedded PNG %d: invalid IHDR length %d", len(d.m.Images)+1, len(ihdr.data))
+	}
+	var data bytes.Buffer
+	data.WriteString(pngSignature)
+	data.Write(ihdr.raw)
+	hasPLTE, hasTRNS := false, false
+	for c := ihdr; c.chunkType != "IEND"; {
+		var err error
+		if c, err = next(); err != nil {
+			return err
+		}
+		switch c.chunkType {
+		case "PLTE":
+			// 空のPLTEはグローバルなPLTEを使う
+			hasPLTE = true
+			if len(c.data) == 0 && d.plte != nil {
+				writeChunk(&data, "PLTE", d.plte)
+				continue
+			}
+		case "tRNS":
+			hasTRNS = true
+		case "IDAT":
+			if colorType := ihdr.data[9]; colorType == 3 && !hasPLTE && d.plte != nil {
+				writeChunk(&data, "PLTE", d.plte)
+				hasPLTE = true
+			}
+			if !hasTRNS && d.trns != nil && hasPLTE {
+				writeChunk(&data, "tRNS", d.trns)
+				hasTRNS = true
+			}
+		}
+		data.Write(c.raw)
+	}
+
+	img, err := pngreader.Decode(bytes.NewReader(data.Bytes()))
+	if err != nil {
+		return fmt.Errorf("mng: embedded PNG %d: %w", len(d.m.Images)+1, err)
+	}
+	d.addImage(data.Bytes(), img)
+	return nil
+}
+
+// readJNG はJHDRからIENDまでのチャンクを単独のJNGとしてデコードし、キャンバスに描く。
+func (d *decoder) readJNG(jhdr *chunk, next func() (*chunk, error)) error {
+	var data bytes.Buffer
+	data.WriteString(JNGSignature)
+	data.Write(jhdr.raw)
+	for c := jhdr; c.chunkType != "IEND"; {
+		var err error
+		if c, err = next(); err != nil {
+			return err
+		}
+		data.Write(c.raw)
+	}
+	img, err := DecodeJNG(bytes.NewReader(data.Bytes()))
+	if err != nil {
+		return fmt.Errorf("mng: embedded JNG %d: %w", len(d.m.Images)+1, err)
+	}
+	d.addImage(data.Bytes(), img)
+	return nil
+}
+
+// addImage は埋め込まれた画像を記録し、現在の位置でキャンバスに描く。
+func (d *decoder) addImage(data []byte, img image.Image) {
+	d.m.Images = append(d.m.Images, &Image{Data: data, X: d.x, Y: d.y, Image: img})
+
+	if len(d.m.Frames) == 0 && !d.pending || d.mode == 3 || d.mode == 4 && !d.pending {
+		draw.Draw(d.canvas, d.canvas.Bounds(), image.NewUniform(d.m.Background), image.Point{}, draw.Src)
+	}
+	b := img.Bounds()
+	draw.Draw(d.canvas, b.Add(image.Pt(d.x, d.y)).Sub(b.Min), img, b.Min, draw.Over)
+	d.pending = true
+	if d.mode == 1 || d.mode == 3 {
+		d.flush()
+	}
+}
+
+// flush はキャンバスに描いた画像があればフレームとして追加する。
+func (d *decoder) flush() {
+	if !d.pending {
+		return
+	}
+	frame := &Frame{Image: image.NewNRGBA(d.canvas.Rect)}
+	copy(frame.Image.Pix, d.canvas.Pix)
+	if d.m.TicksPerSecond > 0 {
+		frame.Delay = time.Duration(d.delay) * time.Second / time.Duration(d.m.TicksPerSecond)
+	}
+	d.m.Frames = append(d.m.Frames, frame)
+	d.pending = false
+}
+
+func writeChunk(w *bytes.Buffer, chunkType string, data []byte) {
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(len(data)))
+	copy(header[4:], chunkType)
+	w.Write(header[:])
+	w.Write(data)
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+	binary.Write(w, binary.BigEndian, crc.Sum32())
+}
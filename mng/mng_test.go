@@ -0,0 +1,197 @@
+package mng
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"runtime"
+	"testing"
+	"time"
+
+	pngreader "github.com/kouheiszk/png-reader"
+)
+
+// pngChunks はimgをPNGにエンコードし、シグネチャを除いたチャンクをIENDまで返す。
+// *image.Palettedは8ビットのインデックスカラーにする。
+func pngChunks(t *testing.T, img image.Image) []*chunk {
+	t.Helper()
+	e := new(pngreader.Encoder)
+	if _, ok := img.(*image.Paletted); ok {
+		e.ColorType, e.BitDepth = pngreader.Indexed, 8
+	}
+	var b bytes.Buffer
+	if err := e.Encode(&b, img); err != nil {
+		t.Fatal(err)
+	}
+	next, err := chunks(&b, pngSignature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list []*chunk
+	for {
+		c, err := next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		list = append(list, c)
+		if c.chunkType == "IEND" {
+			return list
+		}
+	}
+}
+
+// writeMHDR はwidth×heightでticksPerSecondのMHDRを書き込む。
+func writeMHDR(w *bytes.Buffer, width, height, ticksPerSecond uint32) {
+	mhdr := make([]byte, 28)
+	binary.BigEndian.PutUint32(mhdr[0:], width)
+	binary.BigEndian.PutUint32(mhdr[4:], height)
+	binary.BigEndian.PutUint32(mhdr[8:], ticksPerSecond)
+	writeChunk(w, "MHDR", mhdr)
+}
+
+func uniform(c color.Color, size int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// testMNG は赤の背景に白の2x2を左上に置くフレームと、グローバルなPLTEを使う青の2x2を
+// (2, 2)に重ねるフレームからなる4x4のMNGを返す。
+func testMNG(t *testing.T) []byte {
+	var b bytes.Buffer
+	b.WriteString(Signature)
+	writeMHDR(&b, 4, 4, 10)
+	writeChunk(&b, "BACK", []byte{0xff, 0xff, 0, 0, 0, 0})
+	// 表示方法1、名前なし、すべてのフレームの遅延を5ティックに変える
+	writeChunk(&b, "FRAM", []byte{1, 0, 2, 0, 0, 0, 0, 0, 0, 5})
+	writeChunk(&b, "PLTE", []byte{0, 0, 0xff})
+	for _, c := range pngChunks(t, uniform(color.White, 2)) {
+		b.Write(c.raw)
+	}
+	defi := make([]byte, 12)
+	binary.BigEndian.PutUint32(defi[4:], 2)
+	binary.BigEndian.PutUint32(defi[8:], 2)
+	writeChunk(&b, "DEFI", defi)
+	blue := image.NewPaletted(image.Rect(0, 0, 2, 2), color.Palette{color.NRGBA{0, 0, 0xff, 0xff}})
+	for _, c := range pngChunks(t, blue) {
+		if c.chunkType != "PLTE" {
+			b.Write(c.raw)
+		}
+	}
+	writeChunk(&b, "MEND", nil)
+	return b.Bytes()
+}
+
+func TestDecode(t *testing.T) {
+	m, err := Decode(bytes.NewReader(testMNG(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Width != 4 || m.Height != 4 || m.TicksPerSecond != 10 {
+		t.Errorf("header %dx%d at %d ticks", m.Width, m.Height, m.TicksPerSecond)
+	}
+	if m.Background != (color.NRGBA64{0xffff, 0, 0, 0xffff}) {
+		t.Errorf("background %v", m.Background)
+	}
+	if len(m.Images) != 2 || len(m.Frames) != 2 {
+		t.Fatalf("%d images and %d frames, want 2 and 2", len(m.Images), len(m.Frames))
+	}
+	if img := m.Images[1]; img.X != 2 || img.Y != 2 || !bytes.HasPrefix(img.Data, []byte(pngSignature)) {
+		t.Errorf("second image at (%d, %d)", img.X, img.Y)
+	}
+	for _, f := range m.Frames {
+		if f.Delay != 500*time.Millisecond {
+			t.Errorf("delay %v, want 500ms", f.Delay)
+		}
+	}
+
+	white, red, blue := color.NRGBA{0xff, 0xff, 0xff, 0xff}, color.NRGBA{0xff, 0, 0, 0xff}, color.NRGBA{0, 0, 0xff, 0xff}
+	tests := []struct {
+		frame, x, y int
+		want        color.NRGBA
+	}{
+		{0, 0, 0, white},
+		{0, 3, 3, red},
+		{1, 0, 0, white},
+		{1, 3, 0, red},
+		{1, 3, 3, blue},
+	}
+	for _, tt := range tests {
+		if got := m.Frames[tt.frame].Image.NRGBAAt(tt.x, tt.y); got != tt.want {
+			t.Errorf("frame %d (%d, %d) = %v, want %v", tt.frame, tt.x, tt.y, got, tt.want)
+		}
+	}
+}
+
+// TestImageDecode はimage.DecodeがMNGの最初のフレームを返すことを確認する。
+func TestImageDecode(t *testing.T) {
+	data := testMNG(t)
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || format != "mng" || config.Width != 4 || config.Height != 4 {
+		t.Fatalf("config %+v, %q, %v", config, format, err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := img.(*image.NRGBA).NRGBAAt(3, 3); c != (color.NRGBA{0xff, 0, 0, 0xff}) {
+		t.Errorf("first frame (3, 3) = %v", c)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	data := testMNG(t)
+	var critical bytes.Buffer
+	critical.Write(data[:len(data)-12])
+	writeChunk(&critical, "XXXX", nil)
+	writeChunk(&critical, "MEND", nil)
+	corrupt := append([]byte(nil), data...)
+	corrupt[len(corrupt)-1] ^= 1
+	ihdr := func(data []byte) []byte {
+		var b bytes.Buffer
+		b.WriteString(Signature)
+		writeMHDR(&b, 4, 4, 1)
+		writeChunk(&b, "IHDR", data)
+		writeChunk(&b, "IDAT", nil)
+		writeChunk(&b, "IEND", nil)
+		writeChunk(&b, "MEND", nil)
+		return b.Bytes()
+	}
+
+	tests := map[string][]byte{
+		"empty IHDR": ihdr(nil),
+		"short IHDR": ihdr([]byte{0, 0, 0, 2, 0, 0, 0, 2, 8}),
+		"signature":  append([]byte(pngSignature), data[8:]...),
+		"no MHDR":    append([]byte(Signature), data[8+12+28:]...),
+		"no MEND":    data[:len(data)-12],
+		"CRC":        corrupt,
+		"critical":   critical.Bytes(),
+	}
+	for name, input := range tests {
+		if _, err := Decode(bytes.NewReader(input)); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+// TestDecodeHugeChunk は巨大な長さを宣言しただけのチャンクで、その分のメモリを確保しないことを確認する。
+func TestDecodeHugeChunk(t *testing.T) {
+	input := append([]byte(Signature), 0x7f, 0xff, 0xff, 0xff, 'M', 'H', 'D', 'R')
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := Decode(bytes.NewReader(input))
+	runtime.ReadMemStats(&after)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("got %v, want io.ErrUnexpectedEOF", err)
+	}
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Errorf("allocated %d bytes for a 16-byte input", n)
+	}
+}
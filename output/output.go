@@ -0,0 +1,104 @@
+// Package output はpngreaderのコマンドが書き出せる画像形式の登録簿。
+//
+// 新しい形式を提供するパッケージは、init関数でRegisterを呼んで形式を登録する。
+// convertなどのサブコマンドはLookupで形式を探すため、そのパッケージを
+// ブランクインポートしたコマンドをビルドすれば、-formatや出力ファイルの拡張子で選べるようになる。
+//
+//	package avif
+//
+//	func init() {
+//		output.Register(output.Format{
+//			Name:        "avif",
+//			ContentType: "image/avif",
+//			Encoder:     output.EncoderFunc(Encode),
+//		})
+//	}
+package output
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"sync"
+)
+
+// Encoder は画像を1つの形式で書き出す。
+type Encoder interface {
+	Encode(w io.Writer, img image.Image) error
+}
+
+// EncoderFunc は関数をEncoderとして使うための型
+type EncoderFunc func(w io.Writer, img image.Image) error
+
+// Encode はf(w, img)を呼ぶ。
+func (f EncoderFunc) Encode(w io.Writer, img image.Image) error {
+	return f(w, img)
+}
+
+// SidecarEncoder は画像と別に、書き出したデータを解釈するためのメタデータを持つEncoder。
+// convertは出力ファイルに".json"を付けたファイルにSidecarが返す値をJSONで書き込む。
+type SidecarEncoder interface {
+	Encoder
+	Sidecar(img image.Image) (interface{}, error)
+}
+
+// Format は登録された形式
+type Format struct {
+	// Name は形式の正式名。Aliases は別名と拡張子(ドットなし)
+	Name    string
+	Aliases []string
+
+	// ContentType はHTTPで返すときのMIMEタイプ
+	ContentType string
+
+	Encoder Encoder
+}
+
+var (
+	mu      sync.RWMutex
+	formats []Format
+)
+
+// Register は形式を登録する。名前か別名がすでに登録されている場合、
+// またはNameやEncoderが空の場合はパニックになる。
+func Register(f Format) {
+	if f.Name == "" || f.Encoder == nil {
+		panic("output: Register with empty name or nil encoder")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, name := range append([]string{f.Name}, f.Aliases...) {
+		if _, ok := lookup(name); ok {
+			panic(fmt.Sprintf("output: Register called twice for format %q", name))
+		}
+	}
+	formats = append(formats, f)
+}
+
+// Lookup は名前か別名がnameの形式を返す。
+func Lookup(name string) (Format, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	return lookup(name)
+}
+
+func lookup(name string) (Format, bool) {
+	for _, f := range formats {
+		if f.Name == name {
+			return f, true
+		}
+		for _, alias := range f.Aliases {
+			if alias == name {
+				return f, true
+			}
+		}
+	}
+	return Format{}, false
+}
+
+// Formats は登録されたすべての形式を登録順に返す。
+func Formats() []Format {
+	mu.RLock()
+	defer mu.RUnlock()
+	return append([]Format(nil), formats...)
+}
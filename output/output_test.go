@@ -0,0 +1,48 @@
+package output
+
+import (
+	"image"
+	"io"
+	"testing"
+)
+
+var nopEncoder = EncoderFunc(func(w io.Writer, img image.Image) error { return nil })
+
+// mustPanic はfがパニックにならなければテストを失敗させる。
+func mustPanic(t *testing.T, name string, f func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Errorf("%s: no panic", name)
+		}
+	}()
+	f()
+}
+
+func TestRegister(t *testing.T) {
+	Register(Format{Name: "test-a", Aliases: []string{"ta", "tst"}, ContentType: "image/x-a", Encoder: nopEncoder})
+	Register(Format{Name: "test-b", Encoder: nopEncoder})
+
+	for _, name := range []string{"test-a", "ta", "tst"} {
+		if f, ok := Lookup(name); !ok || f.Name != "test-a" || f.ContentType != "image/x-a" {
+			t.Errorf("Lookup(%q) = %+v, %v", name, f, ok)
+		}
+	}
+	if _, ok := Lookup("missing"); ok {
+		t.Errorf("Lookup found an unregistered format")
+	}
+
+	formats := Formats()
+	if len(formats) != 2 || formats[0].Name != "test-a" || formats[1].Name != "test-b" {
+		t.Errorf("Formats() = %+v, want test-a and test-b in order", formats)
+	}
+	formats[0].Name = "changed"
+	if f, _ := Lookup("ta"); f.Name != "test-a" {
+		t.Errorf("modifying the result of Formats changed the registry")
+	}
+
+	mustPanic(t, "duplicate name", func() { Register(Format{Name: "test-b", Encoder: nopEncoder}) })
+	mustPanic(t, "duplicate alias", func() { Register(Format{Name: "test-c", Aliases: []string{"tst"}, Encoder: nopEncoder}) })
+	mustPanic(t, "empty name", func() { Register(Format{Encoder: nopEncoder}) })
+	mustPanic(t, "nil encoder", func() { Register(Format{Name: "test-d"}) })
+}
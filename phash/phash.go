@@ -0,0 +1,177 @@
+// Package phash は画像の知覚ハッシュを計算する。
+// 知覚ハッシュは縮小した輝度から作る64ビットの値で、見た目が似た画像ほど
+// ハミング距離(Distance)が小さくなる。再エンコードや縮小、軽い色調の変化では
+// ほとんど変わらないため、ほぼ同じ画像の検出に使える。
+//
+// 透明な画素は白の上に合成してから輝度を求める。
+package phash
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"math/bits"
+	"sort"
+	"strconv"
+)
+
+// Hash は64ビットの知覚ハッシュ
+type Hash uint64
+
+// String はハッシュを16桁の16進数で返す。
+func (h Hash) String() string {
+	return fmt.Sprintf("%016x", uint64(h))
+}
+
+// Parse はStringの形式の文字列をハッシュに戻す。
+func Parse(s string) (Hash, error) {
+	v, err := strconv.ParseUint(s, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("phash: invalid hash %q", s)
+	}
+	return Hash(v), nil
+}
+
+// Distance はaとbのハミング距離(0から64)を返す。
+func Distance(a, b Hash) int {
+	return bits.OnesCount64(uint64(a ^ b))
+}
+
+// Algorithm はハッシュの計算方法
+type Algorithm string
+
+const (
+	// Average は8x8に縮小し、各画素が平均より明るいかどうかを並べる(aHash)。
+	Average Algorithm = "ahash"
+	// Difference は9x8に縮小し、各画素が右隣より明るいかどうかを並べる(dHash)。
+	Difference Algorithm = "dhash"
+	// Perceptual は32x32に縮小して離散コサイン変換し、低周波の8x8の係数が
+	// 中央値より大きいかどうかを並べる(pHash)。
+	Perceptual Algorithm = "phash"
+)
+
+// Algorithms はすべての計算方法
+var Algorithms = []Algorithm{Average, Difference, Perceptual}
+
+// Compute はalgorithmでimgのハッシュを計算する。
+func Compute(img image.Image, algorithm Algorithm) (Hash, error) {
+	switch algorithm {
+	case Average:
+		return AHash(img), nil
+	case Difference:
+		return DHash(img), nil
+	case Perceptual:
+		return PHash(img), nil
+	}
+	return 0, fmt.Errorf("phash: unknown algorithm %q", algorithm)
+}
+
+// AHash はimgのaHashを返す。
+func AHash(img image.Image) Hash {
+	pix := luma(img, 8, 8)
+	var mean float64
+	for _, v := range pix {
+		mean += v
+	}
+	mean /= float64(len(pix))
+	var h Hash
+	for _, v := range pix {
+		h <<= 1
+		if v > mean {
+			h |= 1
+		}
+	}
+	return h
+}
+
+// DHash はimgのdHashを返す。
+func DHash(img image.Image) Hash {
+	pix := luma(img, 9, 8)
+	var h Hash
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			h <<= 1
+			if pix[y*9+x] > pix[y*9+x+1] {
+				h |= 1
+			}
+		}
+	}
+	return h
+}
+
+// PHash はimgのpHashを返す。
+func PHash(img image.Image) Hash {
+	const n = 32
+	pix := luma(img, n, n)
+
+	// 行ごと、列ごとに1次元のDCT-IIを計算し、左上の8x8だけを残す
+	var rows [n][8]float64
+	for y := 0; y < n; y++ {
+		for u := 0; u < 8; u++ {
+			rows[y][u] = dct(func(x int) float64 { return pix[y*n+x] }, u, n)
+		}
+	}
+	var coeffs [64]float64
+	for u := 0; u < 8; u++ {
+		for v := 0; v < 8; v++ {
+			coeffs[v*8+u] = dct(func(y int) float64 { return rows[y][u] }, v, n)
+		}
+	}
+
+	// 直流成分は画像全体の明るさなので中央値の計算から除く
+	sorted := append([]float64(nil), coeffs[1:]...)
+	sort.Float64s(sorted)
+	median := (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+
+	var h Hash
+	for _, c := range coeffs {
+		h <<= 1
+		if c > median {
+			h |= 1
+		}
+	}
+	return h
+}
+
+// dct はf(0)からf(n-1)のk番目のDCT-II係数を返す。
+func dct(f func(i int) float64, k, n int) float64 {
+	var sum float64
+	for i := 0; i < n; i++ {
+		sum += f(i) * math.Cos(math.Pi/float64(n)*(float64(i)+0.5)*float64(k))
+	}
+	return sum
+}
+
+// luma はimgを白の上に合成した輝度(0から1)を、面積平均でw×hに縮小して返す。
+// imgがw×hより小さい場合は、各画素を複数のマスで使う。
+func luma(img image.Image, w, h int) []float64 {
+	b := img.Bounds()
+	pix := make([]float64, w*h)
+	if b.Empty() {
+		return pix
+	}
+	span := func(t, n, size int) (int, int) {
+		lo := t * size / n
+		hi := (t + 1) * size / n
+		if hi <= lo {
+			hi = lo + 1
+		}
+		return lo, hi
+	}
+	for ty := 0; ty < h; ty++ {
+		y0, y1 := span(ty, h, b.Dy())
+		for tx := 0; tx < w; tx++ {
+			x0, x1 := span(tx, w, b.Dx())
+			var sum float64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					r, g, bl, a := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+					white := float64(0xffff - a)
+					sum += 0.299*(float64(r)+white) + 0.587*(float64(g)+white) + 0.114*(float64(bl)+white)
+				}
+			}
+			pix[ty*w+tx] = sum / float64((y1-y0)*(x1-x0)) / 0xffff
+		}
+	}
+	return pix
+}
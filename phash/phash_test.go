@@ -0,0 +1,136 @@
+package phash
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b Hash
+		want int
+	}{
+		{0, 0, 0},
+		{0, ^Hash(0), 64},
+		{0x0f, 0xf0, 8},
+		{0x8000000000000001, 1, 1},
+	}
+	for _, tt := range tests {
+		if got := Distance(tt.a, tt.b); got != tt.want {
+			t.Errorf("Distance(%v, %v) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	h := Hash(0x00ff00ff12345678)
+	if s := h.String(); s != "00ff00ff12345678" {
+		t.Errorf("String() = %q", s)
+	}
+	if got, err := Parse(h.String()); err != nil || got != h {
+		t.Errorf("Parse = %v, %v", got, err)
+	}
+	for _, s := range []string{"", "xyz", "11112222333344445"} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("Parse(%q): no error", s)
+		}
+	}
+}
+
+// gradient はxが増えるほど明るくなる(reverseなら暗くなる)w×hのグレースケール画像を返す。
+func gradient(w, h int, reverse bool) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := x * 255 / (w - 1)
+			if reverse {
+				v = 255 - v
+			}
+			img.Pix[y*img.Stride+x] = uint8(v)
+		}
+	}
+	return img
+}
+
+// TestKnownHashes は単純な画像のaHashとdHashが期待するビット列になることを確認する。
+func TestKnownHashes(t *testing.T) {
+	// 左半分が黒、右半分が白なら、各行の右4ビットが立つ
+	halves := image.NewGray(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 8; x < 16; x++ {
+			halves.Pix[y*halves.Stride+x] = 0xff
+		}
+	}
+	if h := AHash(halves); h != 0x0f0f0f0f0f0f0f0f {
+		t.Errorf("AHash of halves = %v", h)
+	}
+	if h := DHash(gradient(36, 16, false)); h != 0 {
+		t.Errorf("DHash of a rising gradient = %v, want 0", h)
+	}
+	if h := DHash(gradient(36, 16, true)); h != ^Hash(0) {
+		t.Errorf("DHash of a falling gradient = %v, want all ones", h)
+	}
+}
+
+// TestTransparent は透明な画素を白として扱うことを確認する。
+func TestTransparent(t *testing.T) {
+	// 左半分が透明または白で、右半分が黒の画像
+	transparent := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	white := image.NewGray(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			if x < 8 {
+				white.SetGray(x, y, color.Gray{0xff})
+			} else {
+				transparent.SetNRGBA(x, y, color.NRGBA{0, 0, 0, 0xff})
+			}
+		}
+	}
+	for _, algorithm := range Algorithms {
+		a, _ := Compute(transparent, algorithm)
+		b, _ := Compute(white, algorithm)
+		if a != b {
+			t.Errorf("%s: transparent %v, white %v", algorithm, a, b)
+		}
+	}
+	if h := AHash(transparent); h != 0xf0f0f0f0f0f0f0f0 {
+		t.Errorf("AHash = %v", h)
+	}
+}
+
+// TestSimilar は縮小した画像のハッシュが元の画像に近く、別の画像とは離れることを確認する。
+func TestSimilar(t *testing.T) {
+	pattern := func(w, h int, f func(x, y float64) float64) *image.Gray {
+		img := image.NewGray(image.Rect(0, 0, w, h))
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				v := f(float64(x)/float64(w), float64(y)/float64(h))
+				img.Pix[y*img.Stride+x] = uint8(127.5 + 127.5*v)
+			}
+		}
+		return img
+	}
+	waves := func(x, y float64) float64 { return math.Sin(7*x) * math.Cos(5*y) }
+	rings := func(x, y float64) float64 { return math.Cos(20 * math.Hypot(x-0.3, y-0.6)) }
+	original, smaller, other := pattern(256, 192, waves), pattern(64, 48, waves), pattern(256, 192, rings)
+
+	for _, algorithm := range Algorithms {
+		a, err := Compute(original, algorithm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := Compute(smaller, algorithm)
+		c, _ := Compute(other, algorithm)
+		if d := Distance(a, b); d > 4 {
+			t.Errorf("%s: resized distance %d", algorithm, d)
+		}
+		if d := Distance(a, c); d < 16 {
+			t.Errorf("%s: different image distance %d", algorithm, d)
+		}
+	}
+	if _, err := Compute(original, "md5"); err == nil {
+		t.Error("unknown algorithm accepted")
+	}
+}
@@ -0,0 +1,115 @@
+package pngreader
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// pixelFormat はスキャンラインから色情報を取り出すための情報を保持する。
+type pixelFormat struct {
+	colorType int
+	depth     int
+	palette   []color.NRGBA
+
+	// tRNSで指定された透過色(グレースケールとトゥルーカラーのみ)
+	hasTransparent bool
+	transparent    [3]uint16
+}
+
+// newImage は範囲rの出力先の画像を確保する。ビット深度16の場合はNRGBA64になる。
+func (f *pixelFormat) newImage(r image.Rectangle) image.Image {
+	if f.depth == 16 {
+		return image.NewNRGBA64(r)
+	}
+	return image.NewNRGBA(r)
+}
+
+// sample はrowのi番目のサンプルをビット深度のまま読み出す。
+// ビット深度が8未満の場合、サンプルは上位ビットから詰められている。
+func (f *pixelFormat) sample(row []byte, i int) uint16 {
+	switch f.depth {
+	case 16:
+		return binary.BigEndian.Uint16(row[2*i:])
+	case 8:
+		return uint16(row[i])
+	default:
+		bit := i * f.depth
+		shift := 8 - f.depth - bit%8
+		return uint16(row[bit/8]>>uint(shift)) & (1<<uint(f.depth) - 1)
+	}
+}
+
+// scale はビット深度のサンプルを0-255の範囲に拡大する。
+func (f *pixelFormat) scale(v uint16) uint8 {
+	switch f.depth {
+	case 16:
+		return uint8(v >> 8)
+	case 8:
+		return uint8(v)
+	default:
+		return uint8(uint32(v) * 255 / (1<<uint(f.depth) - 1))
+	}
+}
+
+// convertRow はrowのcount個のピクセルを、imgのy行目のxOffset+x*xFactorの位置に書き込む。
+// imgの範囲外のピクセルは読み飛ばす。
+func (f *pixelFormat) convertRow(img image.Image, row []byte, y, xOffset, xFactor, count int) error {
+	max := uint16(1<<uint(f.depth) - 1)
+	rect := img.Bounds()
+
+	for x := 0; x < count; x++ {
+		dx := xOffset + x*xFactor
+		if dx < rect.Min.X || dx >= rect.Max.X {
+			continue
+		}
+
+		// パレットはビット深度にかかわらず8ビットの色を持つ
+		if f.colorType == 3 {
+			index := int(f.sample(row, x))
+			if index >= len(f.palette) {
+				return fmt.Errorf("palette index %d out of range", index)
+			}
+			img.(*image.NRGBA).SetNRGBA(dx, y, f.palette[index])
+			continue
+		}
+
+		var r, g, b, a uint16
+		switch f.colorType {
+		case 0:
+			r = f.sample(row, x)
+			g, b, a = r, r, max
+			if f.hasTransparent && r == f.transparent[0] {
+				a = 0
+			}
+		case 2:
+			r, g, b, a = f.sample(row, 3*x), f.sample(row, 3*x+1), f.sample(row, 3*x+2), max
+			if f.hasTransparent && r == f.transparent[0] && g == f.transparent[1] && b == f.transparent[2] {
+				a = 0
+			}
+		case 4:
+			r, a = f.sample(row, 2*x), f.sample(row, 2*x+1)
+			g, b = r, r
+		case 6:
+			r, g, b, a = f.sample(row, 4*x), f.sample(row, 4*x+1), f.sample(row, 4*x+2), f.sample(row, 4*x+3)
+		}
+
+		switch m := img.(type) {
+		case *image.NRGBA64:
+			i := m.PixOffset(dx, y)
+			binary.BigEndian.PutUint16(m.Pix[i:], r)
+			binary.BigEndian.PutUint16(m.Pix[i+2:], g)
+			binary.BigEndian.PutUint16(m.Pix[i+4:], b)
+			binary.BigEndian.PutUint16(m.Pix[i+6:], a)
+		case *image.NRGBA:
+			i := m.PixOffset(dx, y)
+			m.Pix[i] = f.scale(r)   // R
+			m.Pix[i+1] = f.scale(g) // G
+			m.Pix[i+2] = f.scale(b) // B
+			m.Pix[i+3] = f.scale(a) // A
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package pngreaderpb
+
+import (
+	"image/color"
+
+	pngreader "github.com/kouheiszk/png-reader"
+)
+
+// FromInfo はpngreader.Infoをメッセージに変換する。proto.Marshalでバイナリにできる。
+func FromInfo(info *pngreader.Info) *Info {
+	m := &Info{
+		Width:          uint32(info.Width),
+		Height:         uint32(info.Height),
+		ColorType:      uint32(info.ColorType),
+		BitDepth:       uint32(info.BitDepth),
+		Interlace:      info.Interlace,
+		PaletteSize:    uint32(info.PaletteSize),
+		IccProfileName: info.ICCProfileName,
+		IccProfile:     info.ICCProfile,
+		Orientation:    uint32(info.Orientation),
+		Exif:           info.EXIF,
+	}
+	for _, c := range info.Chunks {
+		m.Chunks = append(m.Chunks, &Chunk{Type: c.Type, Offset: c.Offset, Length: uint32(c.Length)})
+	}
+	if b := info.Background; b != nil {
+		m.Background = &Color{R: uint32(b.R), G: uint32(b.G), B: uint32(b.B), A: uint32(b.A)}
+	}
+	return m
+}
+
+// ToInfo はメッセージをpngreader.Infoに戻す。
+func (m *Info) ToInfo() *pngreader.Info {
+	info := &pngreader.Info{
+		Width:          int(m.GetWidth()),
+		Height:         int(m.GetHeight()),
+		ColorType:      pngreader.ColorType(m.GetColorType()),
+		BitDepth:       int(m.GetBitDepth()),
+		Interlace:      m.GetInterlace(),
+		PaletteSize:    int(m.GetPaletteSize()),
+		ICCProfileName: m.GetIccProfileName(),
+		ICCProfile:     m.GetIccProfile(),
+		Orientation:    int(m.GetOrientation()),
+		EXIF:           m.GetExif(),
+	}
+	for _, c := range m.GetChunks() {
+		info.Chunks = append(info.Chunks, pngreader.ChunkInfo{Type: c.GetType(), Offset: c.GetOffset(), Length: int(c.GetLength())})
+	}
+	if b := m.GetBackground(); b != nil {
+		info.Background = &color.NRGBA64{uint16(b.GetR()), uint16(b.GetG()), uint16(b.GetB()), uint16(b.GetA())}
+	}
+	return info
+}
+
+// FromReport はpngreader.Reportをメッセージに変換する。
+func FromReport(report *pngreader.Report) *Report {
+	m := &Report{Conformant: report.Conformant, Error: report.Error}
+	for _, c := range report.Checks {
+		m.Checks = append(m.Checks, &CheckResult{
+			Id:       c.ID,
+			Section:  c.Section,
+			Title:    c.Title,
+			Status:   c.Status,
+			Messages: c.Messages,
+		})
+	}
+	return m
+}
+
+// ToReport はメッセージをpngreader.Reportに戻す。
+func (m *Report) ToReport() *pngreader.Report {
+	report := &pngreader.Report{Conformant: m.GetConformant(), Error: m.GetError()}
+	for _, c := range m.GetChecks() {
+		report.Checks = append(report.Checks, pngreader.CheckResult{
+			ID:       c.GetId(),
+			Section:  c.GetSection(),
+			Title:    c.GetTitle(),
+			Status:   c.GetStatus(),
+			Messages: c.GetMessages(),
+		})
+	}
+	return report
+}
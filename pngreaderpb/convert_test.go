@@ -0,0 +1,78 @@
+package pngreaderpb
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	pngreader "github.com/kouheiszk/png-reader"
+)
+
+// TestInfoRoundTrip はInfoがメッセージを経由しても、バイナリにしても同じ値に戻ることを確認する。
+func TestInfoRoundTrip(t *testing.T) {
+	img := image.NewPaletted(image.Rect(0, 0, 3, 2), color.Palette{color.Black, color.White})
+	var b bytes.Buffer
+	e := &pngreader.Encoder{EXIF: []byte{'I', 'I', 42, 0, 8, 0, 0, 0, 0, 0}}
+	if err := e.Encode(&b, img); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := pngreader.DecodeInfo(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	infos := []*pngreader.Info{
+		decoded,
+		{
+			Width: 7, Height: 9, ColorType: pngreader.TruecolorAlpha, BitDepth: 16, Interlace: true,
+			Chunks:         []pngreader.ChunkInfo{{Type: "IHDR", Offset: 8, Length: 13}},
+			Background:     &color.NRGBA64{1, 2, 3, 0xffff},
+			ICCProfileName: "test",
+			ICCProfile:     []byte("profile"),
+			Orientation:    6,
+		},
+	}
+	for _, info := range infos {
+		data, err := proto.Marshal(FromInfo(info))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var m Info
+		if err := proto.Unmarshal(data, &m); err != nil {
+			t.Fatal(err)
+		}
+		if got := m.ToInfo(); !reflect.DeepEqual(got, info) {
+			t.Errorf("got %+v, want %+v", got, info)
+		}
+	}
+}
+
+// TestReportRoundTrip は適合する画像と適合しない画像のReportがメッセージから元に戻ることを確認する。
+func TestReportRoundTrip(t *testing.T) {
+	for _, name := range []string{"one-pixel.png", "bad-filter-type.png"} {
+		data, err := ioutil.ReadFile(filepath.Join("..", "testdata", "regressions", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		report, err := pngreader.ConformanceReport(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded, err := proto.Marshal(FromReport(report))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var m Report
+		if err := proto.Unmarshal(encoded, &m); err != nil {
+			t.Fatal(err)
+		}
+		if got := m.ToReport(); !reflect.DeepEqual(got, report) {
+			t.Errorf("%s: got %+v, want %+v", name, got, report)
+		}
+	}
+}
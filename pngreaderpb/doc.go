@@ -0,0 +1,7 @@
+// Package pngreaderpb はpngreader serve-grpcが提供するgRPCサービスの、
+// pngreader.protoから生成したコード。
+// gRPCとprotobufに依存するため、ライブラリの利用者がそれらを取り込まないよう
+// pngreaderとは別のモジュールにしている。
+package pngreaderpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative pngreader.proto
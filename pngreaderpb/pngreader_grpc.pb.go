@@ -0,0 +1,210 @@
+// pngreader.proto はpngreader serve-grpcが提供するサービスと、
+// デコード結果のメタデータ(Info、Report)のスキーマの定義。
+//
+// パッケージ名のv1がスキーマのバージョン。v1の中ではフィールドの追加だけを行い、
+// 既存のフィールドの番号や型は変えない。互換性のない変更はv2として別に定義する。
+//
+// コードの生成:
+//   protoc --go_out=. --go_opt=paths=source_relative \
+//     --go-grpc_out=. --go-grpc_opt=paths=source_relative pngreader.proto
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: pngreader.proto
+
+package pngreaderpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	PNGReader_DecodeInfo_FullMethodName = "/pngreader.v1.PNGReader/DecodeInfo"
+	PNGReader_Validate_FullMethodName   = "/pngreader.v1.PNGReader/Validate"
+	PNGReader_Convert_FullMethodName    = "/pngreader.v1.PNGReader/Convert"
+)
+
+// PNGReaderClient is the client API for PNGReader service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PNGReaderClient interface {
+	// DecodeInfo はPNGのヘッダとチャンク構成を返す。画像データは展開しない。
+	DecodeInfo(ctx context.Context, in *DecodeInfoRequest, opts ...grpc.CallOption) (*Info, error)
+	// Validate はPNGの仕様適合性を検証する。
+	Validate(ctx context.Context, in *ValidateRequest, opts ...grpc.CallOption) (*Report, error)
+	// Convert はPNGを分割して受け取ってデコードし、指定された形式で分割して返す。
+	// 最初のメッセージのformatとstrictを使い、dataはすべてのメッセージから連結する。
+	Convert(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ConvertRequest, ConvertResponse], error)
+}
+
+type pNGReaderClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPNGReaderClient(cc grpc.ClientConnInterface) PNGReaderClient {
+	return &pNGReaderClient{cc}
+}
+
+func (c *pNGReaderClient) DecodeInfo(ctx context.Context, in *DecodeInfoRequest, opts ...grpc.CallOption) (*Info, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Info)
+	err := c.cc.Invoke(ctx, PNGReader_DecodeInfo_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pNGReaderClient) Validate(ctx context.Context, in *ValidateRequest, opts ...grpc.CallOption) (*Report, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Report)
+	err := c.cc.Invoke(ctx, PNGReader_Validate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pNGReaderClient) Convert(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ConvertRequest, ConvertResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PNGReader_ServiceDesc.Streams[0], PNGReader_Convert_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ConvertRequest, ConvertResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PNGReader_ConvertClient = grpc.BidiStreamingClient[ConvertRequest, ConvertResponse]
+
+// PNGReaderServer is the server API for PNGReader service.
+// All implementations must embed UnimplementedPNGReaderServer
+// for forward compatibility.
+type PNGReaderServer interface {
+	// DecodeInfo はPNGのヘッダとチャンク構成を返す。画像データは展開しない。
+	DecodeInfo(context.Context, *DecodeInfoRequest) (*Info, error)
+	// Validate はPNGの仕様適合性を検証する。
+	Validate(context.Context, *ValidateRequest) (*Report, error)
+	// Convert はPNGを分割して受け取ってデコードし、指定された形式で分割して返す。
+	// 最初のメッセージのformatとstrictを使い、dataはすべてのメッセージから連結する。
+	Convert(grpc.BidiStreamingServer[ConvertRequest, ConvertResponse]) error
+	mustEmbedUnimplementedPNGReaderServer()
+}
+
+// UnimplementedPNGReaderServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedPNGReaderServer struct{}
+
+func (UnimplementedPNGReaderServer) DecodeInfo(context.Context, *DecodeInfoRequest) (*Info, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DecodeInfo not implemented")
+}
+func (UnimplementedPNGReaderServer) Validate(context.Context, *ValidateRequest) (*Report, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Validate not implemented")
+}
+func (UnimplementedPNGReaderServer) Convert(grpc.BidiStreamingServer[ConvertRequest, ConvertResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Convert not implemented")
+}
+func (UnimplementedPNGReaderServer) mustEmbedUnimplementedPNGReaderServer() {}
+func (UnimplementedPNGReaderServer) testEmbeddedByValue()                   {}
+
+// UnsafePNGReaderServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PNGReaderServer will
+// result in compilation errors.
+type UnsafePNGReaderServer interface {
+	mustEmbedUnimplementedPNGReaderServer()
+}
+
+func RegisterPNGReaderServer(s grpc.ServiceRegistrar, srv PNGReaderServer) {
+	// If the following call pancis, it indicates UnimplementedPNGReaderServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&PNGReader_ServiceDesc, srv)
+}
+
+func _PNGReader_DecodeInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DecodeInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PNGReaderServer).DecodeInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PNGReader_DecodeInfo_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PNGReaderServer).DecodeInfo(ctx, req.(*DecodeInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PNGReader_Validate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ValidateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PNGReaderServer).Validate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PNGReader_Validate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PNGReaderServer).Validate(ctx, req.(*ValidateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PNGReader_Convert_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(PNGReaderServer).Convert(&grpc.GenericServerStream[ConvertRequest, ConvertResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PNGReader_ConvertServer = grpc.BidiStreamingServer[ConvertRequest, ConvertResponse]
+
+// PNGReader_ServiceDesc is the grpc.ServiceDesc for PNGReader service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var PNGReader_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pngreader.v1.PNGReader",
+	HandlerType: (*PNGReaderServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "DecodeInfo",
+			Handler:    _PNGReader_DecodeInfo_Handler,
+		},
+		{
+			MethodName: "Validate",
+			Handler:    _PNGReader_Validate_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Convert",
+			Handler:       _PNGReader_Convert_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "pngreader.proto",
+}
@@ -0,0 +1,110 @@
+// Package pnm は画像をNetpbmのバイナリ形式(P5 PGM、P6 PPM、P7 PAM)で書き出す。
+package pnm
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+
+	"github.com/kouheiszk/png-reader/internal/imageutil"
+)
+
+// Format は書き出すNetpbmの形式
+type Format int
+
+const (
+	// Auto は透明なピクセルがあればPAM、すべてグレーならPGM、それ以外はPPMを選ぶ。
+	Auto Format = iota
+	// PGM はグレースケール(P5)。色はグレーに変換し、透明度は捨てる。
+	PGM
+	// PPM はRGB(P6)。透明度は捨てる。
+	PPM
+	// PAM はPAM(P7)。グレーかRGBかは画像から選び、透明なピクセルがあればアルファを含める。
+	PAM
+)
+
+// Encoder はNetpbmの書き出し方法を設定する。
+type Encoder struct {
+	Format Format
+}
+
+// Encode はimgをAutoで選んだ形式でwに書き込む。
+func Encode(w io.Writer, img image.Image) error {
+	return new(Encoder).Encode(w, img)
+}
+
+// Encode はimgをwに書き込む。16ビットの画像は最大値65535、それ以外は255で書き込む。
+func (e *Encoder) Encode(w io.Writer, img image.Image) error {
+	b := img.Bounds()
+	if b.Dx() <= 0 || b.Dy() <= 0 {
+		return fmt.Errorf("pnm: invalid image dimensions %dx%d", b.Dx(), b.Dy())
+	}
+
+	maxval := 255
+	if imageutil.Is16Bit(img) {
+		maxval = 65535
+	}
+	opaque := imageutil.Opaque(img)
+	gray := e.Format == PGM
+	if e.Format == Auto || e.Format == PAM {
+		gray = imageutil.Gray(img)
+	}
+	alpha := (e.Format == Auto || e.Format == PAM) && !opaque
+	pam := e.Format == PAM || (e.Format == Auto && alpha)
+
+	bw := bufio.NewWriter(w)
+	depth := 3
+	if gray {
+		depth = 1
+	}
+	if alpha {
+		depth++
+	}
+	switch {
+	case pam:
+		tupleType := map[[2]bool]string{
+			{false, false}: "RGB",
+			{false, true}:  "RGB_ALPHA",
+			{true, false}:  "GRAYSCALE",
+			{true, true}:   "GRAYSCALE_ALPHA",
+		}[[2]bool{gray, alpha}]
+		fmt.Fprintf(bw, "P7\nWIDTH %d\nHEIGHT %d\nDEPTH %d\nMAXVAL %d\nTUPLTYPE %s\nENDHDR\n",
+			b.Dx(), b.Dy(), depth, maxval, tupleType)
+	case gray:
+		fmt.Fprintf(bw, "P5\n%d %d\n%d\n", b.Dx(), b.Dy(), maxval)
+	default:
+		fmt.Fprintf(bw, "P6\n%d %d\n%d\n", b.Dx(), b.Dy(), maxval)
+	}
+
+	samples := make([]uint16, 0, 4)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := imageutil.NRGBA64(img.At(x, y))
+			samples = samples[:0]
+			if gray {
+				samples = append(samples, luminance(c))
+			} else {
+				samples = append(samples, c.R, c.G, c.B)
+			}
+			if alpha {
+				samples = append(samples, c.A)
+			}
+			for _, s := range samples {
+				if maxval == 65535 {
+					bw.WriteByte(uint8(s >> 8))
+					bw.WriteByte(uint8(s))
+				} else {
+					bw.WriteByte(uint8(s >> 8))
+				}
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// luminance はcのグレースケールの値を返す。係数はcolor.GrayModelと同じ。
+func luminance(c color.NRGBA64) uint16 {
+	return uint16((19595*uint32(c.R) + 38470*uint32(c.G) + 7471*uint32(c.B) + 1<<15) >> 16)
+}
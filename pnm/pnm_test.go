@@ -0,0 +1,161 @@
+package pnm
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"strings"
+	"testing"
+)
+
+// netpbm はテストで読み直したNetpbmのファイル
+type netpbm struct {
+	magic                string
+	width, height, depth int
+	maxval               int
+	tupleType            string
+	samples              []int
+}
+
+// readNetpbm はP5、P6、P7のファイルを読む。コメントには対応しない。
+func readNetpbm(data []byte) (*netpbm, error) {
+	br := bufio.NewReader(bytes.NewReader(data))
+	p := new(netpbm)
+	if _, err := fmt.Fscanln(br, &p.magic); err != nil {
+		return nil, err
+	}
+	switch p.magic {
+	case "P5", "P6":
+		if _, err := fmt.Fscanln(br, &p.width, &p.height); err != nil {
+			return nil, err
+		}
+		if _, err := fmt.Fscanln(br, &p.maxval); err != nil {
+			return nil, err
+		}
+		p.depth = map[string]int{"P5": 1, "P6": 3}[p.magic]
+	case "P7":
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				return nil, err
+			}
+			fields := strings.Fields(line)
+			if fields[0] == "ENDHDR" {
+				break
+			}
+			switch fields[0] {
+			case "WIDTH":
+				fmt.Sscan(fields[1], &p.width)
+			case "HEIGHT":
+				fmt.Sscan(fields[1], &p.height)
+			case "DEPTH":
+				fmt.Sscan(fields[1], &p.depth)
+			case "MAXVAL":
+				fmt.Sscan(fields[1], &p.maxval)
+			case "TUPLTYPE":
+				p.tupleType = fields[1]
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown magic %q", p.magic)
+	}
+	rest, err := io.ReadAll(br)
+	if err != nil {
+		return nil, err
+	}
+	bytesPerSample := 1
+	if p.maxval > 255 {
+		bytesPerSample = 2
+	}
+	if want := p.width * p.height * p.depth * bytesPerSample; len(rest) != want {
+		return nil, fmt.Errorf("got %d bytes of samples, want %d", len(rest), want)
+	}
+	for i := 0; i < len(rest); i += bytesPerSample {
+		s := int(rest[i])
+		if bytesPerSample == 2 {
+			s = s<<8 | int(rest[i+1])
+		}
+		p.samples = append(p.samples, s)
+	}
+	return p, nil
+}
+
+func encode(t *testing.T, e *Encoder, img image.Image) *netpbm {
+	t.Helper()
+	var b bytes.Buffer
+	if err := e.Encode(&b, img); err != nil {
+		t.Fatal(err)
+	}
+	p, err := readNetpbm(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); p.width != size.X || p.height != size.Y {
+		t.Errorf("size %dx%d, want %v", p.width, p.height, size)
+	}
+	return p
+}
+
+// TestEncodeFormats は画像と指定した形式から、ヘッダとサンプルの並びが正しく選ばれることを確認する。
+func TestEncodeFormats(t *testing.T) {
+	rgb := image.NewNRGBA(image.Rect(1, 1, 3, 2))
+	rgb.SetNRGBA(1, 1, color.NRGBA{10, 20, 30, 255})
+	rgb.SetNRGBA(2, 1, color.NRGBA{40, 50, 60, 128})
+	gray := image.NewGray(image.Rect(0, 0, 2, 1))
+	gray.SetGray(0, 0, color.Gray{7})
+	gray.SetGray(1, 0, color.Gray{200})
+
+	tests := []struct {
+		name      string
+		format    Format
+		img       image.Image
+		magic     string
+		tupleType string
+		samples   []int
+	}{
+		{"auto translucent", Auto, rgb, "P7", "RGB_ALPHA", []int{10, 20, 30, 255, 40, 50, 60, 128}},
+		{"auto gray", Auto, gray, "P5", "", []int{7, 200}},
+		{"ppm drops alpha", PPM, rgb, "P6", "", []int{10, 20, 30, 40, 50, 60}},
+		{"pgm", PGM, gray, "P5", "", []int{7, 200}},
+		{"pam gray", PAM, gray, "P7", "GRAYSCALE", []int{7, 200}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := encode(t, &Encoder{Format: tt.format}, tt.img)
+			if p.magic != tt.magic || p.tupleType != tt.tupleType || p.maxval != 255 {
+				t.Errorf("header %s %s maxval %d, want %s %s maxval 255", p.magic, p.tupleType, p.maxval, tt.magic, tt.tupleType)
+			}
+			if fmt.Sprint(p.samples) != fmt.Sprint(tt.samples) {
+				t.Errorf("samples %v, want %v", p.samples, tt.samples)
+			}
+		})
+	}
+}
+
+// TestEncode16Bit は16ビットの画像を最大値65535で、ビッグエンディアンの2バイトずつ書き込むことを確認する。
+func TestEncode16Bit(t *testing.T) {
+	img := image.NewNRGBA64(image.Rect(0, 0, 1, 1))
+	img.SetNRGBA64(0, 0, color.NRGBA64{0x1234, 0x5678, 0x9abc, 0xffff})
+	p := encode(t, &Encoder{Format: PPM}, img)
+	if p.maxval != 65535 || fmt.Sprint(p.samples) != fmt.Sprint([]int{0x1234, 0x5678, 0x9abc}) {
+		t.Errorf("maxval %d samples %x", p.maxval, p.samples)
+	}
+}
+
+// TestPGMLuminance はPGMで色をcolor.GrayModelと同じ係数でグレーにすることを確認する。
+func TestPGMLuminance(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 1))
+	colors := []color.NRGBA{{255, 0, 0, 255}, {0, 255, 0, 255}, {12, 34, 56, 255}}
+	for x, c := range colors {
+		img.SetNRGBA(x, 0, c)
+	}
+	p := encode(t, &Encoder{Format: PGM}, img)
+	for x, c := range colors {
+		if want := int(color.GrayModel.Convert(c).(color.Gray).Y); p.samples[x] != want {
+			t.Errorf("pixel %d: got %d, want %d", x, p.samples[x], want)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+// Package qoi はQOI(Quite OK Image)形式の画像を読み書きする。
+// QOIは非乗算済みの8ビットRGB/RGBAを、直前のピクセルとの差分、ラン長、
+// 最近使った色のインデックスで符号化する。
+//
+// パッケージを読み込むとimage.DecodeでQOIを扱えるようになる。
+package qoi
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+
+	"github.com/kouheiszk/png-reader/internal/imageutil"
+)
+
+const magic = "qoif"
+
+// QOIの各チャンクのタグ
+const (
+	opIndex = 0x00 // 00xxxxxx
+	opDiff  = 0x40 // 01xxxxxx
+	opLuma  = 0x80 // 10xxxxxx
+	opRun   = 0xc0 // 11xxxxxx
+	opRGB   = 0xfe
+	opRGBA  = 0xff
+
+	mask2 = 0xc0
+)
+
+// sRGB はヘッダのcolorspaceでsRGBを表す値
+const sRGB = 0
+
+// end はピクセル列の終わりを示すバイト列
+var end = [8]byte{0, 0, 0, 0, 0, 0, 0, 1}
+
+// maxPixels はQOIの仕様が定める最大ピクセル数
+const maxPixels = 400000000
+
+// DefaultMaxPixels はDecoder.MaxPixelsが0の場合の最大ピクセル数
+const DefaultMaxPixels = 1 << 24
+
+func init() {
+	image.RegisterFormat("qoi", magic, Decode, DecodeConfig)
+}
+
+func hash(c color.NRGBA) int {
+	return (int(c.R)*3 + int(c.G)*5 + int(c.B)*7 + int(c.A)*11) % 64
+}
+
+// Decoder はQOIの読み込みの設定
+type Decoder struct {
+	// MaxPixels はデコードする画像の最大ピクセル数(幅×高さ)。0の場合はDefaultMaxPixels。
+	// 仕様の上限の4億ピクセルを超える値を指定しても、仕様の上限を使う
+	MaxPixels int
+}
+
+func (d *Decoder) maxPixels() uint64 {
+	switch {
+	case d.MaxPixels <= 0:
+		return DefaultMaxPixels
+	case d.MaxPixels > maxPixels:
+		return maxPixels
+	}
+	return uint64(d.MaxPixels)
+}
+
+func (d *Decoder) readHeader(r io.Reader) (width, height int, err error) {
+	var header [14]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, 0, fmt.Errorf("qoi: reading header: %w", err)
+	}
+	if string(header[:4]) != magic {
+		return 0, 0, fmt.Errorf("qoi: invalid signature")
+	}
+	w := binary.BigEndian.Uint32(header[4:8])
+	h := binary.BigEndian.Uint32(header[8:12])
+	if w == 0 || h == 0 {
+		return 0, 0, fmt.Errorf("qoi: invalid image dimensions %dx%d", w, h)
+	}
+	if max := d.maxPixels(); uint64(w)*uint64(h) > max {
+		return 0, 0, fmt.Errorf("qoi: image size %dx%d exceeds limit of %d pixels", w, h, max)
+	}
+	if channels := header[12]; channels != 3 && channels != 4 {
+		return 0, 0, fmt.Errorf("qoi: invalid channel count %d", channels)
+	}
+	if colorspace := header[13]; colorspace > 1 {
+		return 0, 0, fmt.Errorf("qoi: invalid colorspace %d", colorspace)
+	}
+	return int(w), int(h), nil
+}
+
+// DecodeConfig はQOIのヘッダから画像の大きさを返す。
+func (d *Decoder) DecodeConfig(r io.Reader) (image.Config, error) {
+	width, height, err := d.readHeader(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{ColorModel: color.NRGBAModel, Width: width, Height: height}, nil
+}
+
+// Decode はQOIの画像を*image.NRGBAとして読み込む。ヘッダのチャネル数や色空間は
+// 参考情報なので、ピクセルの復元には使わない。
+func (d *Decoder) Decode(r io.Reader) (image.Image, error) {
+	br := bufio.NewReader(r)
+	width, height, err := d.readHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	// ヘッダの大きさで先に確保せず、復元したピクセルの分だけ広げる。
+	// 途中で終わるストリームで画像全体を確保しないようにする
+	size, capacity := width*height*4, 4096
+	if size < capacity {
+		capacity = size
+	}
+	pix := make([]byte, 0, capacity)
+
+	var index [64]color.NRGBA
+	c := color.NRGBA{A: 0xff}
+	run := 0
+	for len(pix) < size {
+		if run > 0 {
+			run--
+		} else {
+			tag, err := br.ReadByte()
+			if err != nil {
+				return nil, truncated(err)
+			}
+			switch {
+			case tag == opRGB || tag == opRGBA:
+				n := 3
+				if tag == opRGBA {
+					n = 4
+				}
+				var v [4]byte
+				if _, err := io.ReadFull(br, v[:n]); err != nil {
+					return nil, truncated(err)
+				}
+				c.R, c.G, c.B = v[0], v[1], v[2]
+				if tag == opRGBA {
+					c.A = v[3]
+				}
+			case tag&mask2 == opIndex:
+				c = index[tag]
+			case tag&mask2 == opDiff:
+				c.R += (tag>>4)&3 - 2
+				c.G += (tag>>2)&3 - 2
+				c.B += tag&3 - 2
+			case tag&mask2 == opLuma:
+				b, err := br.ReadByte()
+				if err != nil {
+					return nil, truncated(err)
+				}
+				dg := tag&0x3f - 32
+				c.R += dg - 8 + b>>4
+				c.G += dg
+				c.B += dg - 8 + b&0x0f
+			default:
+				run = int(tag & 0x3f)
+			}
+			index[hash(c)] = c
+		}
+		pix = append(pix, c.R, c.G, c.B, c.A)
+	}
+
+	var trailer [8]byte
+	if _, err := io.ReadFull(br, trailer[:]); err != nil {
+		return nil, truncated(err)
+	}
+	if trailer != end {
+		return nil, fmt.Errorf("qoi: invalid end marker")
+	}
+	return &image.NRGBA{Pix: pix, Stride: width * 4, Rect: image.Rect(0, 0, width, height)}, nil
+}
+
+// DecodeConfig はDefaultMaxPixelsまでのQOIのヘッダから画像の大きさを返す。
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	return new(Decoder).DecodeConfig(r)
+}
+
+// Decode はDefaultMaxPixelsまでのQOIの画像を*image.NRGBAとして読み込む。
+func Decode(r io.Reader) (image.Image, error) {
+	return new(Decoder).Decode(r)
+}
+
+func truncated(err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("qoi: reading pixels: %w", err)
+}
+
+// Encode はimgをQOIとしてwに書き込む。すべてのピクセルが不透明ならチャネル数を3とし、
+// 16ビットの画像は8ビットに丸める。
+func Encode(w io.Writer, img image.Image) error {
+	b := img.Bounds()
+	if b.Dx() <= 0 || b.Dy() <= 0 || int64(b.Dx())*int64(b.Dy()) > maxPixels {
+		return fmt.Errorf("qoi: invalid image dimensions %dx%d", b.Dx(), b.Dy())
+	}
+
+	channels := byte(4)
+	if imageutil.Opaque(img) {
+		channels = 3
+	}
+
+	bw := bufio.NewWriter(w)
+	var header [14]byte
+	copy(header[:4], magic)
+	binary.BigEndian.PutUint32(header[4:8], uint32(b.Dx()))
+	binary.BigEndian.PutUint32(header[8:12], uint32(b.Dy()))
+	header[12] = channels
+	header[13] = sRGB
+	bw.Write(header[:])
+
+	var index [64]color.NRGBA
+	prev := color.NRGBA{A: 0xff}
+	run := 0
+	last := b.Max.Y - 1
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			n := imageutil.NRGBA64(img.At(x, y))
+			c := color.NRGBA{uint8(n.R >> 8), uint8(n.G >> 8), uint8(n.B >> 8), uint8(n.A >> 8)}
+			if c == prev {
+				run++
+				if run == 62 || (y == last && x == b.Max.X-1) {
+					bw.WriteByte(opRun | byte(run-1))
+					run = 0
+				}
+				continue
+			}
+			if run > 0 {
+				bw.WriteByte(opRun | byte(run-1))
+				run = 0
+			}
+
+			h := hash(c)
+			switch {
+			case index[h] == c:
+				bw.WriteByte(opIndex | byte(h))
+			case c.A != prev.A:
+				bw.Write([]byte{opRGBA, c.R, c.G, c.B, c.A})
+			default:
+				// 差分はバイトの桁あふれを含めて計算する
+				dr := int(int8(c.R - prev.R))
+				dg := int(int8(c.G - prev.G))
+				db := int(int8(c.B - prev.B))
+				drdg := dr - dg
+				dbdg := db - dg
+				switch {
+				case -2 <= dr && dr <= 1 && -2 <= dg && dg <= 1 && -2 <= db && db <= 1:
+					bw.WriteByte(opDiff | byte(dr+2)<<4 | byte(dg+2)<<2 | byte(db+2))
+				case -32 <= dg && dg <= 31 && -8 <= drdg && drdg <= 7 && -8 <= dbdg && dbdg <= 7:
+					bw.Write([]byte{opLuma | byte(dg+32), byte(drdg+8)<<4 | byte(dbdg+8)})
+				default:
+					bw.Write([]byte{opRGB, c.R, c.G, c.B})
+				}
+			}
+			index[h] = c
+			prev = c
+		}
+	}
+	bw.Write(end[:])
+	return bw.Flush()
+}
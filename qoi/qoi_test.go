@@ -0,0 +1,134 @@
+package qoi
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"math/rand"
+	"runtime"
+	"testing"
+)
+
+func roundTrip(t *testing.T, img image.Image) image.Image {
+	t.Helper()
+	var b bytes.Buffer
+	if err := Encode(&b, img); err != nil {
+		t.Fatal(err)
+	}
+	config, err := DecodeConfig(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); config.Width != size.X || config.Height != size.Y {
+		t.Errorf("config %dx%d, want %v", config.Width, config.Height, size)
+	}
+	m, err := Decode(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// TestRoundTrip は差分、ラン長、インデックスのすべての符号が出るように、
+// 近い色と繰り返しを混ぜた画像が元の色に戻ることを確認する。
+func TestRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, opaque := range []bool{false, true} {
+		img := image.NewNRGBA(image.Rect(0, 0, 67, 13))
+		palette := make([]color.NRGBA, 5)
+		for i := range palette {
+			palette[i] = color.NRGBA{uint8(r.Intn(256)), uint8(r.Intn(256)), uint8(r.Intn(256)), uint8(r.Intn(256))}
+		}
+		var c color.NRGBA
+		for i := 0; i < len(img.Pix); i += 4 {
+			switch r.Intn(4) {
+			case 0: // 直前の色に近い色
+				c.R += uint8(r.Intn(5)) - 2
+				c.G += uint8(r.Intn(31)) - 15
+				c.B += uint8(r.Intn(5)) - 2
+			case 1: // 最近使った色
+				c = palette[r.Intn(len(palette))]
+			case 2: // 任意の色
+				c = color.NRGBA{uint8(r.Intn(256)), uint8(r.Intn(256)), uint8(r.Intn(256)), uint8(r.Intn(256))}
+			}
+			if opaque {
+				c.A = 0xff
+			}
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+		}
+
+		m := roundTrip(t, img)
+		for y := 0; y < 13; y++ {
+			for x := 0; x < 67; x++ {
+				got := color.NRGBAModel.Convert(m.At(x, y)).(color.NRGBA)
+				if want := img.NRGBAAt(x, y); got != want {
+					t.Fatalf("opaque=%v (%d, %d): got %v, want %v", opaque, x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+// TestEncode16Bit は16ビットの画像の各サンプルの上位8ビットを、乗算済みを経由せずに書き込むことを確認する。
+func TestEncode16Bit(t *testing.T) {
+	img := image.NewNRGBA64(image.Rect(0, 0, 2, 1))
+	img.SetNRGBA64(0, 0, color.NRGBA64{0x12ff, 0x3456, 0xfe01, 0x0180})
+	img.SetNRGBA64(1, 0, color.NRGBA64{0xffff, 0x00ff, 0x8080, 0xffff})
+	m := roundTrip(t, img)
+	want := []color.NRGBA{{0x12, 0x34, 0xfe, 0x01}, {0xff, 0x00, 0x80, 0xff}}
+	for x, w := range want {
+		if got := color.NRGBAModel.Convert(m.At(x, 0)).(color.NRGBA); got != w {
+			t.Errorf("pixel %d: got %v, want %v", x, got, w)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	var b bytes.Buffer
+	if err := Encode(&b, image.NewNRGBA(image.Rect(0, 0, 3, 2))); err != nil {
+		t.Fatal(err)
+	}
+	data := b.Bytes()
+	tests := map[string][]byte{
+		"bad magic":  append([]byte("qoix"), data[4:]...),
+		"channels":   append(append([]byte(nil), data[:12]...), append([]byte{5}, data[13:]...)...),
+		"no pixels":  data[:14],
+		"end marker": append(append([]byte(nil), data[:len(data)-1]...), 2),
+		"no end":     data[:len(data)-8],
+	}
+	for name, input := range tests {
+		if _, err := Decode(bytes.NewReader(input)); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+// TestDecodeLimit はMaxPixelsを超える画像を拒否し、途中で終わるストリームで
+// ヘッダが宣言した大きさのメモリを確保しないことを確認する。
+func TestDecodeLimit(t *testing.T) {
+	var b bytes.Buffer
+	if err := Encode(&b, image.NewNRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&Decoder{MaxPixels: 15}).Decode(bytes.NewReader(b.Bytes())); err == nil {
+		t.Error("16 pixels accepted with a limit of 15")
+	}
+	if _, err := (&Decoder{MaxPixels: 16}).Decode(bytes.NewReader(b.Bytes())); err != nil {
+		t.Error(err)
+	}
+
+	// 20000x20000の画像を宣言し、ラン1つで終わる入力
+	input := []byte("qoif\x00\x00\x4e\x20\x00\x00\x4e\x20\x04\x00\xfd")
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := (&Decoder{MaxPixels: 400000000}).Decode(bytes.NewReader(input))
+	runtime.ReadMemStats(&after)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("got %v, want io.ErrUnexpectedEOF", err)
+	}
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Errorf("allocated %d bytes for a 15-byte input", n)
+	}
+}
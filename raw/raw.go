@@ -0,0 +1,117 @@
+// Package raw は画像をヘッダのない8ビットのピクセル列として書き出す。
+// 大きさなどの情報はGeometryとして別に記録する。
+package raw
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+
+	"github.com/kouheiszk/png-reader/internal/imageutil"
+)
+
+// Layout はピクセルの並べ方
+type Layout string
+
+const (
+	// RGBA はピクセルごとにR、G、B、Aの順に並べる。
+	RGBA Layout = "rgba"
+	// BGRA はピクセルごとにB、G、R、Aの順に並べる。
+	BGRA Layout = "bgra"
+	// RGB はピクセルごとにR、G、Bの順に並べ、透明度は捨てる。
+	RGB Layout = "rgb"
+	// Planar はR、G、B、Aの各チャンネルを画像全体ずつ順に並べる。
+	Planar Layout = "planar"
+)
+
+// Geometry は書き出したデータの構造。JSONのサイドカーファイルとして保存する。
+type Geometry struct {
+	Width          int    `json:"width"`
+	Height         int    `json:"height"`
+	Layout         Layout `json:"layout"`
+	Channels       string `json:"channels"`
+	BytesPerSample int    `json:"bytesPerSample"`
+	// Stride は1行のバイト数。Planarの場合は1チャンネル分の行のバイト数
+	Stride int `json:"stride"`
+	// PlaneSize はPlanarの場合の1チャンネル分のバイト数
+	PlaneSize     int  `json:"planeSize,omitempty"`
+	Premultiplied bool `json:"premultiplied"`
+}
+
+// Encoder は書き出し方法を設定する。
+type Encoder struct {
+	Layout Layout
+}
+
+// Encode はimgをRGBAでwに書き込む。
+func Encode(w io.Writer, img image.Image) error {
+	return (&Encoder{Layout: RGBA}).Encode(w, img)
+}
+
+// Geometry はimgを書き出した場合のデータの構造を返す。
+func (e *Encoder) Geometry(img image.Image) (*Geometry, error) {
+	channels := map[Layout]string{RGBA: "RGBA", BGRA: "BGRA", RGB: "RGB", Planar: "RGBA"}[e.layout()]
+	if channels == "" {
+		return nil, fmt.Errorf("raw: unknown layout %q", e.Layout)
+	}
+	b := img.Bounds()
+	g := &Geometry{
+		Width:          b.Dx(),
+		Height:         b.Dy(),
+		Layout:         e.layout(),
+		Channels:       channels,
+		BytesPerSample: 1,
+		Stride:         b.Dx() * len(channels),
+	}
+	if g.Layout == Planar {
+		g.Stride = b.Dx()
+		g.PlaneSize = b.Dx() * b.Dy()
+	}
+	return g, nil
+}
+
+// Encode はimgをLayoutに従ってwに書き込む。色は非乗算済みの8ビットになる。
+func (e *Encoder) Encode(w io.Writer, img image.Image) error {
+	g, err := e.Geometry(img)
+	if err != nil {
+		return err
+	}
+	b := img.Bounds()
+	bw := bufio.NewWriter(w)
+
+	if g.Layout == Planar {
+		for channel := 0; channel < 4; channel++ {
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				for x := b.Min.X; x < b.Max.X; x++ {
+					c := imageutil.NRGBA64(img.At(x, y))
+					bw.WriteByte(uint8([4]uint16{c.R, c.G, c.B, c.A}[channel] >> 8))
+				}
+			}
+		}
+		return bw.Flush()
+	}
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := imageutil.NRGBA64(img.At(x, y))
+			r, g, b, a := uint8(c.R>>8), uint8(c.G>>8), uint8(c.B>>8), uint8(c.A>>8)
+			switch e.layout() {
+			case RGBA:
+				bw.Write([]byte{r, g, b, a})
+			case BGRA:
+				bw.Write([]byte{b, g, r, a})
+			case RGB:
+				bw.Write([]byte{r, g, b})
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+func (e *Encoder) layout() Layout {
+	if e.Layout == "" {
+		return RGBA
+	}
+	return e.Layout
+}
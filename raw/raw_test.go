@@ -0,0 +1,91 @@
+package raw
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// decode はGeometryに従ってdataを画像に戻す。RGBの場合はアルファを255にする。
+func decode(data []byte, g *Geometry) *image.NRGBA {
+	m := image.NewNRGBA(image.Rect(0, 0, g.Width, g.Height))
+	for y := 0; y < g.Height; y++ {
+		for x := 0; x < g.Width; x++ {
+			c := color.NRGBA{A: 0xff}
+			samples := []*uint8{&c.R, &c.G, &c.B, &c.A}
+			for i, ch := range g.Channels {
+				offset := y*g.Stride + x*len(g.Channels) + i
+				if g.Layout == Planar {
+					offset = i*g.PlaneSize + y*g.Stride + x
+				}
+				*samples[strings.IndexRune("RGBA", ch)] = data[offset]
+			}
+			m.SetNRGBA(x, y, c)
+		}
+	}
+	return m
+}
+
+// TestRoundTrip は各Layoutで書き出したデータが、Geometryに従って元の非乗算済みの色に戻ることを確認する。
+func TestRoundTrip(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(3, 2, 10, 7))
+	rand.New(rand.NewSource(1)).Read(img.Pix)
+	for _, layout := range []Layout{RGBA, BGRA, RGB, Planar, ""} {
+		e := &Encoder{Layout: layout}
+		g, err := e.Geometry(img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		if err := e.Encode(&b, img); err != nil {
+			t.Fatal(err)
+		}
+		if want := g.Width * g.Height * len(g.Channels); b.Len() != want {
+			t.Fatalf("%q: wrote %d bytes, want %d", layout, b.Len(), want)
+		}
+
+		m := decode(b.Bytes(), g)
+		for y := 0; y < g.Height; y++ {
+			for x := 0; x < g.Width; x++ {
+				want := img.NRGBAAt(3+x, 2+y)
+				if layout == RGB {
+					want.A = 0xff
+				}
+				if got := m.NRGBAAt(x, y); got != want {
+					t.Fatalf("%q (%d, %d): got %v, want %v", layout, x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+// TestGeometry はLayoutごとの行とプレーンの大きさを確認する。
+func TestGeometry(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 5, 3))
+	tests := []struct {
+		layout    Layout
+		channels  string
+		stride    int
+		planeSize int
+	}{
+		{RGBA, "RGBA", 20, 0},
+		{BGRA, "BGRA", 20, 0},
+		{RGB, "RGB", 15, 0},
+		{Planar, "RGBA", 5, 15},
+	}
+	for _, tt := range tests {
+		g, err := (&Encoder{Layout: tt.layout}).Geometry(img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g.Channels != tt.channels || g.Stride != tt.stride || g.PlaneSize != tt.planeSize || g.Premultiplied {
+			t.Errorf("%q: got %+v", tt.layout, g)
+		}
+	}
+	if _, err := (&Encoder{Layout: "cmyk"}).Geometry(img); err == nil {
+		t.Error("unknown layout: no error")
+	}
+}
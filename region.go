@@ -0,0 +1,31 @@
+package pngreader
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+)
+
+// ErrRegion はDecodeRegionの範囲が空か、画像と重ならないことを表す。入力の誤りではないので、
+// ErrFormatなどの分類には含めない。
+var ErrRegion = errors.New("invalid region")
+
+// DecodeRegion はrのPNG画像のうちrectの範囲だけを展開して返す。
+func DecodeRegion(r io.Reader, rect image.Rectangle) (image.Image, error) {
+	return new(Decoder).DecodeRegion(r, rect)
+}
+
+// DecodeRegion はrのPNG画像のうちrectの範囲だけを展開して返す。
+// 返す画像の範囲はrectと画像の範囲の共通部分で、SubImageと同じく元の画像の座標を使う。
+// rectが空か画像と重ならない場合は、IHDRを読んだ時点でErrRegionを返す。
+// 画素のメモリは範囲の分しか確保しないので、巨大な画像の一部を取り出すのに使える。
+// インターレースのない画像では範囲の最後の行で展開をやめるため、それより後の画像データは検証しない。
+// AutoOrientの場合は切り出した後に向きを直す。
+func (d *Decoder) DecodeRegion(r io.Reader, rect image.Rectangle) (image.Image, error) {
+	if rect.Empty() {
+		return nil, fmt.Errorf("%w: %v is empty", ErrRegion, rect)
+	}
+	p := &decoder{Decoder: d, seen: make(map[string]int), region: rect}
+	return p.parse(r)
+}
@@ -0,0 +1,56 @@
+package pngreader
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"math/rand"
+	"testing"
+)
+
+// TestDecodeRegion は範囲を指定したデコードが、画像全体をデコードしてSubImageで
+// 切り出した結果と一致することを確認する。
+func TestDecodeRegion(t *testing.T) {
+	m := randomNRGBA(rand.New(rand.NewSource(4)), image.Rect(0, 0, 37, 23))
+	rects := []image.Rectangle{
+		image.Rect(0, 0, 37, 23),
+		image.Rect(10, 5, 20, 6),
+		image.Rect(3, 0, 4, 23),
+		image.Rect(30, 20, 100, 100),
+		image.Rect(-5, -5, 2, 2),
+	}
+	for _, interlace := range []bool{false, true} {
+		var b bytes.Buffer
+		if err := (&Encoder{Interlace: interlace}).Encode(&b, m); err != nil {
+			t.Fatal(err)
+		}
+		for _, rect := range rects {
+			got, err := DecodeRegion(bytes.NewReader(b.Bytes()), rect)
+			if err != nil {
+				t.Fatalf("interlace=%v %v: %v", interlace, rect, err)
+			}
+			want := m.SubImage(rect)
+			if got.Bounds() != want.Bounds() {
+				t.Fatalf("interlace=%v %v: bounds %v, want %v", interlace, rect, got.Bounds(), want.Bounds())
+			}
+			assertSamePixels(t, got, want)
+		}
+	}
+}
+
+// TestDecodeRegionInvalid は空の範囲と画像と重ならない範囲をErrRegionにすることを確認する。
+func TestDecodeRegionInvalid(t *testing.T) {
+	var b bytes.Buffer
+	if err := Encode(&b, image.NewGray(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatal(err)
+	}
+	for _, rect := range []image.Rectangle{image.Rect(2, 2, 2, 5), image.Rect(8, 0, 10, 8), image.Rect(-4, -4, 0, 0)} {
+		_, err := DecodeRegion(bytes.NewReader(b.Bytes()), rect)
+		if !errors.Is(err, ErrRegion) {
+			t.Errorf("%v: got %v, want ErrRegion", rect, err)
+		}
+		if errors.Is(err, ErrFormat) {
+			t.Errorf("%v: %v is classified as a format error", rect, err)
+		}
+	}
+}
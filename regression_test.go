@@ -0,0 +1,68 @@
+package pngreader
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kouheiszk/png-reader/internal/imageutil"
+)
+
+// TestRegressions はtestdata/regressionsの各PNGをデコードし、同名の.wantファイルに
+// 書かれた結果と比較する。.wantの内容は、デコードに成功する場合は"ok"の行に続けて
+// pixelRowsの画素の値、失敗する場合はエラーメッセージそのもの。
+// クラッシュや誤ったデコードを修正したときは、再現するファイルをここに追加する。
+func TestRegressions(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "regressions", "*.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no regression files")
+	}
+
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".png")
+		t.Run(name, func(t *testing.T) {
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := ioutil.ReadFile(strings.TrimSuffix(file, ".png") + ".want")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got string
+			if m, err := Decode(bytes.NewReader(data)); err != nil {
+				got = err.Error()
+			} else {
+				got = "ok\n" + pixelRows(m)
+			}
+			if got != strings.TrimSpace(string(want)) {
+				t.Errorf("got %q, want %q", got, strings.TrimSpace(string(want)))
+			}
+		})
+	}
+}
+
+// pixelRows はmの画素を非乗算済みの16ビットのRGBAの16進数にし、画像の1行をテキストの1行にして返す。
+func pixelRows(m image.Image) string {
+	var b strings.Builder
+	bounds := m.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if x > bounds.Min.X {
+				b.WriteByte(' ')
+			}
+			c := imageutil.NRGBA64(m.At(x, y))
+			fmt.Fprintf(&b, "%04x%04x%04x%04x", c.R, c.G, c.B, c.A)
+		}
+		b.WriteByte('\n')
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
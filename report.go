@@ -0,0 +1,193 @@
+package pngreader
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+)
+
+// 検証項目のID
+const (
+	checkSignature     = "signature"
+	checkChunkLayout   = "chunk-layout"
+	checkChunkNaming   = "chunk-naming"
+	checkCRC           = "crc"
+	checkChunkOrdering = "chunk-ordering"
+	checkIHDR          = "ihdr"
+	checkPLTE          = "plte"
+	checkIDAT          = "idat"
+	checkIEND          = "iend"
+	checkTRNS          = "trns"
+	checkICCP          = "iccp"
+	checkText          = "text"
+	checkTIME          = "time"
+	checkBKGD          = "bkgd"
+	checkCompression   = "compression"
+	checkFiltering     = "filtering"
+)
+
+// デコードの進行段階。段階に到達しなかった検証項目は未実行として報告する。
+const (
+	stageSignature = iota
+	stageHeader
+	stageChunks
+	stageData
+	stageDone
+)
+
+// checkInfo は検証項目と対応する仕様の節(W3C PNG Second Edition)
+type checkInfo struct {
+	id      string
+	section string
+	title   string
+	stage   int
+}
+
+var checkInfos = []checkInfo{
+	{checkSignature, "5.2", "PNG signature", stageSignature},
+	{checkChunkLayout, "5.3", "Chunk layout", stageHeader},
+	{checkChunkNaming, "5.4", "Chunk naming conventions", stageHeader},
+	{checkCRC, "5.5", "Cyclic Redundancy Code", stageHeader},
+	{checkIHDR, "11.2.2", "IHDR Image header", stageHeader},
+	{checkChunkOrdering, "5.6", "Chunk ordering", stageChunks},
+	{checkPLTE, "11.2.3", "PLTE Palette", stageChunks},
+	{checkTRNS, "11.3.2.1", "tRNS Transparency", stageChunks},
+	{checkICCP, "11.3.3.3", "iCCP Embedded ICC profile", stageChunks},
+	{checkText, "11.3.4.2", "Keywords and text strings", stageChunks},
+	{checkTIME, "11.3.6.1", "tIME Image last-modification time", stageChunks},
+	{checkBKGD, "11.3.5.1", "bKGD Background colour", stageChunks},
+	{checkIEND, "11.2.5", "IEND Image trailer", stageChunks},
+	{checkIDAT, "11.2.4", "IDAT Image data", stageData},
+	{checkCompression, "10", "Compression", stageData},
+	{checkFiltering, "9", "Filtering", stageData},
+}
+
+// finding はデコード中に見つかった仕様違反
+type finding struct {
+	check   string
+	message string
+	fatal   bool
+}
+
+// 検証結果の状態
+const (
+	StatusPass   = "pass"
+	StatusFail   = "fail"
+	StatusNotRun = "not-run"
+)
+
+// CheckResult は1つの検証項目の結果
+type CheckResult struct {
+	ID       string   `json:"id"`
+	Section  string   `json:"section"`
+	Title    string   `json:"title"`
+	Status   string   `json:"status"`
+	Messages []string `json:"messages,omitempty"`
+}
+
+// Report はPNGファイルの仕様適合性の検証結果
+type Report struct {
+	Conformant bool          `json:"conformant"`
+	Error      string        `json:"error,omitempty"`
+	Checks     []CheckResult `json:"checks"`
+}
+
+// ConformanceReport はrのPNGファイルをすべての検証項目について調べ、結果を返す。
+// デコードに失敗した場合もエラーは返さず、Report.Errorに記録する。
+// エラーを返すのはrの読み込みに失敗した場合のみ。
+func ConformanceReport(r io.Reader) (*Report, error) {
+	return new(Decoder).ConformanceReportContext(context.Background(), r)
+}
+
+// ConformanceReportContext はdのLimitsを使ってConformanceReportと同じ検証を行う。
+// Limitsを超えた場合はReport.Errorに記録する。Limits以外の設定は使わない。
+// ctxが終了した場合は検証を中断し、ctx.Err()を返す。
+func (d *Decoder) ConformanceReportContext(ctx context.Context, r io.Reader) (*Report, error) {
+	buffer := new(bytes.Buffer)
+	if _, err := buffer.ReadFrom(r); err != nil {
+		return nil, err
+	}
+
+	p := &decoder{Decoder: &Decoder{Limits: d.Limits}, seen: make(map[string]int), ctx: ctx}
+	_, err := p.parse(buffer)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	report := &Report{Conformant: err == nil}
+	if err != nil {
+		report.Error = err.Error()
+	}
+	for _, info := range checkInfos {
+		result := CheckResult{ID: info.id, Section: info.section, Title: info.title, Status: StatusPass}
+		for _, f := range p.findings {
+			if f.check == info.id {
+				result.Status = StatusFail
+				result.Messages = append(result.Messages, f.message)
+			}
+		}
+		if result.Status == StatusPass && info.stage > p.stage {
+			result.Status = StatusNotRun
+		}
+		if result.Status == StatusFail {
+			report.Conformant = false
+		}
+		report.Checks = append(report.Checks, result)
+	}
+
+	return report, nil
+}
+
+// problem は仕様違反を記録する。Strictの場合はエラーを返し、そうでなければデコードを続ける。
+func (d *decoder) problem(check string, format string, args ...interface{}) error {
+	return d.problemError(check, fmt.Errorf(format, args...))
+}
+
+// problemError はerrを仕様違反として記録する。Strictの場合はエラーを返す。
+func (d *decoder) problemError(check string, err error) error {
+	err = d.errorAt(classify(check, err))
+	d.findings = append(d.findings, finding{check: check, message: err.Error(), fatal: d.Strict})
+	if d.Strict {
+		return err
+	}
+	if d.Warn != nil {
+		d.Warn(err)
+	}
+	return nil
+}
+
+// warn は仕様違反ではない問題をWarnに渡す。検証結果には記録しない。
+// Strictの場合は警告もエラーとして返す。
+func (d *decoder) warn(format string, args ...interface{}) error {
+	return d.warnError(fmt.Errorf(format, args...))
+}
+
+// warnError はerrを仕様違反ではない問題としてWarnに渡す。Strictの場合はエラーを返す。
+func (d *decoder) warnError(err error) error {
+	err = d.errorAt(classify("", err))
+	if d.Strict {
+		return err
+	}
+	if d.Warn != nil {
+		d.Warn(err)
+	}
+	return nil
+}
+
+// limit はLimitsを超えたことを表すエラーを返す。仕様違反ではないため検証結果には記録しない。
+func (d *decoder) limit(format string, args ...interface{}) error {
+	return d.errorAt(limitf(format, args...))
+}
+
+// fail はデコードを続けられない仕様違反を記録し、エラーを返す。
+func (d *decoder) fail(check string, format string, args ...interface{}) error {
+	return d.wrap(check, fmt.Errorf(format, args...))
+}
+
+// wrap はerrを分類して現在位置を付加し、検証項目checkの違反として記録して返す。
+func (d *decoder) wrap(check string, err error) error {
+	err = d.errorAt(classify(check, err))
+	d.findings = append(d.findings, finding{check: check, message: err.Error(), fatal: true})
+	return err
+}
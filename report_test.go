@@ -0,0 +1,149 @@
+package pngreader
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+)
+
+// testChunk はテスト用のPNGを組み立てるためのチャンク
+type testChunk struct {
+	chunkType string
+	data      []byte
+}
+
+// testPNG はシグネチャとchunksを並べたPNGファイルを返す。CRCは正しく計算する。
+func testPNG(chunks ...testChunk) []byte {
+	var b bytes.Buffer
+	b.WriteString(pngSignature)
+	for _, c := range chunks {
+		binary.Write(&b, binary.BigEndian, uint32(len(c.data)))
+		b.WriteString(c.chunkType)
+		b.Write(c.data)
+		binary.Write(&b, binary.BigEndian, crc32.ChecksumIEEE(append([]byte(c.chunkType), c.data...)))
+	}
+	return b.Bytes()
+}
+
+// testIHDR は8ビットのグレースケールのIHDRチャンクを返す。
+func testIHDR(width, height uint32) testChunk {
+	data := make([]byte, 13)
+	binary.BigEndian.PutUint32(data[0:], width)
+	binary.BigEndian.PutUint32(data[4:], height)
+	data[8] = 8
+	return testChunk{"IHDR", data}
+}
+
+func zlibBytes(data []byte) []byte {
+	var b bytes.Buffer
+	zw := zlib.NewWriter(&b)
+	zw.Write(data)
+	zw.Close()
+	return b.Bytes()
+}
+
+// TestConformanceReportSections は検証項目の順序と仕様の節を確認し、
+// 正しいファイルではすべての項目が通ることを確認する。
+func TestConformanceReportSections(t *testing.T) {
+	sections := []struct{ id, section string }{
+		{checkSignature, "5.2"},
+		{checkChunkLayout, "5.3"},
+		{checkChunkNaming, "5.4"},
+		{checkCRC, "5.5"},
+		{checkIHDR, "11.2.2"},
+		{checkChunkOrdering, "5.6"},
+		{checkPLTE, "11.2.3"},
+		{checkTRNS, "11.3.2.1"},
+		{checkICCP, "11.3.3.3"},
+		{checkText, "11.3.4.2"},
+		{checkTIME, "11.3.6.1"},
+		{checkBKGD, "11.3.5.1"},
+		{checkIEND, "11.2.5"},
+		{checkIDAT, "11.2.4"},
+		{checkCompression, "10"},
+		{checkFiltering, "9"},
+	}
+	file := testPNG(testIHDR(1, 1), testChunk{"IDAT", zlibBytes([]byte{0, 0})}, testChunk{"IEND", nil})
+	report, err := ConformanceReport(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Conformant || report.Error != "" || len(report.Checks) != len(sections) {
+		t.Fatalf("got %+v", report)
+	}
+	for i, c := range report.Checks {
+		if c.ID != sections[i].id || c.Section != sections[i].section || c.Title == "" || c.Status != StatusPass || len(c.Messages) != 0 {
+			t.Errorf("check %d: got %+v, want %s in section %s", i, c, sections[i].id, sections[i].section)
+		}
+	}
+}
+
+// TestConformanceReportChecks は各検証項目について、違反を含むファイルでその項目だけが失敗し、
+// デコードが止まった段階より後の項目が未実行になることを確認する。
+func TestConformanceReportChecks(t *testing.T) {
+	ihdr, idat, iend := testIHDR(1, 1), testChunk{"IDAT", zlibBytes([]byte{0, 0})}, testChunk{"IEND", nil}
+	valid := testPNG(ihdr, idat, iend)
+	badCRC := append([]byte(nil), valid...)
+	badCRC[len(badCRC)-1] ^= 1
+
+	// デコードが止まった段階より後の検証項目
+	afterChunks := []string{checkIDAT, checkCompression, checkFiltering}
+	afterHeader := append([]string{checkChunkOrdering, checkPLTE, checkTRNS, checkICCP, checkText, checkTIME, checkBKGD, checkIEND}, afterChunks...)
+	afterSignature := append([]string{checkChunkLayout, checkChunkNaming, checkCRC, checkIHDR}, afterHeader...)
+
+	tests := []struct {
+		check string
+		file  []byte
+		// notRun はデコードが止まったために未実行になる項目
+		notRun []string
+	}{
+		{checkSignature, []byte("not a PNG file at all"), afterSignature},
+		{checkChunkLayout, valid[:len(valid)-6], afterChunks},
+		{checkChunkNaming, testPNG(ihdr, testChunk{"te5t", nil}, idat, iend), nil},
+		{checkCRC, badCRC, nil},
+		{checkIHDR, testPNG(testIHDR(0, 1), idat, iend), afterHeader},
+		{checkChunkOrdering, testPNG(ihdr, idat, testChunk{"tEXt", []byte("Comment\x00a")}, idat, iend), nil},
+		{checkPLTE, testPNG(ihdr, testChunk{"PLTE", []byte{0, 0, 0}}, idat, iend), nil},
+		{checkTRNS, testPNG(ihdr, testChunk{"tRNS", []byte{0}}, idat, iend), afterChunks},
+		{checkICCP, testPNG(ihdr, testChunk{"iCCP", []byte("x")}, idat, iend), nil},
+		{checkText, testPNG(ihdr, testChunk{"tEXt", []byte(" bad\x00text")}, idat, iend), nil},
+		{checkTIME, testPNG(ihdr, testChunk{"tIME", []byte{0, 0, 0}}, idat, iend), nil},
+		{checkBKGD, testPNG(ihdr, testChunk{"bKGD", []byte{0}}, idat, iend), nil},
+		{checkIEND, append(valid, "trailing"...), nil},
+		{checkIDAT, testPNG(ihdr, testChunk{"IDAT", zlibBytes([]byte{0})}, iend), nil},
+		{checkCompression, testPNG(ihdr, testChunk{"IDAT", []byte("not zlib")}, iend), nil},
+		{checkFiltering, testPNG(ihdr, testChunk{"IDAT", zlibBytes([]byte{5, 0})}, iend), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.check, func(t *testing.T) {
+			report, err := ConformanceReport(bytes.NewReader(tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Conformant {
+				t.Fatal("reported as conformant")
+			}
+			notRun := make(map[string]bool)
+			for _, id := range tt.notRun {
+				notRun[id] = true
+			}
+			for _, c := range report.Checks {
+				want := StatusPass
+				switch {
+				case c.ID == tt.check:
+					want = StatusFail
+				case notRun[c.ID]:
+					want = StatusNotRun
+				}
+				if c.Status != want {
+					t.Errorf("%s: %s %v, want %s", c.ID, c.Status, c.Messages, want)
+				}
+				if c.Status == StatusFail && len(c.Messages) == 0 {
+					t.Errorf("%s: failed without a message", c.ID)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package pngreader
+
+import "fmt"
+
+const maxInt = int(^uint(0) >> 1)
+
+// maxDimension はPNG仕様で許されるwidth、heightの最大値(2^31-1)
+const maxDimension = 1<<31 - 1
+
+// mul はa*bを計算する。結果がintに収まらない場合はエラーを返す。
+func mul(a, b int) (int, error) {
+	if a < 0 || b < 0 {
+		return 0, fmt.Errorf("negative size %d*%d", a, b)
+	}
+	if a != 0 && b > maxInt/a {
+		return 0, fmt.Errorf("size %d*%d overflows int", a, b)
+	}
+	return a * b, nil
+}
+
+// add はa+bを計算する。結果がintに収まらない場合はエラーを返す。
+func add(a, b int) (int, error) {
+	if a < 0 || b < 0 {
+		return 0, fmt.Errorf("negative size %d+%d", a, b)
+	}
+	if a > maxInt-b {
+		return 0, fmt.Errorf("size %d+%d overflows int", a, b)
+	}
+	return a + b, nil
+}
+
+// rowBytes はフィルタタイプを除いた1行のバイト数を計算する。
+func rowBytes(width, bitsPerPixel int) (int, error) {
+	bits, err := mul(width, bitsPerPixel)
+	if err != nil {
+		return 0, err
+	}
+	bits, err = add(bits, 7)
+	if err != nil {
+		return 0, err
+	}
+	return bits / 8, nil
+}
+
+// filteredBytes はフィルタタイプを含むheight行分のバイト数を計算する。
+func filteredBytes(width, height, bitsPerPixel int) (int, error) {
+	n, err := rowBytes(width, bitsPerPixel)
+	if err != nil {
+		return 0, err
+	}
+	n, err = add(n, 1)
+	if err != nil {
+		return 0, err
+	}
+	return mul(n, height)
+}
+
+// pixelBytes はwidth*height*bytesPerPixelを計算する。
+func pixelBytes(width, height, bytesPerPixel int) (int, error) {
+	n, err := mul(width, height)
+	if err != nil {
+		return 0, err
+	}
+	return mul(n, bytesPerPixel)
+}
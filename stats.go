@@ -0,0 +1,100 @@
+package pngreader
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+
+	"github.com/kouheiszk/png-reader/deflate"
+)
+
+// FilterNames はフィルタタイプ0から4の名前
+var FilterNames = [5]string{"none", "sub", "up", "average", "paeth"}
+
+// Stats は画像データを展開して集めた統計
+type Stats struct {
+	*Info
+
+	Filters FilterStats `json:"filters"`
+
+	// Compression はIDATのzlibストリームのブロック構造と、リテラルと一致の集計
+	Compression *deflate.Stats `json:"compression"`
+	// Recompressed は同じフィルタのままzlibの最高圧縮で圧縮し直した場合のIDATの合計の大きさ
+	Recompressed int `json:"recompressedSize"`
+}
+
+// FilterStats は走査線ごとのフィルタタイプの集計
+type FilterStats struct {
+	// Counts はフィルタタイプごとの走査線の数。添字はフィルタタイプ
+	Counts [5]int `json:"counts"`
+	// Rows は各走査線のフィルタタイプ。インターレースの場合はパスの順に並ぶ。
+	// StatsOptions.SkipRowsの場合はnil
+	Rows []int `json:"rows,omitempty"`
+
+	skipRows bool
+}
+
+func (s *FilterStats) add(filterType int) {
+	s.Counts[filterType]++
+	if !s.skipRows {
+		s.Rows = append(s.Rows, filterType)
+	}
+}
+
+// Total は走査線の数を返す。
+func (s *FilterStats) Total() int {
+	total := 0
+	for _, n := range s.Counts {
+		total += n
+	}
+	return total
+}
+
+// StatsOptions はDecodeStatsOptionsで集める統計を選ぶ。ゼロ値ではすべて集める。
+type StatsOptions struct {
+	// SkipRows が真の場合、FilterStats.Rowsを集めない。走査線の数に比例するメモリを使わずに済む。
+	SkipRows bool
+}
+
+// DecodeStats はrのPNG画像を展開し、Statsを返す。
+func DecodeStats(r io.Reader) (*Stats, error) {
+	return new(Decoder).DecodeStats(r)
+}
+
+// DecodeStats はrのPNG画像を展開し、Statsを返す。
+// 画像データを展開したうえで圧縮し直すので、デコードより時間がかかる。
+func (d *Decoder) DecodeStats(r io.Reader) (*Stats, error) {
+	return d.DecodeStatsOptions(r, nil)
+}
+
+// DecodeStatsOptions はoptsで選んだ統計だけを集めるDecodeStats。optsがnilの場合はすべて集める。
+func (d *Decoder) DecodeStatsOptions(r io.Reader, opts *StatsOptions) (*Stats, error) {
+	if opts == nil {
+		opts = new(StatsOptions)
+	}
+	stats := &Stats{Filters: FilterStats{skipRows: opts.SkipRows}}
+	p := &decoder{Decoder: d, seen: make(map[string]int), filters: &stats.Filters}
+	if _, err := p.parse(r); err != nil {
+		return nil, err
+	}
+	stats.Info = p.info()
+
+	var raw, recompressed bytes.Buffer
+	compression, err := deflate.Analyze(&idatReader{chunks: p.idat}, &raw)
+	if err != nil {
+		return nil, err
+	}
+	stats.Compression = compression
+	zw, err := zlib.NewWriterLevel(&recompressed, zlib.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(raw.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	stats.Recompressed = recompressed.Len()
+	return stats, nil
+}
@@ -0,0 +1,42 @@
+package pngreader
+
+import (
+	"bytes"
+	"image"
+	"math/rand"
+	"testing"
+)
+
+// TestDecodeStats はフィルタの集計と、SkipRowsで走査線ごとの記録を省けることを確認する。
+func TestDecodeStats(t *testing.T) {
+	m := randomNRGBA(rand.New(rand.NewSource(5)), image.Rect(0, 0, 9, 13))
+	var b bytes.Buffer
+	if err := (&Encoder{Filter: FilterPaeth}).Encode(&b, m); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := DecodeStats(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Filters.Counts[4] != 13 || stats.Filters.Total() != 13 {
+		t.Errorf("counts %v, want 13 paeth rows", stats.Filters.Counts)
+	}
+	if len(stats.Filters.Rows) != 13 || stats.Filters.Rows[0] != 4 {
+		t.Errorf("rows %v, want 13 paeth rows", stats.Filters.Rows)
+	}
+	if stats.Compression == nil || stats.Recompressed <= 0 {
+		t.Errorf("missing compression stats: %+v", stats)
+	}
+
+	skipped, err := new(Decoder).DecodeStatsOptions(bytes.NewReader(b.Bytes()), &StatsOptions{SkipRows: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skipped.Filters.Rows != nil {
+		t.Errorf("SkipRows: got %d rows, want none", len(skipped.Filters.Rows))
+	}
+	if skipped.Filters.Counts != stats.Filters.Counts || skipped.Recompressed != stats.Recompressed {
+		t.Errorf("SkipRows changed the other stats")
+	}
+}
@@ -0,0 +1,271 @@
+// Package stego は画素の下位ビットに任意のデータを埋め込み、取り出す(LSBステガノグラフィ)。
+//
+// データは画素を左上から行順に、各画素ではOptions.Channelsの順にチャンネルをたどり、
+// 各チャンネルの下位Options.Bitsビットに上位ビットから詰めていく。
+// 先頭にはデータの長さ(4バイト、ビッグエンディアン)を置く。
+// Options.Passphraseを指定するとAES-256-GCMで暗号化してから埋め込む。
+//
+// 埋め込んだ画像はPNGのような可逆形式で保存しなければデータが失われる。
+package stego
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Options は埋め込みと取り出しの設定。取り出すときは埋め込んだときと同じ値を指定する。
+type Options struct {
+	// Bits はチャンネルごとに使う下位ビットの数(1から8)。0の場合は1
+	Bits int
+	// Channels は使うチャンネルと順序を"r"、"g"、"b"、"a"で指定する。空の場合は"rgb"
+	Channels string
+	// Passphrase が空でなければ、データをこのパスフレーズから導いた鍵で暗号化する。
+	Passphrase []byte
+}
+
+// ErrCapacity は画像にデータが収まらないことを表す。
+var ErrCapacity = errors.New("stego: payload exceeds image capacity")
+
+// ErrNoPayload は画像から有効なデータを取り出せないことを表す。
+var ErrNoPayload = errors.New("stego: no payload found")
+
+const (
+	lengthSize = 4
+
+	// 暗号化したデータはソルト、ノンス、暗号文の順に並ぶ
+	saltSize   = 16
+	nonceSize  = 12
+	tagSize    = 16
+	iterations = 100000
+)
+
+// layout は検証済みの設定
+type layout struct {
+	bits     int
+	channels []int // NRGBAの画素内のオフセット
+}
+
+func (o *Options) layout() (*layout, error) {
+	l := &layout{bits: 1}
+	channels := "rgb"
+	if o != nil {
+		if o.Bits != 0 {
+			l.bits = o.Bits
+		}
+		if o.Channels != "" {
+			channels = o.Channels
+		}
+	}
+	if l.bits < 1 || l.bits > 8 {
+		return nil, fmt.Errorf("stego: bits must be between 1 and 8, got %d", l.bits)
+	}
+	for _, c := range strings.ToLower(channels) {
+		i := strings.IndexRune("rgba", c)
+		if i < 0 {
+			return nil, fmt.Errorf("stego: unknown channel %q", c)
+		}
+		for _, j := range l.channels {
+			if j == i {
+				return nil, fmt.Errorf("stego: channel %q given twice", c)
+			}
+		}
+		l.channels = append(l.channels, i)
+	}
+	return l, nil
+}
+
+func (o *Options) passphrase() []byte {
+	if o == nil {
+		return nil
+	}
+	return o.Passphrase
+}
+
+// capacity はn画素の画像に埋め込めるビット数
+func (l *layout) capacity(n int) int {
+	return n * len(l.channels) * l.bits
+}
+
+// Capacity はimgに埋め込めるデータの最大バイト数を返す。
+// 長さの領域を除き、暗号化する場合はソルト、ノンス、認証タグの分も除いた値になる。
+func Capacity(img image.Image, o *Options) (int, error) {
+	l, err := o.layout()
+	if err != nil {
+		return 0, err
+	}
+	b := img.Bounds()
+	n := l.capacity(b.Dx()*b.Dy())/8 - lengthSize
+	if len(o.passphrase()) > 0 {
+		n -= saltSize + nonceSize + tagSize
+	}
+	if n < 0 {
+		n = 0
+	}
+	return n, nil
+}
+
+// Embed はimgの画素にpayloadを埋め込んだ画像を返す。imgは変更しない。
+// アルファが0の画素も色の値を保つよう、結果はimage.NRGBAになる。
+// 16ビットの画像も8ビットのimage.NRGBAに変換するため、各チャンネルの下位8ビットは失われる。
+func Embed(img image.Image, payload []byte, o *Options) (*image.NRGBA, error) {
+	l, err := o.layout()
+	if err != nil {
+		return nil, err
+	}
+	if passphrase := o.passphrase(); len(passphrase) > 0 {
+		if payload, err = encrypt(payload, passphrase); err != nil {
+			return nil, err
+		}
+	}
+	data := make([]byte, lengthSize+len(payload))
+	binary.BigEndian.PutUint32(data, uint32(len(payload)))
+	copy(data[lengthSize:], payload)
+
+	b := img.Bounds()
+	if len(data)*8 > l.capacity(b.Dx()*b.Dy()) {
+		return nil, ErrCapacity
+	}
+	dst := toNRGBA(img)
+	w := &bitWriter{l: l, pix: dst.Pix, bit: l.bits}
+	for _, c := range data {
+		w.write(c)
+	}
+	return dst, nil
+}
+
+// Extract はEmbedで埋め込んだデータをimgから取り出す。
+func Extract(img image.Image, o *Options) ([]byte, error) {
+	l, err := o.layout()
+	if err != nil {
+		return nil, err
+	}
+	b := img.Bounds()
+	src := toNRGBA(img)
+	r := &bitReader{bitWriter{l: l, pix: src.Pix, bit: l.bits}}
+	capacity := l.capacity(b.Dx()*b.Dy()) / 8
+	if capacity < lengthSize {
+		return nil, ErrNoPayload
+	}
+	var length [lengthSize]byte
+	for i := range length {
+		length[i] = r.read()
+	}
+	n := binary.BigEndian.Uint32(length[:])
+	if uint64(n) > uint64(capacity-lengthSize) {
+		return nil, ErrNoPayload
+	}
+	payload := make([]byte, n)
+	for i := range payload {
+		payload[i] = r.read()
+	}
+	if passphrase := o.passphrase(); len(passphrase) > 0 {
+		return decrypt(payload, passphrase)
+	}
+	return payload, nil
+}
+
+// toNRGBA はimgの画素をコピーしたimage.NRGBAを返す。
+// draw.Drawは乗算済みのアルファを経由して半透明の画素の下位ビットを変えてしまうため、画素ごとに変換する。
+func toNRGBA(img image.Image) *image.NRGBA {
+	b := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			dst.SetNRGBA(x, y, color.NRGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA))
+		}
+	}
+	return dst
+}
+
+// bitWriter はNRGBAの画素列の下位ビットに1バイトずつ書き込む。
+type bitWriter struct {
+	l   *layout
+	pix []byte
+
+	pixel, channel, bit int // 次に書き込む位置。bitはチャンネル内で次に使うビットの1つ上の位置
+}
+
+// next は次のビットの位置(Pixのインデックスとビット)を返し、位置を進める。
+func (w *bitWriter) next() (int, uint) {
+	w.bit--
+	i, shift := w.pixel*4+w.l.channels[w.channel], uint(w.bit)
+	if w.bit == 0 {
+		w.bit = w.l.bits
+		if w.channel++; w.channel == len(w.l.channels) {
+			w.channel = 0
+			w.pixel++
+		}
+	}
+	return i, shift
+}
+
+func (w *bitWriter) write(c byte) {
+	for k := 7; k >= 0; k-- {
+		i, shift := w.next()
+		w.pix[i] = w.pix[i]&^(1<<shift) | (c>>uint(k)&1)<<shift
+	}
+}
+
+// bitReader はbitWriterと同じ順序で下位ビットを読み込む。
+type bitReader struct {
+	bitWriter
+}
+
+func (r *bitReader) read() byte {
+	var c byte
+	for k := 0; k < 8; k++ {
+		i, shift := r.next()
+		c = c<<1 | r.pix[i]>>shift&1
+	}
+	return c
+}
+
+func encrypt(plaintext, passphrase []byte) ([]byte, error) {
+	header := make([]byte, saltSize+nonceSize)
+	if _, err := rand.Read(header); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(passphrase, header[:saltSize])
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(header, header[saltSize:], plaintext, nil), nil
+}
+
+func decrypt(data, passphrase []byte) ([]byte, error) {
+	if len(data) < saltSize+nonceSize {
+		return nil, ErrNoPayload
+	}
+	aead, err := newAEAD(passphrase, data[:saltSize])
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, data[saltSize:saltSize+nonceSize], data[saltSize+nonceSize:], nil)
+	if err != nil {
+		return nil, errors.New("stego: wrong passphrase or corrupted payload")
+	}
+	return plaintext, nil
+}
+
+func newAEAD(passphrase, salt []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(deriveKey(passphrase, salt))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// deriveKey はPBKDF2-HMAC-SHA256でパスフレーズから32バイトの鍵を導く。
+func deriveKey(passphrase, salt []byte) []byte {
+	return pbkdf2.Key(passphrase, salt, iterations, 32, sha256.New)
+}
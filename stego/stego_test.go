@@ -0,0 +1,128 @@
+package stego
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	pngreader "github.com/kouheiszk/png-reader"
+)
+
+// testImage は半透明の画素を含む、原点がずれた37x27の画像を返す。
+func testImage() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(3, 3, 40, 30))
+	for i := range img.Pix {
+		img.Pix[i] = byte(i * 7)
+	}
+	return img
+}
+
+// TestRoundTrip は埋め込んだデータがPNGに保存して読み直しても取り出せることを確認する。
+func TestRoundTrip(t *testing.T) {
+	img := testImage()
+	payload := []byte("translucent pixels keep their low bits")
+	for _, o := range []*Options{nil, {Bits: 3, Channels: "arg"}, {Bits: 8, Channels: "b", Passphrase: []byte("secret")}} {
+		out, err := Embed(img, payload, o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		if err := pngreader.Encode(&b, out); err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := pngreader.Decode(&b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Extract(decoded, o)
+		if err != nil || !bytes.Equal(got, payload) {
+			t.Errorf("%+v: got %q, %v", o, got, err)
+		}
+	}
+}
+
+// TestLowBits は1ビットで埋め込むと、指定したチャンネルの最下位ビットだけが変わることを確認する。
+func TestLowBits(t *testing.T) {
+	img := testImage()
+	out, err := Embed(img, bytes.Repeat([]byte{0xa5}, 100), &Options{Channels: "rg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range out.Pix {
+		want := img.Pix[i]
+		if c := i % 4; c == 0 || c == 1 {
+			want &^= 1
+			v &^= 1
+		}
+		if v != want {
+			t.Fatalf("byte %d (channel %d) changed from %d to %d", i, i%4, img.Pix[i], out.Pix[i])
+		}
+	}
+}
+
+// TestEmbed16Bit は16ビットの画像が各チャンネルの上位8ビットの8ビット画像として埋め込まれることを確認する。
+func TestEmbed16Bit(t *testing.T) {
+	img := image.NewNRGBA64(image.Rect(0, 0, 16, 16))
+	for i := range img.Pix {
+		img.Pix[i] = byte(i * 13)
+		// 不透明にして、乗算済みの値を経由した丸めが入らないようにする
+		if i%8 >= 6 {
+			img.Pix[i] = 0xff
+		}
+	}
+	out, err := Embed(img, []byte("deep"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range out.Pix {
+		if want := img.Pix[2*i]; v&^1 != want&^1 {
+			t.Fatalf("byte %d: got %d, want the high byte %d", i, v, want)
+		}
+	}
+	if got, err := Extract(out, nil); err != nil || string(got) != "deep" {
+		t.Errorf("got %q, %v", got, err)
+	}
+}
+
+func TestPassphrase(t *testing.T) {
+	img := testImage()
+	out, err := Embed(img, []byte("hidden"), &Options{Passphrase: []byte("right")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Extract(out, &Options{Passphrase: []byte("wrong")}); err == nil {
+		t.Error("wrong passphrase accepted")
+	}
+	if got, err := Extract(out, nil); err != nil || bytes.Equal(got, []byte("hidden")) {
+		t.Errorf("extracting without the passphrase returned %q, %v", got, err)
+	}
+}
+
+// TestCapacity はCapacityのバイト数がちょうど収まり、1バイト多いとErrCapacityになることを確認する。
+func TestCapacity(t *testing.T) {
+	img := testImage()
+	for _, o := range []*Options{nil, {Bits: 2, Channels: "rgba"}, {Passphrase: []byte("secret")}} {
+		n, err := Capacity(img, o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Embed(img, make([]byte, n), o); err != nil {
+			t.Errorf("%+v: %d bytes: %v", o, n, err)
+		}
+		if _, err := Embed(img, make([]byte, n+1), o); err != ErrCapacity {
+			t.Errorf("%+v: %d bytes: got %v, want ErrCapacity", o, n+1, err)
+		}
+	}
+	if _, err := Extract(image.NewNRGBA(image.Rect(0, 0, 2, 2)), nil); err != ErrNoPayload {
+		t.Errorf("tiny image: got %v, want ErrNoPayload", err)
+	}
+}
+
+func TestInvalidOptions(t *testing.T) {
+	img := testImage()
+	for _, o := range []*Options{{Bits: 9}, {Bits: -1}, {Channels: "rx"}, {Channels: "rr"}} {
+		if _, err := Embed(img, nil, o); err == nil {
+			t.Errorf("%+v: no error", o)
+		}
+	}
+}
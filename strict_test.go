@@ -0,0 +1,52 @@
+package pngreader
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// TestStrictWarnings は、Strictでない場合は警告するだけの問題を、Strictの場合はエラーにすることを確認する。
+// 仕様違反ではないため、ConformanceReportでは適合のままになる。
+func TestStrictWarnings(t *testing.T) {
+	ihdr, idat, iend := testIHDR(1, 1), testChunk{"IDAT", zlibBytes([]byte{0, 0})}, testChunk{"IEND", nil}
+	longText := append([]byte("Comment\x00"), bytes.Repeat([]byte("a"), longText+1)...)
+	tests := []struct {
+		name  string
+		file  []byte
+		class error
+	}{
+		{"unknown ancillary chunk", testPNG(ihdr, testChunk{"teSt", []byte("data")}, idat, iend), ErrUnsupported},
+		{"long text", testPNG(ihdr, testChunk{"tEXt", longText}, idat, iend), ErrFormat},
+		{"invalid EXIF", testPNG(ihdr, testChunk{"eXIf", []byte("XX\x00\x2a\x00\x00\x00\x08")}, idat, iend), ErrFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var warnings []error
+			decoder := &Decoder{Warn: func(err error) { warnings = append(warnings, err) }}
+			if _, err := decoder.Decode(bytes.NewReader(tt.file)); err != nil {
+				t.Fatalf("non-strict: %v", err)
+			}
+			if len(warnings) != 1 {
+				t.Errorf("non-strict: got %d warnings, want 1", len(warnings))
+			}
+
+			_, err := (&Decoder{Strict: true}).Decode(bytes.NewReader(tt.file))
+			if !errors.Is(err, tt.class) {
+				t.Errorf("strict: got %v, want %v", err, tt.class)
+			}
+			var decodeErr *DecodeError
+			if !errors.As(err, &decodeErr) || decodeErr.ChunkType == "" {
+				t.Errorf("strict: %v has no chunk location", err)
+			}
+
+			report, err := ConformanceReport(bytes.NewReader(tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !report.Conformant {
+				t.Errorf("report: not conformant: %s", report.Error)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+//go:build js && wasm
+// +build js,wasm
+
+// wasm はブラウザからpngreaderを使うためのWebAssemblyモジュール。
+// 読み込むとグローバルオブジェクトpngreaderにdecode関数を登録する。
+// 通常はpngreader.jsを経由して使う。
+//
+//	GOOS=js GOARCH=wasm go build -o pngreader.wasm ./wasm
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image"
+	"syscall/js"
+
+	pngreader "github.com/kouheiszk/png-reader"
+)
+
+func main() {
+	js.Global().Set("pngreader", js.ValueOf(map[string]interface{}{
+		"decode": js.FuncOf(decode),
+	}))
+	// Goのプログラムが終了すると登録した関数を呼べなくなるため、待ち続ける
+	select {}
+}
+
+// decode はUint8ArrayのPNGをデコードし、次のプロパティを持つオブジェクトを返す。
+//
+//	width, height  画像の大きさ
+//	pixels         非乗算済みRGBA各8ビットのUint8ClampedArray(ImageDataにそのまま使える)
+//	pixels16       ビット深度16の場合のみ、非乗算済みRGBA各16ビットのUint16Array
+//	metadata       pngreader.Infoと同じ内容のオブジェクト
+//
+// 失敗した場合はerrorプロパティにメッセージを持つオブジェクトを返す。
+func decode(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 {
+		return errorResult(fmt.Errorf("decode takes one Uint8Array argument"))
+	}
+	data := make([]byte, args[0].Get("length").Int())
+	js.CopyBytesToGo(data, args[0])
+
+	info, err := pngreader.DecodeInfo(bytes.NewReader(data))
+	if err != nil {
+		return errorResult(err)
+	}
+	img, err := pngreader.Decode(bytes.NewReader(data))
+	if err != nil {
+		return errorResult(err)
+	}
+	metadata, err := json.Marshal(info)
+	if err != nil {
+		return errorResult(err)
+	}
+
+	result := map[string]interface{}{
+		"width":    info.Width,
+		"height":   info.Height,
+		"metadata": js.Global().Get("JSON").Call("parse", string(metadata)),
+	}
+	switch m := img.(type) {
+	case *image.NRGBA:
+		result["pixels"] = toJS("Uint8ClampedArray", m.Pix)
+	case *image.NRGBA64:
+		pixels := make([]byte, len(m.Pix)/2)
+		pixels16 := make([]byte, len(m.Pix))
+		for i := 0; i < len(m.Pix); i += 2 {
+			pixels[i/2] = m.Pix[i]
+			// Uint16Arrayはリトルエンディアンで解釈される
+			binary.LittleEndian.PutUint16(pixels16[i:], binary.BigEndian.Uint16(m.Pix[i:]))
+		}
+		result["pixels"] = toJS("Uint8ClampedArray", pixels)
+		result["pixels16"] = js.Global().Get("Uint16Array").New(toJS("Uint8Array", pixels16).Get("buffer"))
+	}
+	return js.ValueOf(result)
+}
+
+// toJS はbをコピーした、typeNameの型付き配列を作成する。
+func toJS(typeName string, b []byte) js.Value {
+	array := js.Global().Get(typeName).New(len(b))
+	js.CopyBytesToJS(array, b)
+	return array
+}
+
+func errorResult(err error) interface{} {
+	return js.ValueOf(map[string]interface{}{"error": err.Error()})
+}
@@ -0,0 +1,32 @@
+// pngreader.wasmを読み込み、PNGをデコードするdecode関数を提供する。
+// Goのwasm_exec.js($(go env GOROOT)/lib/wasm/wasm_exec.js、古いGoではmisc/wasm)を
+// 先に読み込んでおくこと。
+//
+//   const pngreader = await loadPNGReader("pngreader.wasm");
+//   const { width, height, pixels, metadata } = pngreader.decode(bytes);
+//   ctx.putImageData(new ImageData(pixels, width, height), 0, 0);
+
+async function loadPNGReader(url) {
+  const go = new Go();
+  const { instance } = await WebAssembly.instantiateStreaming(fetch(url), go.importObject);
+  // run()はGoのmainが終了するまで解決しないため、待たない
+  go.run(instance);
+
+  return {
+    // decode はUint8ArrayまたはArrayBufferのPNGをデコードする。失敗した場合は例外を投げる。
+    decode(bytes) {
+      if (bytes instanceof ArrayBuffer) {
+        bytes = new Uint8Array(bytes);
+      }
+      const result = globalThis.pngreader.decode(bytes);
+      if (result.error) {
+        throw new Error(result.error);
+      }
+      return result;
+    },
+  };
+}
+
+if (typeof module !== "undefined") {
+  module.exports = { loadPNGReader };
+}